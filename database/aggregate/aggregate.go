@@ -0,0 +1,36 @@
+// package aggregate provide constructors of aggregations for ManagerI.Aggregate:
+//
+//	manager.Aggregate(aggregate.Sum("Year"), aggregate.Avg("Price"))
+package aggregate
+
+import (
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// Sum returns sum of values of field.
+func Sum(field string) Aggregation {
+	return Aggregation{Func: AggSum, Field: field}
+}
+
+// Avg returns average of values of field.
+func Avg(field string) Aggregation {
+	return Aggregation{Func: AggAvg, Field: field}
+}
+
+// Min returns minimal value of field.
+func Min(field string) Aggregation {
+	return Aggregation{Func: AggMin, Field: field}
+}
+
+// Max returns maximal value of field.
+func Max(field string) Aggregation {
+	return Aggregation{Func: AggMax, Field: field}
+}
+
+// Count returns count of models, or count of not null values of field.
+func Count(field ...string) Aggregation {
+	if len(field) > 0 {
+		return Aggregation{Func: AggCount, Field: field[0]}
+	}
+	return Aggregation{Func: AggCount}
+}
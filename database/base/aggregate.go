@@ -0,0 +1,210 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+func (manager *Manager) Aggregate(aggregations ...Aggregation) (Params, error) {
+	return manager.AggregateContext(context.Background(), aggregations...)
+}
+
+func (manager *Manager) AggregateContext(ctx context.Context, aggregations ...Aggregation) (Params, error) {
+	groups, err := manager.aggregate(ctx, nil, aggregations)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return aggregateModels(nil, aggregations)
+	}
+	return groups[0].Result, nil
+}
+
+func (manager *Manager) GroupBy(fields ...string) Grouping {
+	return &grouping{manager, append([]string{}, fields...)}
+}
+
+func (manager *Manager) Distinct(field string) ([]any, error) {
+	return manager.DistinctContext(context.Background(), field)
+}
+
+func (manager *Manager) DistinctContext(ctx context.Context, field string) ([]any, error) {
+	groups, err := manager.aggregate(ctx, []string{field}, nil)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(groups))
+	for i, group := range groups {
+		values[i] = group.Values[field]
+	}
+	return values, nil
+}
+
+// aggregate returns groups ordered by values,
+// OnAggregate is used if manager is not paged.
+func (manager *Manager) aggregate(ctx context.Context, groupBy []string, aggregations []Aggregation) ([]Group, error) {
+	for _, aggregation := range aggregations {
+		switch aggregation.Func {
+		case AggSum, AggAvg, AggMin, AggMax, AggCount:
+		default:
+			return nil, NewErrNotSupported(fmt.Sprintf("aggregation `%v`", aggregation.Func))
+		}
+	}
+
+	var groups []Group
+	if manager.OnAggregate != nil && manager.query.Limit == 0 && manager.query.Offset == 0 &&
+		(!manager.isInstance || len(manager.query.Where) > 0) {
+		var err error
+		if groups, err = manager.OnAggregate(ctx, manager, groupBy, aggregations); err != nil {
+			return nil, err
+		}
+	} else {
+		models, err := manager.AllContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if groups, err = groupModels(models, groupBy, aggregations); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		for _, field := range groupBy {
+			if r := Compare(groups[i].Values[field], groups[j].Values[field]); r != 0 && r != -2 {
+				return r == -1
+			}
+		}
+		return false
+	})
+	return groups, nil
+}
+
+// groupModels groups models by values of fields and aggregates every group.
+func groupModels(models []Model, groupBy []string, aggregations []Aggregation) ([]Group, error) {
+	keys := []string{}
+	values := map[string]Params{}
+	grouped := map[string][]Model{}
+	for _, model := range models {
+		groupValues := Params{}
+		parts := make([]string, len(groupBy))
+		for i, field := range groupBy {
+			value, err := Check(model, field)
+			if err != nil {
+				return nil, err
+			}
+			groupValues[field] = value.Interface()
+			parts[i] = fmt.Sprintf("%#v", value.Interface())
+		}
+		key := strings.Join(parts, "\x00")
+		if _, ok := grouped[key]; !ok {
+			keys = append(keys, key)
+			values[key] = groupValues
+		}
+		grouped[key] = append(grouped[key], model)
+	}
+
+	groups := make([]Group, 0, len(keys))
+	for _, key := range keys {
+		result, err := aggregateModels(grouped[key], aggregations)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, Group{Values: values[key], Result: result})
+	}
+	return groups, nil
+}
+
+// aggregateModels returns results of aggregations over models.
+func aggregateModels(models []Model, aggregations []Aggregation) (Params, error) {
+	result := Params{}
+	for _, aggregation := range aggregations {
+		if aggregation.Func == AggCount && aggregation.Field == "" {
+			result[aggregation.Key()] = uint(len(models))
+			continue
+		}
+
+		var sum float64
+		var count uint
+		var bound any
+		for _, model := range models {
+			value, err := Check(model, aggregation.Field)
+			if err != nil {
+				return nil, err
+			}
+			v := value.Interface()
+			if isNull(v) {
+				continue
+			}
+			count++
+			switch aggregation.Func {
+			case AggSum, AggAvg:
+				number, ok := toFloat(value)
+				if !ok {
+					return nil, NewErrorf("aggregation `%v`: field is %v, not number", aggregation.Key(), value.Kind())
+				}
+				sum += number
+			case AggMin:
+				if bound == nil || Compare(v, bound) == -1 {
+					bound = v
+				}
+			case AggMax:
+				if bound == nil || Compare(v, bound) == 1 {
+					bound = v
+				}
+			}
+		}
+
+		switch aggregation.Func {
+		case AggSum:
+			result[aggregation.Key()] = sum
+		case AggAvg:
+			if count == 0 {
+				result[aggregation.Key()] = nil
+			} else {
+				result[aggregation.Key()] = sum / float64(count)
+			}
+		case AggMin, AggMax:
+			result[aggregation.Key()] = bound
+		case AggCount:
+			result[aggregation.Key()] = count
+		}
+	}
+	return result, nil
+}
+
+func toFloat(value *reflect.Value) (float64, bool) {
+	switch {
+	case value.CanInt():
+		return float64(value.Int()), true
+	case value.CanUint():
+		return float64(value.Uint()), true
+	case value.CanFloat():
+		return value.Float(), true
+	}
+	return 0, false
+}
+
+// grouping is Grouping of Manager.
+type grouping struct {
+	manager *Manager
+	fields  []string
+}
+
+func (g *grouping) Count() ([]Group, error) {
+	return g.AggregateContext(context.Background(), Aggregation{Func: AggCount})
+}
+
+func (g *grouping) Aggregate(aggregations ...Aggregation) ([]Group, error) {
+	return g.AggregateContext(context.Background(), aggregations...)
+}
+
+func (g *grouping) AggregateContext(ctx context.Context, aggregations ...Aggregation) ([]Group, error) {
+	return g.manager.aggregate(ctx, g.fields, aggregations)
+}
@@ -0,0 +1,65 @@
+package base
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// AutoTime returns value of field `auto:"kind"` of model, zero for model without it.
+func AutoTime(model Model, kind string) time.Time {
+	field, err := Check(model, AutoField(model, kind))
+	if err != nil {
+		return time.Time{}
+	}
+	return field.Convert(reflect.TypeOf(time.Time{})).Interface().(time.Time)
+}
+
+// restoredKey is key of context of saves of restored models.
+type restoredKey struct{}
+
+// WithRestored returns ctx of saves of restored models, they keep their auto fields
+// and version. Restore of dump saves models with it.
+func WithRestored(ctx context.Context) context.Context {
+	return context.WithValue(ctx, restoredKey{}, true)
+}
+
+// IsRestored reports whether ctx is context of saves of restored models.
+func IsRestored(ctx context.Context) bool {
+	restored, _ := ctx.Value(restoredKey{}).(bool)
+	return restored
+}
+
+// Stamp sets fields `auto:"created"` and `auto:"updated"` of model,
+// zero created keeps created time of model, zero one is set to updated.
+// Times are truncated to milliseconds as pocketbase stores them.
+// Restored models of ctx keep their times which are not zero.
+func Stamp(ctx context.Context, model Model, created, updated time.Time) {
+	if IsRestored(ctx) {
+		if kept := AutoTime(model, AutoUpdated); !kept.IsZero() {
+			updated = kept
+		}
+		created = time.Time{}
+	}
+	updated = updated.Truncate(time.Millisecond)
+	if created.IsZero() {
+		created = AutoTime(model, AutoCreated)
+	}
+	if created.IsZero() {
+		created = updated
+	}
+	setAutoTime(model, AutoCreated, created.Truncate(time.Millisecond))
+	setAutoTime(model, AutoUpdated, updated)
+}
+
+func setAutoTime(model Model, kind string, value time.Time) {
+	field, err := Check(model, AutoField(model, kind))
+	if err != nil {
+		return
+	}
+	field.Set(reflect.ValueOf(value).Convert(field.Type()))
+}
@@ -0,0 +1,72 @@
+package base
+
+import (
+	"reflect"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// SaveMany saves models in one transaction of table's DB.
+// Failed item rolls back the batch, errors of items are returned in ErrBatch
+// and fields set by saves, as ids and versions, are restored.
+func SaveMany(table Table, models []Model) error {
+	restore := keepFields(models)
+	errs := make([]error, len(models))
+	err := table.DB().Tx(func(tx DB) error {
+		txTable, err := tx.Table(table.Name(), table.Model())
+		if err != nil {
+			return err
+		}
+		for i, model := range models {
+			errs[i] = txTable.Save(model)
+		}
+		if batch := NewErrBatch(errs); batch.Failed() > 0 {
+			return batch
+		}
+		return nil
+	})
+	if err != nil {
+		restore()
+	}
+	return err
+}
+
+// DeleteMany deletes models of ids in one transaction of table's DB.
+// Failed item rolls back the batch, errors of items are returned in ErrBatch.
+func DeleteMany(table Table, ids []any) error {
+	errs := make([]error, len(ids))
+	return table.DB().Tx(func(tx DB) error {
+		txTable, err := tx.Table(table.Name(), table.Model())
+		if err != nil {
+			return err
+		}
+		for i, id := range ids {
+			errs[i] = txTable.Delete(id)
+		}
+		if batch := NewErrBatch(errs); batch.Failed() > 0 {
+			return batch
+		}
+		return nil
+	})
+}
+
+// keepFields returns function setting fields of models to their current values.
+func keepFields(models []Model) func() {
+	kept := make([]reflect.Value, len(models))
+	for i, model := range models {
+		modelV := reflect.ValueOf(model)
+		if modelV.Kind() != reflect.Pointer || modelV.IsNil() {
+			continue
+		}
+		kept[i] = reflect.New(modelV.Elem().Type()).Elem()
+		kept[i].Set(modelV.Elem())
+	}
+	return func() {
+		for i, model := range models {
+			if kept[i].IsValid() {
+				reflect.ValueOf(model).Elem().Set(kept[i])
+			}
+		}
+	}
+}
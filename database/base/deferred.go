@@ -0,0 +1,36 @@
+package base
+
+import (
+	"sync"
+)
+
+// Deferred collects updates of managers made in transaction,
+// they are applied by Run after commit.
+// Nil Deferred applies updates at once.
+type Deferred struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+func (deferred *Deferred) Do(fn func()) {
+	if deferred == nil {
+		fn()
+		return
+	}
+	deferred.mu.Lock()
+	deferred.fns = append(deferred.fns, fn)
+	deferred.mu.Unlock()
+}
+
+func (deferred *Deferred) Run() {
+	if deferred == nil {
+		return
+	}
+	deferred.mu.Lock()
+	fns := deferred.fns
+	deferred.fns = nil
+	deferred.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
@@ -1,27 +1,70 @@
 package base
 
 import (
+	"fmt"
+	"reflect"
+	"regexp"
 	"strings"
+	"time"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
 )
 
-func getOffset(key string) int {
-	if len(key) < 2 {
-		return 0
-	}
-	if strings.Contains("!<=>", string(key[len(key)-2])) {
-		return 2
+// match reports if value of model and value satisfy operator or lookup op.
+func match(mvalue any, op string, value any) bool {
+	switch op {
+	case "~":
+		return strings.Contains(strings.ToLower(fmt.Sprint(mvalue)), strings.ToLower(fmt.Sprint(value)))
+	case "__icontains":
+		if isList(mvalue) {
+			return anyOf(mvalue, func(item any) bool {
+				return strings.EqualFold(fmt.Sprint(item), fmt.Sprint(value))
+			})
+		}
+		return match(mvalue, "~", value)
+	case "!~":
+		return !match(mvalue, "~", value)
+	case "__contains":
+		if isList(mvalue) {
+			return anyOf(mvalue, func(item any) bool {
+				return match(item, "=", value)
+			})
+		}
+		return strings.Contains(fmt.Sprint(mvalue), fmt.Sprint(value))
+	case "__startswith":
+		return strings.HasPrefix(fmt.Sprint(mvalue), fmt.Sprint(value))
+	case "__in":
+		lookup := "="
+		if isList(mvalue) {
+			lookup = "__contains"
+		}
+		return isList(value) && anyOf(value, func(item any) bool {
+			return match(mvalue, lookup, item)
+		})
+	case "__isnull":
+		null, _ := value.(bool)
+		return isNull(mvalue) == null
+	case "__between":
+		bounds := reflect.ValueOf(value)
+		if !isList(value) || bounds.Len() != 2 {
+			return false
+		}
+		return match(mvalue, ">=", bounds.Index(0).Interface()) && match(mvalue, "<=", bounds.Index(1).Interface())
+	case "__regex":
+		re, err := regexp.Compile(fmt.Sprint(value))
+		return err == nil && re.MatchString(fmt.Sprint(mvalue))
 	}
-	if strings.Contains("<=>", string(key[len(key)-1])) {
-		return 1
+	converted, ok := convertLike(value, mvalue)
+	if !ok {
+		return false
 	}
-	return 0
+	return checkOp(op, Compare(mvalue, converted))
 }
 
-func checkKey(key string, compareRes int) bool {
-	if len(key) < 2 {
-		return compareRes == 0
-	}
-	switch key[len(key)-getOffset(key):] {
+func checkOp(op string, compareRes int) bool {
+	switch op {
 	case "<":
 		return compareRes == -1
 	case "=":
@@ -37,3 +80,53 @@ func checkKey(key string, compareRes int) bool {
 	}
 	return compareRes == 0
 }
+
+func isList(value any) bool {
+	kind := reflect.ValueOf(value).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+func anyOf(list any, fn func(item any) bool) bool {
+	items := reflect.ValueOf(list)
+	for i := 0; i < items.Len(); i++ {
+		if fn(items.Index(i).Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNull reports if value is nil, empty string, empty list or zero time.
+// Numbers and bools are never null.
+func isNull(value any) bool {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil() || isNull(v.Elem().Interface())
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() == 0
+	}
+	if t, ok := value.(time.Time); ok {
+		return t.IsZero()
+	}
+	if t, ok := value.(PBTime); ok {
+		return time.Time(t).IsZero()
+	}
+	return false
+}
+
+// convertLike converts number value to type of like, so 2000 matches uint field.
+// ok is false if number is changed by conversion, managers report it by CheckValues.
+func convertLike(value, like any) (any, bool) {
+	v, l := reflect.ValueOf(value), reflect.ValueOf(like)
+	if !v.IsValid() || !l.IsValid() || v.Type() == l.Type() {
+		return value, true
+	}
+	if IsNumber(v.Kind()) && IsNumber(l.Kind()) {
+		converted, ok := ConvertExact(v, l.Type())
+		return converted.Interface(), ok
+	}
+	return value, true
+}
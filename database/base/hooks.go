@@ -0,0 +1,51 @@
+package base
+
+import (
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// HasSaveHooks reports whether model implements BeforeSaver or AfterSaver.
+func HasSaveHooks(model Model) bool {
+	_, before := model.(BeforeSaver)
+	_, after := model.(AfterSaver)
+	return before || after
+}
+
+// HasDeleteHooks reports whether model implements BeforeDeleter or AfterDeleter.
+func HasDeleteHooks(model Model) bool {
+	_, before := model.(BeforeDeleter)
+	_, after := model.(AfterDeleter)
+	return before || after
+}
+
+// BeforeSave calls hook of model if it is BeforeSaver.
+func BeforeSave(table Table, model Model) error {
+	if hook, ok := model.(BeforeSaver); ok {
+		return hook.BeforeSave(table)
+	}
+	return nil
+}
+
+// AfterSave calls hook of model if it is AfterSaver.
+func AfterSave(table Table, model Model) error {
+	if hook, ok := model.(AfterSaver); ok {
+		return hook.AfterSave(table)
+	}
+	return nil
+}
+
+// BeforeDelete calls hook of model if it is BeforeDeleter.
+func BeforeDelete(table Table, model Model) error {
+	if hook, ok := model.(BeforeDeleter); ok {
+		return hook.BeforeDelete(table)
+	}
+	return nil
+}
+
+// AfterDelete calls hook of model if it is AfterDeleter.
+func AfterDelete(table Table, model Model) error {
+	if hook, ok := model.(AfterDeleter); ok {
+		return hook.AfterDelete(table)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package base
+
+import (
+	"crypto/rand"
+)
+
+// idAlphabet and idLength are as of ids of pocketbase
+const idAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+const idLength = 15
+
+// NewStringId returns random id of models with string ids.
+func NewStringId() string {
+	buf := make([]byte, idLength)
+	rand.Read(buf)
+	for i := range buf {
+		buf[i] = idAlphabet[int(buf[i])%len(idAlphabet)]
+	}
+	return string(buf)
+}
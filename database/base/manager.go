@@ -1,7 +1,10 @@
 package base
 
 import (
+	"context"
+	"log"
 	"reflect"
+	"strings"
 	"sync"
 
 	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
@@ -9,10 +12,14 @@ import (
 	. "github.com/PoulIgorson/sub_engine_fiber/define"
 )
 
-var _ ManagerI = &Manager{}
+var _ ContextManager = &Manager{}
 
 type modelMap struct {
 	sync.Map
+
+	mu    sync.Mutex
+	minId any
+	maxId any
 }
 
 func (m *modelMap) Load(id any) Model {
@@ -37,23 +44,99 @@ func (m *modelMap) Range(fn func(id any, model Model) (continue_ bool)) {
 	})
 }
 
+// bounds returns minimal and maximal id, they are recalculated after deleting of bound.
+func (m *modelMap) bounds() (any, any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.minId == nil || m.maxId == nil {
+		m.Map.Range(func(id, _ any) bool {
+			m.compareAndSetMinMaxId(id)
+			return true
+		})
+	}
+	return m.minId, m.maxId
+}
+
+func (m *modelMap) compareAndSetMinMaxId(id any, setNil ...bool) {
+	if len(setNil) > 0 && setNil[0] {
+		if Compare(m.maxId, id) == 0 {
+			m.maxId = nil
+		}
+		if Compare(id, m.minId) == 0 {
+			m.minId = nil
+		}
+		return
+	}
+	if m.maxId == nil || Compare(m.maxId, id) == -1 {
+		m.maxId = id
+	}
+	if m.minId == nil || Compare(id, m.minId) == -1 {
+		m.minId = id
+	}
+}
+
 type Manager struct {
 	isInstance bool
 	table      Table
 
-	objects modelMap // map[uint]Model
-	minId   any
-	maxId   any
+	objects *modelMap // map[uint]Model, shared by copies of manager
+	copied  bool      // objects belong to manager this one is copied from
+	query   Query
 
 	UseCache bool
-	OnCount  func(ManagerI) uint
-	OnAll    func(ManagerI) []Model
-	OnFilter func(manager ManagerI, include Params, exclude ...Params) []Model
+	OnCount  func(ctx context.Context, manager ManagerI) (uint, error)
+	OnAll    func(ctx context.Context, manager ManagerI) ([]Model, error)
+	OnFilter func(ctx context.Context, manager ManagerI, q Q) ([]Model, error)
+	// OnAggregate aggregates models of Query().Q() grouped by fields,
+	// it is not called for paged managers.
+	OnAggregate func(ctx context.Context, manager ManagerI, groupBy []string, aggregations []Aggregation) ([]Group, error)
+	// OnIterate streams models of q in Query().Order until fn returns false.
+	OnIterate func(ctx context.Context, manager ManagerI, q Q, fn func(model Model) bool) error
+	// Preloads is true if OnAll and OnFilter load relations of Query().With themselves,
+	// their models are not preloaded again.
+	Preloads bool
+
+	// loadedWith is With of OnFilter which loaded stored models of instance manager,
+	// loaded is false if they were not loaded by OnFilter with Preloads.
+	loaded     bool
+	loadedWith string
 }
 
 func NewManager(table Table) *Manager {
+	manager := &Manager{
+		table:   table,
+		objects: &modelMap{},
+	}
+	if table != nil {
+		manager.query.SoftDelete = SoftDeleteField(table.Model())
+	}
+	return manager
+}
+
+// clone returns manager sharing objects with manager.
+func (manager *Manager) clone() *Manager {
+	newManager := *manager
+	newManager.query = manager.query.copy()
+	newManager.copied = true
+	return &newManager
+}
+
+// instance returns empty instance manager with hooks of manager.
+func (manager *Manager) instance() *Manager {
 	return &Manager{
-		table: table,
+		isInstance: true,
+		table:      manager.table,
+
+		objects: &modelMap{},
+
+		UseCache: manager.UseCache,
+		OnCount:  manager.OnCount,
+		OnAll:    manager.OnAll,
+		OnFilter: manager.OnFilter,
+
+		OnAggregate: manager.OnAggregate,
+		OnIterate:   manager.OnIterate,
+		Preloads:    manager.Preloads,
 	}
 }
 
@@ -65,34 +148,63 @@ func (manager *Manager) Table() Table {
 	return manager.table
 }
 
+// Query returns ordering and paging of manager.
+func (manager *Manager) Query() Query {
+	return manager.query.copy()
+}
+
+// Clear removes stored models. Copy of manager drops shared models
+// without removing them from manager it is copied from.
 func (manager *Manager) Clear() {
-	manager.objects = modelMap{}
-	manager.minId = nil
-	manager.maxId = nil
+	if manager.copied {
+		manager.objects = &modelMap{}
+		manager.copied = false
+		return
+	}
+	manager.objects.Map.Range(func(id, _ any) bool {
+		manager.objects.Map.Delete(id)
+		return true
+	})
+	manager.objects.mu.Lock()
+	manager.objects.minId = nil
+	manager.objects.maxId = nil
+	manager.objects.mu.Unlock()
 }
 
 func (manager *Manager) Copy() ManagerI {
-	return &Manager{
-		isInstance: true,
-		table:      manager.table,
+	newManager := manager.clone()
+	newManager.isInstance = true
+	return newManager
+}
 
-		objects: manager.objects,
-		minId:   manager.minId,
-		maxId:   manager.maxId,
+func (manager *Manager) OrderBy(fields ...string) ManagerI {
+	newManager := manager.clone()
+	newManager.query.Order = append([]string{}, fields...)
+	return newManager
+}
 
-		UseCache: manager.UseCache,
-		OnCount:  manager.OnCount,
-		OnAll:    manager.OnAll,
-		OnFilter: manager.OnFilter,
-	}
+func (manager *Manager) Limit(n uint) ManagerI {
+	newManager := manager.clone()
+	newManager.query.Limit = n
+	return newManager
+}
+
+func (manager *Manager) Offset(n uint) ManagerI {
+	newManager := manager.clone()
+	newManager.query.Offset = n
+	return newManager
 }
 
 func (manager *Manager) Get(id any) Model {
 	if manager.UseCache || manager.isInstance {
 		model := manager.objects.Load(id)
 		if model != nil {
+			if !manager.inScope(model) {
+				return nil
+			}
 			manager.Store(model.Id(), model)
 			manager.CheckPointers(model)
+			manager.preloadOne(model)
 			return model
 		}
 	}
@@ -104,6 +216,11 @@ func (manager *Manager) Get(id any) Model {
 	}
 
 	manager.Store(model.Id(), model)
+	if !manager.inScope(model) {
+		return nil
+	}
+	manager.CheckPointers(model)
+	manager.preloadOne(model)
 	return model
 }
 
@@ -117,30 +234,16 @@ func (manager *Manager) Store(id any, model Model) {
 		return
 	}
 	manager.objects.Store(id, model)
-	manager.compareAndSetMinMaxId(id)
-}
-
-func (manager *Manager) compareAndSetMinMaxId(id any, setNil ...bool) {
-	if len(setNil) > 0 && setNil[0] {
-		if Compare(manager.maxId, id) == 0 {
-			manager.maxId = nil
-		}
-		if Compare(id, manager.minId) == 0 {
-			manager.minId = nil
-		}
-		return
-	}
-	if manager.maxId == nil || Compare(manager.maxId, id) == -1 {
-		manager.maxId = id
-	}
-	if manager.minId == nil || Compare(id, manager.minId) == -1 {
-		manager.minId = id
-	}
+	manager.objects.mu.Lock()
+	manager.objects.compareAndSetMinMaxId(id)
+	manager.objects.mu.Unlock()
 }
 
 func (manager *Manager) ClearId(id any) {
 	manager.objects.Delete(id)
-	manager.compareAndSetMinMaxId(id, true)
+	manager.objects.mu.Lock()
+	manager.objects.compareAndSetMinMaxId(id, true)
+	manager.objects.mu.Unlock()
 }
 
 func (manager *Manager) Broadcast(next Nexter) {
@@ -156,92 +259,334 @@ func (manager *Manager) Broadcast(next Nexter) {
 }
 
 func (manager *Manager) CheckModel(model Model, include Params, exclude ...Params) bool {
+	return manager.CheckQ(model, FilterQ(include, exclude...))
+}
+
+// CheckQ reports if model satisfies q, conditions of unknown fields are skipped.
+func (manager *Manager) CheckQ(model Model, q Q) bool {
 	if model == nil {
 		return false
 	}
-	check := func(params Params, invert bool) bool {
-		for key, value := range params {
-			mvalue, err := Check(model, key[:len(key)-getOffset(key)])
-			if err != nil {
-				continue
-			}
+	// And is true until some condition fails, Or is false until some matches
+	result := !q.IsOr()
+	if len(q.Params) > 0 && manager.checkParams(model, q.Params) != result {
+		return result == q.Not
+	}
+	for _, child := range q.Children {
+		if manager.CheckQ(model, child) != result {
+			return result == q.Not
+		}
+	}
+	return result != q.Not
+}
 
-			r := Compare(mvalue.Interface(), value)
-			if checkKey(key, r) == invert {
+func (manager *Manager) checkParams(model Model, params Params) bool {
+	for key, value := range params {
+		field, op := SplitKey(key)
+		if name, _, ok := strings.Cut(field, "__"); ok {
+			if matched, known := manager.checkRelated(model, name, Params{key[len(name)+2:]: value}); known && !matched {
 				return false
 			}
+			continue
+		}
+		mvalue, err := Check(model, field)
+		if err != nil {
+			continue
+		}
+		current := mvalue.Interface()
+		if many, ok := current.(interface{ IDs() []any }); ok {
+			current = many.IDs()
+		}
+		if !match(current, op, value) {
+			return false
 		}
-		return true
 	}
+	return true
+}
 
-	return check(include, false) && (len(exclude) == 0 || check(exclude[0], true))
+// checkRelated reports if some related model of relation or many-to-many field `name`
+// of model satisfies params, known is false if model has not such field.
+func (manager *Manager) checkRelated(model Model, name string, params Params) (matched bool, known bool) {
+	var table Table
+	ids := []any{}
+	for _, relation := range ModelManyRelations(model) {
+		if relation.Field == name {
+			table = manager.table.DB().TableFromCache(relation.Table)
+			field, _ := Check(model, name)
+			ids = field.Interface().(interface{ IDs() []any }).IDs()
+		}
+	}
+	for _, relation := range ModelRelations(model) {
+		if relation.Pointer == name || relation.Field == name {
+			table = manager.table.DB().TableFromCache(relation.Table)
+			if field, _ := Check(model, relation.Field); !field.IsZero() {
+				ids = append(ids, field.Interface())
+			}
+		}
+	}
+	if table == nil {
+		return false, false
+	}
+	relatedManager, ok := table.Manager().(*Manager)
+	if !ok {
+		return false, false
+	}
+	for _, id := range ids {
+		if related := relatedManager.Get(id); related != nil && relatedManager.checkParams(related, params) {
+			return true, true
+		}
+	}
+	return false, true
 }
 
 func (manager *Manager) All() []Model {
+	objects, err := manager.AllContext(context.Background())
+	if err != nil {
+		log.Printf("base.Manager.All: %v\n", err)
+	}
+	return objects
+}
+
+func (manager *Manager) AllContext(ctx context.Context) ([]Model, error) {
+	var objects []Model
+	var err error
 	if manager.OnAll != nil {
-		return manager.OnAll(manager)
+		objects, err = manager.OnAll(ctx, manager)
+	} else {
+		objects, err = manager.Cached(ctx)
+	}
+	if err != nil {
+		return objects, err
+	}
+	if manager.withLoaded() {
+		return objects, resolveRelations(ctx, manager.table.DB(), objects, manager.query.With)
+	}
+	return objects, manager.preload(ctx, objects)
+}
+
+// withLoaded reports if relations of With of models of OnAll are loaded by backend.
+func (manager *Manager) withLoaded() bool {
+	if !manager.Preloads || manager.OnAll == nil {
+		return false
 	}
+	if !manager.isInstance {
+		return true
+	}
+	return manager.loaded && manager.loadedWith == strings.Join(manager.query.With, ",")
+}
+
+// Cached returns models stored in manager ordered and paged by query, bypassing OnAll.
+// Backends call it from OnAll for instance managers returned by Filter.
+func (manager *Manager) Cached(ctx context.Context) ([]Model, error) {
 	objects := []Model{}
+	var err error
 	manager.objects.Range(func(id any, model Model) bool {
+		if err = ctx.Err(); err != nil {
+			return false
+		}
+		if !manager.inScope(model) {
+			return true
+		}
 		manager.CheckPointers(model)
 		objects = append(objects, model)
 		return true
 	})
-	return objects
+	if err != nil {
+		return nil, err
+	}
+	manager.query.Sort(objects)
+	return manager.query.Page(objects), nil
 }
 
 func (manager *Manager) Filter(include Params, exclude ...Params) ManagerI {
-	newManager := &Manager{
-		isInstance: true,
-		table:      manager.table,
+	newManager, err := manager.FilterContext(context.Background(), include, exclude...)
+	if err != nil {
+		log.Printf("base.Manager.Filter: %v\n", err)
+	}
+	return newManager
+}
 
-		UseCache: manager.UseCache,
-		OnCount:  manager.OnCount,
-		OnAll:    manager.OnAll,
-		OnFilter: manager.OnFilter,
+func (manager *Manager) FilterContext(ctx context.Context, include Params, exclude ...Params) (ManagerI, error) {
+	return manager.WhereContext(ctx, FilterQ(include, exclude...))
+}
+
+func (manager *Manager) Where(q Q) ManagerI {
+	newManager, err := manager.WhereContext(context.Background(), q)
+	if err != nil {
+		log.Printf("base.Manager.Where: %v\n", err)
+	}
+	return newManager
+}
+
+func (manager *Manager) WhereContext(ctx context.Context, q Q) (ManagerI, error) {
+	newManager := manager.instance()
+	newManager.query = manager.query.copy()
+	newManager.query.Where = append(newManager.query.Where, q)
+	if err := CheckValues(manager.table.Model(), q); err != nil {
+		return newManager, err
 	}
 
 	if manager.OnFilter != nil {
-		models := manager.OnFilter(manager, include, exclude...)
+		// OnFilter pages models itself, result keeps ordering only
+		newManager.query.Limit, newManager.query.Offset = 0, 0
+		models, err := manager.OnFilter(ctx, manager, newManager.query.Q())
 		for _, model := range models {
 			newManager.Store(model.Id(), model)
 		}
-	} else {
-		manager.objects.Range(func(id any, model Model) bool {
-			manager.CheckPointers(model)
-			if manager.CheckModel(model, include, exclude...) {
-				newManager.Store(model.Id(), model)
-			}
+		if manager.Preloads {
+			newManager.loaded, newManager.loadedWith = true, strings.Join(manager.query.With, ",")
+		}
+		return newManager, err
+	}
+
+	return newManager, manager.filterCached(ctx, newManager, q)
+}
+
+// filterCached stores models of manager satisfying q in newManager.
+func (manager *Manager) filterCached(ctx context.Context, newManager *Manager, q Q) error {
+	models, err := manager.CachedQ(ctx, q)
+	for _, model := range models {
+		newManager.Store(model.Id(), model)
+	}
+	return err
+}
+
+// CachedQ returns models stored in manager satisfying q, bypassing OnFilter.
+func (manager *Manager) CachedQ(ctx context.Context, q Q) ([]Model, error) {
+	models := []Model{}
+	var err error
+	manager.objects.Range(func(id any, model Model) bool {
+		if err = ctx.Err(); err != nil {
+			return false
+		}
+		if !manager.inScope(model) {
 			return true
-		})
+		}
+		manager.CheckPointers(model)
+		if manager.CheckQ(model, q) {
+			models = append(models, model)
+		}
+		return true
+	})
+	return models, err
+}
+
+func (manager *Manager) Iterate(fn func(model Model) (continue_ bool), conditions ...Condition) error {
+	return manager.IterateContext(context.Background(), fn, conditions...)
+}
+
+// IterateContext streams models by OnIterate, paging of manager is applied to stream.
+// Instance managers returned by Filter iterate their stored models.
+// Pointers of relations of streamed models are not resolved, All resolves them.
+func (manager *Manager) IterateContext(ctx context.Context, fn func(model Model) (continue_ bool), conditions ...Condition) error {
+	q := And(conditions...)
+	if err := CheckValues(manager.table.Model(), q); err != nil {
+		return err
+	}
+	if manager.OnIterate == nil || (manager.isInstance && len(manager.query.Where) == 0) {
+		newManager := manager.instance()
+		newManager.query = manager.query.copy()
+		if err := manager.filterCached(ctx, newManager, q); err != nil {
+			return err
+		}
+		models, err := newManager.Cached(ctx)
+		if err != nil {
+			return err
+		}
+		for _, model := range models {
+			if !fn(model) {
+				break
+			}
+		}
+		return nil
 	}
 
-	return newManager
+	query := manager.query.copy()
+	query.Where = append(query.Where, q)
+	skip, left := query.Offset, query.Limit
+	return manager.OnIterate(ctx, manager, query.Q(), func(model Model) bool {
+		if skip > 0 {
+			skip--
+			return true
+		}
+		if !fn(model) {
+			return false
+		}
+		if query.Limit > 0 {
+			left--
+			return left > 0
+		}
+		return true
+	})
 }
 
 func (manager *Manager) First() Model {
-	if manager.minId == nil || !manager.UseCache && !manager.isInstance {
+	if _, scoped := manager.query.Scope(); scoped && len(manager.query.Order) == 0 {
+		return manager.scopedEdge(false)
+	}
+	if len(manager.query.Order) > 0 {
+		first := manager.clone()
+		first.query.Limit = 1
+		if objects := first.All(); len(objects) > 0 {
+			return objects[0]
+		}
 		return nil
 	}
-	model := manager.objects.Load(manager.minId)
+	if !manager.UseCache && !manager.isInstance {
+		return nil
+	}
+	minId, _ := manager.objects.bounds()
+	if minId == nil {
+		return nil
+	}
+	model := manager.objects.Load(minId)
 	manager.CheckPointers(model)
+	manager.preloadOne(model)
 	return model
 }
 
 func (manager *Manager) Last() Model {
-	if manager.maxId == nil || !manager.UseCache && !manager.isInstance {
+	if _, scoped := manager.query.Scope(); scoped && len(manager.query.Order) == 0 {
+		return manager.scopedEdge(true)
+	}
+	if len(manager.query.Order) > 0 {
+		last := manager.clone()
+		if last.query.Limit == 0 && last.query.Offset == 0 {
+			last.query.Order = last.query.Reversed()
+			last.query.Limit = 1
+		}
+		if objects := last.All(); len(objects) > 0 {
+			return objects[len(objects)-1]
+		}
+		return nil
+	}
+	if !manager.UseCache && !manager.isInstance {
 		return nil
 	}
-	model := manager.objects.Load(manager.maxId)
+	_, maxId := manager.objects.bounds()
+	if maxId == nil {
+		return nil
+	}
+	model := manager.objects.Load(maxId)
 	manager.CheckPointers(model)
+	manager.preloadOne(model)
 	return model
 }
 
 func (manager *Manager) Count() uint {
+	count, err := manager.CountContext(context.Background())
+	if err != nil {
+		log.Printf("base.Manager.Count: %v\n", err)
+	}
+	return count
+}
+
+func (manager *Manager) CountContext(ctx context.Context) (uint, error) {
 	if manager.OnCount != nil {
-		return manager.OnCount(manager)
+		return manager.OnCount(ctx, manager)
 	}
-	return uint(len(manager.All()))
+	objects, err := manager.AllContext(ctx)
+	return uint(len(objects)), err
 }
 
 func (manager *Manager) CheckPointers(model Model) {
@@ -253,10 +598,18 @@ func (manager *Manager) CheckPointers(model Model) {
 		return
 	}
 	modelT = modelT.Elem()
+	if modelT.Kind() != reflect.Struct {
+		return
+	}
+	BindManyRelations(manager.table, model)
+	pointers := map[string]bool{}
+	for _, relation := range ModelRelations(model) {
+		pointers[relation.Pointer] = true
+	}
 	modelV := reflect.ValueOf(model).Elem()
 	for i := 0; i < modelT.NumField(); i++ {
 		field := modelT.Field(i)
-		if field.Tag.Get("json") != "-" {
+		if field.Tag.Get("json") != "-" || pointers[field.Name] {
 			continue
 		}
 		if field.Type.Kind() == reflect.Pointer && field.IsExported() {
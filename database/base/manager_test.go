@@ -0,0 +1,46 @@
+package base_test
+
+import (
+	"testing"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/dbtest"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	"github.com/PoulIgorson/sub_engine_fiber/database/memory"
+)
+
+func TestClearOfCopy(t *testing.T) {
+	db := memory.New()
+	table, err := db.Table("test_item", &dbtest.TestItem{ID: uint(0)})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := table.Save(&dbtest.TestItem{ID: uint(0), Name: name}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	// copies share cached models of table, their Clear drops them for copy only
+	for name, copied := range map[string]ManagerI{
+		"Copy":        table.Manager().Copy(),
+		"OrderBy":     table.Manager().OrderBy("Name"),
+		"Limit":       table.Manager().Limit(1),
+		"WithDeleted": table.Manager().WithDeleted(),
+		"Filter":      table.Manager().Filter(nil),
+	} {
+		copied.Clear()
+		if count := len(table.Manager().All()); count != 2 {
+			t.Fatalf("Clear of copy of %v leaves %v cached models of table, expected 2", name, count)
+		}
+	}
+	copied := table.Manager().Copy()
+	copied.Clear()
+	if count := len(copied.All()); count != 0 {
+		t.Errorf("cleared copy returns %v models, expected 0", count)
+	}
+
+	table.Manager().Clear()
+	if count := len(table.Manager().All()); count != 0 {
+		t.Errorf("Clear of manager of table leaves %v cached models", count)
+	}
+}
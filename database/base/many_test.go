@@ -0,0 +1,134 @@
+package base_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	"github.com/PoulIgorson/sub_engine_fiber/database/memory"
+)
+
+type manyGroup struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func (group manyGroup) Id() any { return group.ID }
+func (manyGroup) Create(db DB, data string) Model {
+	group := &manyGroup{}
+	json.Unmarshal([]byte(data), group)
+	return group
+}
+func (group *manyGroup) Save(table Table) error { return table.Save(group) }
+func (group *manyGroup) Delete(db DB) error     { return db.TableFromCache("many_group").Delete(group.ID) }
+
+type manyUser struct {
+	ID     uint                   `json:"id"`
+	Name   string                 `json:"name"`
+	Groups ManyToMany[*manyGroup] `json:"groups"`
+}
+
+func (user manyUser) Id() any { return user.ID }
+func (manyUser) Create(db DB, data string) Model {
+	user := &manyUser{}
+	json.Unmarshal([]byte(data), user)
+	return user
+}
+func (user *manyUser) Save(table Table) error { return table.Save(user) }
+func (user *manyUser) Delete(db DB) error     { return db.TableFromCache("many_user").Delete(user.ID) }
+
+// groupNames returns names of groups of user.
+func groupNames(t *testing.T, user *manyUser) []string {
+	t.Helper()
+	groups, err := user.Groups.All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	names := []string{}
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	return names
+}
+
+func TestManyToMany(t *testing.T) {
+	tables := openTables(t, memory.New(), &manyGroup{}, &manyUser{})
+	groups, users := tables[0], tables[1]
+	a, b, c := &manyGroup{Name: "a"}, &manyGroup{Name: "b"}, &manyGroup{Name: "c"}
+	saveModels(t, groups, a, b, c)
+	user, other := &manyUser{Name: "u"}, &manyUser{Name: "o", Groups: NewManyToMany[*manyGroup](c.ID)}
+	saveModels(t, users, user, other)
+
+	steps := []struct {
+		name string
+		fn   func() error
+		want []string
+	}{
+		{"Add", func() error { return user.Groups.Add(a, b, a) }, []string{"a", "b"}},
+		{"Remove", func() error { return user.Groups.Remove(a) }, []string{"b"}},
+		{"Set", func() error { return user.Groups.Set(c, a) }, []string{"c", "a"}},
+		{"Clear", func() error { return user.Groups.Clear() }, []string{}},
+	}
+	for _, step := range steps {
+		if err := step.fn(); err != nil {
+			t.Fatalf("%v: %v", step.name, err)
+		}
+		if names := groupNames(t, user); !reflect.DeepEqual(names, step.want) {
+			t.Errorf("%v: groups are %v, expected %v", step.name, names, step.want)
+		}
+		// field saves model at once
+		loaded := users.Manager().Get(user.ID).(*manyUser)
+		if names := groupNames(t, loaded); !reflect.DeepEqual(names, step.want) {
+			t.Errorf("%v: groups of loaded user are %v, expected %v", step.name, names, step.want)
+		}
+	}
+
+	if err := user.Groups.Set(a, b); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	tests := []struct {
+		params Params
+		want   int
+	}{
+		{Params{"Groups__Name": "a"}, 1},
+		{Params{"Groups__Name": "c"}, 1},
+		{Params{"Groups__Name__in": []string{"b", "c"}}, 2},
+		{Params{"Groups__Name": "z"}, 0},
+		{Params{"Groups__contains": b.ID}, 1},
+	}
+	for _, test := range tests {
+		if count := users.Manager().Filter(test.params).Count(); count != uint(test.want) {
+			t.Errorf("Filter(%v) returns %v models, expected %v", test.params, count, test.want)
+		}
+	}
+
+	// deleted group is removed from fields
+	if err := groups.Delete(a.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	loaded := users.Manager().Get(user.ID).(*manyUser)
+	if ids := loaded.Groups.IDs(); !reflect.DeepEqual(ids, []any{b.ID}) {
+		t.Errorf("ids of groups after delete of group are %v, expected [%v]", ids, b.ID)
+	}
+}
+
+func TestManyToManyUnbound(t *testing.T) {
+	user := &manyUser{}
+	if err := user.Groups.Add(&manyGroup{ID: 1}); err == nil {
+		t.Errorf("Add of unbound field returns no error")
+	}
+	if len(user.Groups.IDs()) != 0 {
+		t.Errorf("failed Add changes ids: %v", user.Groups.IDs())
+	}
+
+	user.Groups = NewManyToMany[*manyGroup](uint(1), uint(2))
+	data, err := json.Marshal(user)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	loaded := manyUser{}.Create(nil, string(data)).(*manyUser)
+	if ids := loaded.Groups.IDs(); !reflect.DeepEqual(ids, []any{uint(1), uint(2)}) {
+		t.Errorf("ids after json are %v, expected [1 2]", ids)
+	}
+}
@@ -0,0 +1,213 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+func (manager *Manager) With(relations ...string) ManagerI {
+	newManager := manager.clone()
+	newManager.query.With = append(newManager.query.With, relations...)
+	return newManager
+}
+
+// preload loads relations of With of models, by Expander of table if it implements it,
+// pointers of other relations are resolved for all models at once.
+func (manager *Manager) preload(ctx context.Context, models []Model) error {
+	if len(models) == 0 {
+		return nil
+	}
+	if len(manager.query.With) > 0 {
+		var err error
+		if expander, ok := manager.table.(Expander); ok {
+			err = expander.Expand(ctx, models, manager.query.With)
+		} else {
+			err = Preload(ctx, manager.table.DB(), models, manager.query.With)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return resolveRelations(ctx, manager.table.DB(), models, manager.query.With)
+}
+
+// preloadOne is preload of one model logging error.
+func (manager *Manager) preloadOne(model Model) {
+	if model == nil {
+		return
+	}
+	if err := manager.preload(context.Background(), []Model{model}); err != nil {
+		log.Printf("base.Manager.With: %v\n", err)
+	}
+}
+
+// Preload sets pointers of relations of models to related models of tables of db,
+// related models of each relation are taken by one GetManyContext of ManyGetter table.
+func Preload(ctx context.Context, db DB, models []Model, with []string) error {
+	if len(models) == 0 || len(with) == 0 {
+		return nil
+	}
+	names, rest := splitWith(with)
+	for _, name := range names {
+		relation, err := relationOf(models[0], name)
+		if err != nil {
+			return err
+		}
+		table := db.TableFromCache(relation.Table)
+		if table == nil {
+			return NewErrorf("with: table `%v` of `%v` is not opened", relation.Table, name)
+		}
+
+		ids := []any{}
+		seen := map[string]bool{}
+		for _, model := range models {
+			id := reflect.ValueOf(model).Elem().FieldByName(relation.Field)
+			if key := fmt.Sprint(id.Interface()); !id.IsZero() && !seen[key] {
+				seen[key] = true
+				ids = append(ids, id.Interface())
+			}
+		}
+		related, err := getMany(ctx, table, ids)
+		if err != nil {
+			return err
+		}
+		byId := map[string]Model{}
+		for _, model := range related {
+			byId[fmt.Sprint(model.Id())] = model
+		}
+		for _, model := range models {
+			modelV := reflect.ValueOf(model).Elem()
+			setPointer(modelV.FieldByName(relation.Pointer), byId[fmt.Sprint(modelV.FieldByName(relation.Field).Interface())])
+		}
+
+		if err := Preload(ctx, db, related, rest[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getMany returns models of ids by ManyGetter table or from cache of manager and table.
+func getMany(ctx context.Context, table Table, ids []any) ([]Model, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if getter, ok := table.(ManyGetter); ok {
+		return getter.GetManyContext(ctx, ids)
+	}
+	models := []Model{}
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if model := table.Manager().Get(id); model != nil {
+			models = append(models, model)
+		}
+	}
+	return models, nil
+}
+
+// ExpandPaths returns pocketbase expand of relations of model:
+// json names of relation fields joined by ".".
+func ExpandPaths(db DB, model Model, with []string) ([]string, error) {
+	paths := []string{}
+	for _, path := range with {
+		current := model
+		names := []string{}
+		for _, name := range strings.Split(path, ".") {
+			relation, err := relationOf(current, name)
+			if err != nil {
+				return nil, err
+			}
+			table := db.TableFromCache(relation.Table)
+			if table == nil {
+				return nil, NewErrorf("with: table `%v` of `%v` is not opened", relation.Table, name)
+			}
+			names = append(names, relation.Name)
+			current = table.Model()
+		}
+		paths = append(paths, strings.Join(names, "."))
+	}
+	return paths, nil
+}
+
+// SetExpanded sets pointers of relations of model from expand of pocketbase record,
+// expand contains expanded records by json names of relation fields.
+func SetExpanded(db DB, model Model, expand map[string]any, with []string) error {
+	names, rest := splitWith(with)
+	for _, name := range names {
+		relation, err := relationOf(model, name)
+		if err != nil {
+			return err
+		}
+		table := db.TableFromCache(relation.Table)
+		if table == nil {
+			return NewErrorf("with: table `%v` of `%v` is not opened", relation.Table, name)
+		}
+		pointer := reflect.ValueOf(model).Elem().FieldByName(relation.Pointer)
+		data, ok := expand[relation.Name].(map[string]any)
+		if !ok {
+			pointer.SetZero()
+			continue
+		}
+		MapAutoFields(table.Model(), data)
+		dataB, err := json.Marshal(data)
+		if err != nil {
+			return NewErrorf("with: %v", err)
+		}
+		related := table.Model().Create(db, string(dataB))
+		setPointer(pointer, related)
+		if len(rest[name]) > 0 {
+			subexpand, _ := data["expand"].(map[string]any)
+			if err := SetExpanded(db, related, subexpand, rest[name]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// splitWith groups relations by first name, "Owner.Company" is {"Owner": ["Company"]}.
+func splitWith(with []string) ([]string, map[string][]string) {
+	names := []string{}
+	rest := map[string][]string{}
+	for _, path := range with {
+		name, tail, _ := strings.Cut(path, ".")
+		if _, ok := rest[name]; !ok {
+			names = append(names, name)
+			rest[name] = []string{}
+		}
+		if tail != "" {
+			rest[name] = append(rest[name], tail)
+		}
+	}
+	return names, rest
+}
+
+// relationOf returns relation of model resolved to pointer field `pointer`.
+func relationOf(model Model, pointer string) (Relation, error) {
+	for _, relation := range ModelRelations(model) {
+		if relation.Pointer == pointer {
+			return relation, nil
+		}
+	}
+	return Relation{}, NewErrorf("with: `%v` is not relation of `%v`", pointer, GetNameModel(model))
+}
+
+// setPointer sets pointer to related model or nil.
+func setPointer(pointer reflect.Value, related Model) {
+	relatedV := reflect.ValueOf(related)
+	if related == nil || !relatedV.Type().AssignableTo(pointer.Type()) {
+		pointer.SetZero()
+		return
+	}
+	pointer.Set(relatedV)
+}
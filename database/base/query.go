@@ -0,0 +1,119 @@
+package base
+
+import (
+	"sort"
+	"strings"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// Query is ordering and paging of manager, it is applied when models are taken.
+type Query struct {
+	// Order contains names of fields, "-" prefix means descending order.
+	Order  []string
+	Limit  uint
+	Offset uint
+	// Where contains conditions of Filter and Where calls made on manager.
+	Where []Q
+	// With contains relations loaded in bulk.
+	With []string
+	// SoftDelete is name of field `deleted_at` of soft deleted models,
+	// Deleted selects them by it.
+	SoftDelete string
+	Deleted    Deleted
+}
+
+// Deleted selects soft deleted models of manager.
+type Deleted uint8
+
+const (
+	DeletedExcluded Deleted = iota // default
+	DeletedIncluded
+	DeletedOnly
+)
+
+func (query Query) copy() Query {
+	query.Order = append([]string{}, query.Order...)
+	query.Where = append([]Q{}, query.Where...)
+	query.With = append([]string{}, query.With...)
+	return query
+}
+
+// Q returns conditions of Where and Scope joined by And.
+func (query Query) Q() Q {
+	conditions := make([]Condition, len(query.Where))
+	for i, q := range query.Where {
+		conditions[i] = q
+	}
+	if scope, ok := query.Scope(); ok {
+		conditions = append(conditions, scope)
+	}
+	return And(conditions...)
+}
+
+// Scope returns condition selecting soft deleted models by Deleted,
+// ok is false if all models are selected.
+func (query Query) Scope() (scope Q, ok bool) {
+	if query.SoftDelete == "" {
+		return Q{}, false
+	}
+	switch query.Deleted {
+	case DeletedExcluded:
+		return And(Params{query.SoftDelete + "__isnull": true}), true
+	case DeletedOnly:
+		return And(Params{query.SoftDelete + "__isnull": false}), true
+	}
+	return Q{}, false
+}
+
+// Reversed returns Order with inverted directions.
+func (query Query) Reversed() []string {
+	order := make([]string, len(query.Order))
+	for i, field := range query.Order {
+		if strings.HasPrefix(field, "-") {
+			order[i] = field[1:]
+		} else {
+			order[i] = "-" + field
+		}
+	}
+	return order
+}
+
+// Sort sorts models by Order, models of equal values are sorted by id.
+func (query Query) Sort(models []Model) {
+	sort.SliceStable(models, func(i, j int) bool {
+		return query.Less(models[i], models[j])
+	})
+}
+
+// Less reports if model a precedes model b by Order, models of equal values are ordered by id.
+func (query Query) Less(a, b Model) bool {
+	for _, field := range query.Order {
+		desc := strings.HasPrefix(field, "-")
+		field = strings.TrimPrefix(field, "-")
+		valueA, errA := Check(a, field)
+		valueB, errB := Check(b, field)
+		if errA != nil || errB != nil {
+			continue
+		}
+		r := Compare(valueA.Interface(), valueB.Interface())
+		if r == 0 || r == -2 {
+			continue
+		}
+		return (r == -1) != desc
+	}
+	return Compare(a.Id(), b.Id()) == -1
+}
+
+// Page returns models in range of Offset and Limit.
+func (query Query) Page(models []Model) []Model {
+	if query.Offset >= uint(len(models)) {
+		return []Model{}
+	}
+	models = models[query.Offset:]
+	if query.Limit > 0 && query.Limit < uint(len(models)) {
+		models = models[:query.Limit]
+	}
+	return models
+}
@@ -0,0 +1,208 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// Reference is relation of models of table Table,
+// Many is true for many-to-many field.
+type Reference struct {
+	Table string
+	Relation
+	Many bool
+}
+
+// Relations is registry of relations between tables of db,
+// tables register relations of their models on open.
+type Relations struct {
+	mu         sync.RWMutex
+	references map[string][]Reference // by name of related table
+}
+
+// Register replaces relations of table `name` by relations of model.
+func (relations *Relations) Register(name string, model Model) {
+	relations.mu.Lock()
+	defer relations.mu.Unlock()
+	if relations.references == nil {
+		relations.references = map[string][]Reference{}
+	}
+	for related, references := range relations.references {
+		kept := references[:0]
+		for _, reference := range references {
+			if reference.Table != name {
+				kept = append(kept, reference)
+			}
+		}
+		relations.references[related] = kept
+	}
+	for _, relation := range ModelRelations(model) {
+		relations.references[relation.Table] = append(relations.references[relation.Table], Reference{name, relation, false})
+	}
+	for _, many := range ModelManyRelations(model) {
+		relation := Relation{Field: many.Field, Name: many.Name, Table: many.Table}
+		relations.references[many.Table] = append(relations.references[many.Table], Reference{name, relation, true})
+	}
+}
+
+// To returns relations referencing table `name`.
+func (relations *Relations) To(name string) []Reference {
+	relations.mu.RLock()
+	defer relations.mu.RUnlock()
+	return append([]Reference{}, relations.references[name]...)
+}
+
+// OnDelete applies relations referencing model `id` of table `name` before its delete:
+// restrict returns ErrRestrict, cascade deletes referencing models and setnull clears their relation field,
+// id is removed from many-to-many fields. Soft deleted models are referencing too,
+// cascade removes them by HardDelete.
+// Tables are taken from db, it should be transaction to roll back changes on error.
+func (relations *Relations) OnDelete(db DB, name string, id any) error {
+	type referencing struct {
+		Reference
+		table  Table
+		models []Model
+	}
+	found := []referencing{}
+	for _, reference := range relations.To(name) {
+		table := db.TableFromCache(reference.Table)
+		if table == nil {
+			continue
+		}
+		key := reference.Field
+		if reference.Many {
+			key += "__contains"
+		}
+		models := table.Manager().WithDeleted().Filter(Params{key: id}).All()
+		if len(models) == 0 {
+			continue
+		}
+		if reference.OnDelete == OnDeleteRestrict {
+			return NewErrRestrict(name, reference.Table)
+		}
+		found = append(found, referencing{reference, table, models})
+	}
+
+	for _, referencing := range found {
+		for _, model := range referencing.models {
+			if referencing.Many {
+				many := reflect.ValueOf(model).Elem().FieldByName(referencing.Field).Addr().Interface().(ManyToManyField)
+				ids := []any{}
+				for _, related := range many.IDs() {
+					if fmt.Sprint(related) != fmt.Sprint(id) {
+						ids = append(ids, related)
+					}
+				}
+				many.SetIDs(ids)
+				if err := referencing.table.Save(model); err != nil {
+					return err
+				}
+				continue
+			}
+			if referencing.OnDelete == OnDeleteCascade {
+				remove := referencing.table.Delete
+				if isSoftDeleted(model) {
+					remove = referencing.table.HardDelete
+				}
+				if err := remove(model.Id()); err != nil {
+					return err
+				}
+				continue
+			}
+			field := reflect.ValueOf(model).Elem().FieldByName(referencing.Field)
+			field.SetZero()
+			if referencing.Pointer != "" {
+				reflect.ValueOf(model).Elem().FieldByName(referencing.Pointer).SetZero()
+			}
+			if err := referencing.table.Save(model); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveRelations sets pointers of relations of models to related models of tables of db,
+// relations of with are skipped, they are loaded by preload.
+// Related models are not resolved, they are taken from cache of manager of table,
+// missing ones are taken by one getMany of each relation.
+func resolveRelations(ctx context.Context, db DB, models []Model, with []string) error {
+	if len(models) == 0 {
+		return nil
+	}
+	_, preloaded := splitWith(with)
+	for _, relation := range ModelRelations(models[0]) {
+		if _, ok := preloaded[relation.Pointer]; ok || relation.Pointer == "" {
+			continue
+		}
+		table := db.TableFromCache(relation.Table)
+		if table == nil {
+			continue
+		}
+		manager, _ := table.Manager().(*Manager)
+
+		byId := map[string]Model{}
+		ids := []any{}
+		for _, model := range models {
+			id := reflect.ValueOf(model).Elem().FieldByName(relation.Field)
+			key := fmt.Sprint(id.Interface())
+			if _, ok := byId[key]; ok || id.IsZero() {
+				continue
+			}
+			byId[key] = nil
+			if manager != nil {
+				if related := manager.objects.Load(id.Interface()); related != nil {
+					byId[key] = related
+					continue
+				}
+			}
+			ids = append(ids, id.Interface())
+		}
+		related, err := getMany(ctx, table, ids)
+		if err != nil {
+			return err
+		}
+		for _, model := range related {
+			byId[fmt.Sprint(model.Id())] = model
+		}
+		for _, model := range models {
+			modelV := reflect.ValueOf(model).Elem()
+			setPointer(modelV.FieldByName(relation.Pointer), byId[fmt.Sprint(modelV.FieldByName(relation.Field).Interface())])
+		}
+	}
+	return nil
+}
+
+// fieldJoin saves ids of many-to-many field by saving its model.
+type fieldJoin struct {
+	table   Table
+	model   Model
+	related string
+}
+
+func (join fieldJoin) Save(ids []any) error {
+	return join.table.Save(join.model)
+}
+
+func (join fieldJoin) Table() Table {
+	return join.table.DB().TableFromCache(join.related)
+}
+
+// BindManyRelations binds many-to-many fields of model to table,
+// backends call it for saved models, loaded models are bound by manager.
+func BindManyRelations(table Table, model Model) {
+	modelV := reflect.ValueOf(model)
+	if modelV.Kind() != reflect.Pointer || modelV.Elem().Kind() != reflect.Struct {
+		return
+	}
+	for _, relation := range ModelManyRelations(model) {
+		many := modelV.Elem().FieldByName(relation.Field).Addr().Interface().(ManyToManyField)
+		many.Bind(fieldJoin{table, model, relation.Table})
+	}
+}
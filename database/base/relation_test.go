@@ -0,0 +1,222 @@
+package base_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	"github.com/PoulIgorson/sub_engine_fiber/database/memory"
+)
+
+type relOwner struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func (owner relOwner) Id() any { return owner.ID }
+func (relOwner) Create(db DB, data string) Model {
+	owner := &relOwner{}
+	json.Unmarshal([]byte(data), owner)
+	return owner
+}
+func (owner *relOwner) Save(table Table) error { return table.Save(owner) }
+func (owner *relOwner) Delete(db DB) error     { return db.TableFromCache("rel_owner").Delete(owner.ID) }
+
+// relCar is deleted with its owner, it is soft deleted.
+type relCar struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	OwnerID   uint      `json:"owner_id" relation:"rel_owner" ondelete:"cascade"`
+	Owner     *relOwner `json:"-"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (car relCar) Id() any { return car.ID }
+func (relCar) Create(db DB, data string) Model {
+	car := &relCar{}
+	json.Unmarshal([]byte(data), car)
+	return car
+}
+func (car *relCar) Save(table Table) error { return table.Save(car) }
+func (car *relCar) Delete(db DB) error     { return db.TableFromCache("rel_car").Delete(car.ID) }
+
+// relNote loses its owner on delete of owner.
+type relNote struct {
+	ID      uint   `json:"id"`
+	Text    string `json:"text"`
+	OwnerID uint   `json:"owner_id" relation:"rel_owner"`
+}
+
+func (note relNote) Id() any { return note.ID }
+func (relNote) Create(db DB, data string) Model {
+	note := &relNote{}
+	json.Unmarshal([]byte(data), note)
+	return note
+}
+func (note *relNote) Save(table Table) error { return table.Save(note) }
+func (note *relNote) Delete(db DB) error     { return db.TableFromCache("rel_note").Delete(note.ID) }
+
+// relLock forbids delete of its owner, it is soft deleted.
+type relLock struct {
+	ID        uint      `json:"id"`
+	OwnerID   uint      `json:"owner_id" relation:"rel_owner" ondelete:"restrict"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (lock relLock) Id() any { return lock.ID }
+func (relLock) Create(db DB, data string) Model {
+	lock := &relLock{}
+	json.Unmarshal([]byte(data), lock)
+	return lock
+}
+func (lock *relLock) Save(table Table) error { return table.Save(lock) }
+func (lock *relLock) Delete(db DB) error     { return db.TableFromCache("rel_lock").Delete(lock.ID) }
+
+// openTables returns tables of models of db, it fails t on error.
+func openTables(t *testing.T, db DB, models ...Model) []Table {
+	t.Helper()
+	tables := make([]Table, len(models))
+	for i, model := range models {
+		table, err := db.Table("", model)
+		if err != nil {
+			t.Fatalf("Table: %v", err)
+		}
+		tables[i] = table
+	}
+	return tables
+}
+
+// saveModels saves models to table, it fails t on error.
+func saveModels(t *testing.T, table Table, models ...Model) {
+	t.Helper()
+	for _, model := range models {
+		if err := table.Save(model); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+}
+
+func TestRelationPointer(t *testing.T) {
+	tables := openTables(t, memory.New(), &relOwner{}, &relCar{})
+	owner := &relOwner{Name: "a"}
+	saveModels(t, tables[0], owner)
+	car := &relCar{Name: "x", OwnerID: owner.ID}
+	saveModels(t, tables[1], car)
+
+	loaded := tables[1].Manager().Get(car.ID).(*relCar)
+	if loaded.Owner == nil || loaded.Owner.Name != "a" {
+		t.Fatalf("pointer of relation is %v, expected owner a", loaded.Owner)
+	}
+	if got := tables[1].Manager().Filter(Params{"Owner__Name": "a"}).Count(); got != 1 {
+		t.Fatalf("Filter by field of related model returns %v models, expected 1", got)
+	}
+}
+
+func TestRelationsOnDelete(t *testing.T) {
+	tables := openTables(t, memory.New(), &relOwner{}, &relCar{}, &relNote{}, &relLock{})
+	owners, cars, notes, locks := tables[0], tables[1], tables[2], tables[3]
+	a, b := &relOwner{Name: "a"}, &relOwner{Name: "b"}
+	saveModels(t, owners, a, b)
+	alive, deleted, other := &relCar{Name: "x", OwnerID: a.ID}, &relCar{Name: "y", OwnerID: a.ID}, &relCar{Name: "z", OwnerID: b.ID}
+	saveModels(t, cars, alive, deleted, other)
+	if err := cars.Delete(deleted.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	note := &relNote{Text: "n", OwnerID: a.ID}
+	saveModels(t, notes, note)
+
+	// soft deleted lock restricts delete too
+	lock := &relLock{OwnerID: a.ID}
+	saveModels(t, locks, lock)
+	if err := locks.Delete(lock.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := owners.Delete(a.ID); err == nil {
+		t.Fatalf("Delete of restricted owner returns no error")
+	} else if _, ok := err.(ErrRestrict); !ok {
+		t.Fatalf("Delete of restricted owner returns %T: %v, expected ErrRestrict", err, err)
+	}
+	if owners.Count() != 2 || cars.Count() != 3 {
+		t.Fatalf("failed Delete changes models")
+	}
+
+	if err := locks.HardDelete(lock.ID); err != nil {
+		t.Fatalf("HardDelete: %v", err)
+	}
+	if err := owners.Delete(a.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if model, _ := cars.Get(alive.ID); model == nil || model.(*relCar).DeletedAt.IsZero() {
+		t.Errorf("car of deleted owner is not deleted: %v", model)
+	}
+	// soft deleted car references owner too
+	if model, _ := cars.Get(deleted.ID); model != nil {
+		t.Errorf("soft deleted car of deleted owner is kept: %v", model)
+	}
+	if model, _ := cars.Get(other.ID); model == nil || !model.(*relCar).DeletedAt.IsZero() {
+		t.Errorf("car of other owner is changed: %v", model)
+	}
+	if model, _ := notes.Get(note.ID); model == nil || model.(*relNote).OwnerID != 0 {
+		t.Errorf("owner of note is not cleared: %v", model)
+	}
+}
+
+// countOwner counts its loads by Create.
+type countOwner struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+var countOwnerLoads int
+
+func (owner countOwner) Id() any { return owner.ID }
+func (countOwner) Create(db DB, data string) Model {
+	countOwnerLoads++
+	owner := &countOwner{}
+	json.Unmarshal([]byte(data), owner)
+	return owner
+}
+func (owner *countOwner) Save(table Table) error { return table.Save(owner) }
+func (owner *countOwner) Delete(db DB) error     { return nil }
+
+type countCar struct {
+	ID      uint        `json:"id"`
+	OwnerID uint        `json:"owner_id" relation:"count_owner"`
+	Owner   *countOwner `json:"-"`
+}
+
+func (car countCar) Id() any { return car.ID }
+func (countCar) Create(db DB, data string) Model {
+	car := &countCar{}
+	json.Unmarshal([]byte(data), car)
+	return car
+}
+func (car *countCar) Save(table Table) error { return table.Save(car) }
+func (car *countCar) Delete(db DB) error     { return nil }
+
+func TestRelationsResolvedOnce(t *testing.T) {
+	tables := openTables(t, memory.New(), &countOwner{}, &countCar{})
+	owners, cars := tables[0], tables[1]
+	owner := &countOwner{Name: "a"}
+	saveModels(t, owners, owner)
+	for i := 0; i < 3; i++ {
+		saveModels(t, cars, &countCar{OwnerID: owner.ID})
+	}
+
+	owners.Manager().Clear()
+	countOwnerLoads = 0
+	models := cars.Manager().All()
+	if len(models) != 3 {
+		t.Fatalf("All returns %v models, expected 3", len(models))
+	}
+	for _, model := range models {
+		if car := model.(*countCar); car.Owner == nil || car.Owner.Name != "a" {
+			t.Fatalf("pointer of relation is %v, expected owner a", car.Owner)
+		}
+	}
+	if countOwnerLoads != 1 {
+		t.Fatalf("owner is loaded %v times for 3 cars, expected 1", countOwnerLoads)
+	}
+}
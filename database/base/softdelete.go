@@ -0,0 +1,147 @@
+package base
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// WithDeleted returns copy of manager selecting soft deleted models too.
+func (manager *Manager) WithDeleted() ManagerI {
+	newManager := manager.clone()
+	newManager.query.Deleted = DeletedIncluded
+	return newManager
+}
+
+// OnlyDeleted returns copy of manager selecting soft deleted models only.
+func (manager *Manager) OnlyDeleted() ManagerI {
+	newManager := manager.clone()
+	newManager.query.Deleted = DeletedOnly
+	return newManager
+}
+
+// inScope reports if model is selected by Query().Scope().
+func (manager *Manager) inScope(model Model) bool {
+	scope, ok := manager.query.Scope()
+	return !ok || manager.CheckQ(model, scope)
+}
+
+// scopedEdge returns model of minimal or maximal id selected by Query().Scope(),
+// bounds of stored models may be soft deleted.
+func (manager *Manager) scopedEdge(last bool) Model {
+	if !manager.UseCache && !manager.isInstance {
+		return nil
+	}
+	objects := manager.All()
+	if len(objects) == 0 {
+		return nil
+	}
+	if last {
+		return objects[len(objects)-1]
+	}
+	return objects[0]
+}
+
+// SoftDelete sets field `deleted_at` of model of id to current time in one transaction
+// with hooks of Delete, relations of model are kept. Missing or deleted model is skipped.
+// DB without transactions changes model by direct Get and Save.
+func SoftDelete(table Table, id any) error {
+	return SoftDeleteContext(context.Background(), table, id)
+}
+
+// SoftDeleteContext is SoftDelete whose Get and Save of ContextTable are cancelled through ctx.
+func SoftDeleteContext(ctx context.Context, table Table, id any) error {
+	called := false
+	err := table.DB().Tx(func(tx DB) error {
+		called = true
+		return softDelete(ctx, tx.TableFromCache(table.Name()), id)
+	})
+	if _, ok := err.(ErrNotSupported); ok && !called {
+		return softDelete(ctx, table, id)
+	}
+	return err
+}
+
+// softDelete sets field `deleted_at` of model of id with hooks of Delete.
+func softDelete(ctx context.Context, table Table, id any) error {
+	model, err := getContext(ctx, table, id)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil || model == nil {
+		return nil
+	}
+	field, err := Check(model, SoftDeleteField(model))
+	if err != nil {
+		return err
+	}
+	if !field.IsZero() {
+		return nil
+	}
+	if err := BeforeDelete(table, model); err != nil {
+		return err
+	}
+	field.Set(reflect.ValueOf(time.Now().UTC()).Convert(field.Type()))
+	if err := saveContext(ctx, table, model); err != nil {
+		return err
+	}
+	return AfterDelete(table, model)
+}
+
+// getContext returns model of id by GetContext of ContextTable or by Get.
+func getContext(ctx context.Context, table Table, id any) (Model, error) {
+	if contextTable, ok := table.(ContextTable); ok {
+		return contextTable.GetContext(ctx, id)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return table.Get(id)
+}
+
+// saveContext saves model by SaveContext of ContextTable or by Save.
+func saveContext(ctx context.Context, table Table, model Model) error {
+	if contextTable, ok := table.(ContextTable); ok {
+		return contextTable.SaveContext(ctx, model)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return table.Save(model)
+}
+
+// isSoftDeleted reports if field `deleted_at` of model is set.
+func isSoftDeleted(model Model) bool {
+	name := SoftDeleteField(model)
+	if name == "" {
+		return false
+	}
+	field, err := Check(model, name)
+	return err == nil && !field.IsZero()
+}
+
+// Restore clears field `deleted_at` of soft deleted model of id.
+func Restore(table Table, id any) error {
+	name := SoftDeleteField(table.Model())
+	if name == "" {
+		return NewErrNotSupported("restore of model without field `" + DeletedAt + "`")
+	}
+	model, err := table.Get(id)
+	if err != nil {
+		return err
+	}
+	field, err := Check(model, name)
+	if err != nil {
+		return err
+	}
+	if field.IsZero() {
+		return nil
+	}
+	field.SetZero()
+	return table.Save(model)
+}
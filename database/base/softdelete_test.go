@@ -0,0 +1,64 @@
+package base_test
+
+import (
+	"testing"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	"github.com/PoulIgorson/sub_engine_fiber/database/dbtest"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	"github.com/PoulIgorson/sub_engine_fiber/database/memory"
+)
+
+// noTxDB is DB without transactions as remote pocketbase.
+type noTxDB struct {
+	DB
+}
+
+func (db noTxDB) Tx(fn func(tx DB) error) error {
+	return NewErrNotSupported("transactions")
+}
+
+// noTxTable is table of noTxDB.
+type noTxTable struct {
+	Table
+	db noTxDB
+}
+
+func (table noTxTable) DB() DB {
+	return table.db
+}
+
+func TestSoftDeleteWithoutTx(t *testing.T) {
+	db := memory.New()
+	memTable, err := db.Table("test_soft_item", &dbtest.SoftItem{ID: uint(0)})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	table := noTxTable{memTable, noTxDB{db}}
+	item := &dbtest.SoftItem{ID: uint(0), Name: "a"}
+	if err := table.Save(item); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if err := base.SoftDelete(table, item.ID); err != nil {
+		t.Fatalf("SoftDelete: %v", err)
+	}
+	model, err := table.Get(item.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if model.(*dbtest.SoftItem).DeletedAt.IsZero() {
+		t.Fatalf("SoftDelete does not set deleted_at")
+	}
+	if count := table.Manager().Count(); count != 0 {
+		t.Fatalf("Manager.Count after SoftDelete is %v, expected 0", count)
+	}
+
+	if err := base.Restore(table, item.ID); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if count := table.Manager().Count(); count != 1 {
+		t.Fatalf("Manager.Count after Restore is %v, expected 1", count)
+	}
+}
@@ -0,0 +1,50 @@
+package base
+
+import (
+	"context"
+	"reflect"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// ModelVersion returns value of field `version` of model, 0 for model without it.
+func ModelVersion(model Model) uint64 {
+	field, err := Check(model, VersionField(model))
+	if err != nil {
+		return 0
+	}
+	if field.CanUint() {
+		return field.Uint()
+	}
+	return uint64(field.Int())
+}
+
+// SetVersion sets field `version` of model, it is used to undo failed save.
+func SetVersion(model Model, version uint64) {
+	field, err := Check(model, VersionField(model))
+	if err != nil {
+		return
+	}
+	field.Set(reflect.ValueOf(version).Convert(field.Type()))
+}
+
+// NextVersion increments field `version` of model before save,
+// version of model must be equal to stored version of existing model.
+// Models without field `version` are not checked, restored models of ctx keep their version.
+func NextVersion(ctx context.Context, table string, model Model, stored uint64, exists bool) error {
+	if VersionField(model) == "" {
+		return nil
+	}
+	version := ModelVersion(model)
+	if exists && version != stored {
+		return NewErrConflict(table, model.Id(), version)
+	}
+	if IsRestored(ctx) && version != 0 {
+		return nil
+	}
+	SetVersion(model, stored+1)
+	return nil
+}
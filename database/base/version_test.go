@@ -0,0 +1,69 @@
+package base
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+type versionCar struct {
+	ID      uint `json:"id"`
+	Version int  `json:"version"`
+}
+
+func (car versionCar) Id() any                     { return car.ID }
+func (versionCar) Create(db DB, data string) Model { return &versionCar{} }
+func (car *versionCar) Save(table Table) error     { return table.Save(car) }
+func (car *versionCar) Delete(db DB) error         { return nil }
+
+type plainCar struct {
+	ID uint `json:"id"`
+}
+
+func (car plainCar) Id() any                     { return car.ID }
+func (plainCar) Create(db DB, data string) Model { return &plainCar{} }
+func (car *plainCar) Save(table Table) error     { return table.Save(car) }
+func (car *plainCar) Delete(db DB) error         { return nil }
+
+func TestNextVersion(t *testing.T) {
+	car := &versionCar{ID: 1}
+	if err := NextVersion(context.Background(), "car", car, 0, false); err != nil || car.Version != 1 {
+		t.Fatalf("NextVersion of new model returns %v, version %v, expected 1", err, car.Version)
+	}
+	if err := NextVersion(context.Background(), "car", car, 1, true); err != nil || car.Version != 2 {
+		t.Fatalf("NextVersion of current model returns %v, version %v, expected 2", err, car.Version)
+	}
+
+	// stale model keeps its version
+	err := NextVersion(context.Background(), "car", car, 5, true)
+	if errC, ok := err.(ErrConflict); !ok {
+		t.Fatalf("NextVersion of stale model returns %T: %v, expected ErrConflict", err, err)
+	} else if errC.Table != "car" || errC.Id != uint(1) || errC.Version != 2 {
+		t.Errorf("ErrConflict is %+v, expected table car, id 1 and version 2", errC)
+	}
+	if car.Version != 2 {
+		t.Errorf("failed NextVersion changes version to %v", car.Version)
+	}
+
+	SetVersion(car, 7)
+	if version := ModelVersion(car); version != 7 {
+		t.Errorf("ModelVersion after SetVersion is %v, expected 7", version)
+	}
+
+	// model without version is never conflicting
+	plain := &plainCar{ID: 1}
+	if err := NextVersion(context.Background(), "car", plain, 5, true); err != nil {
+		t.Errorf("NextVersion of model without version returns %v", err)
+	}
+	if version := ModelVersion(plain); version != 0 {
+		t.Errorf("ModelVersion of model without version is %v", version)
+	}
+
+	// new restored model keeps its version
+	restored := &versionCar{Version: 4}
+	if err := NextVersion(WithRestored(context.Background()), "car", restored, 0, false); err != nil || restored.Version != 4 {
+		t.Errorf("NextVersion of restored model returns %v, version %v, expected 4", err, restored.Version)
+	}
+}
@@ -1,23 +1,55 @@
 package bbolt
 
 import (
+	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"log"
 	"reflect"
+	"strconv"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
 	. "github.com/PoulIgorson/sub_engine_fiber/define"
 )
 
-var _ Table = &Bucket{}
+var _ ContextTable = &Bucket{}
+var _ ManyGetter = &Bucket{}
 
 const _DELETE = "DELETE"
 
+// keysKey is key of marker of format of keys of bucket. Keys of models are big-endian ids,
+// so cursor walks models in order of ids, key of id 0 is counter of ids.
+// Buckets without marker have decimal keys and are migrated by open.
+const keysKey = "keys"
+
+// keysFormat is value of marker of big-endian keys
+const keysFormat = "uint64"
+
+// sizeKey is key of count of models of bucket, big-endian uint64.
+// It is changed by put in transaction of write of model, buckets without it get it by open.
+const sizeKey = "size"
+
+// idKey returns key of model of id.
+func idKey(id uint) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(id))
+}
+
+// keyId returns id of key of model, false for counter, marker and nested buckets.
+func keyId(key []byte) (uint, bool) {
+	if len(key) != 8 {
+		return 0, false
+	}
+	id := uint(binary.BigEndian.Uint64(key))
+	return id, id != 0
+}
+
 func checkId(idI any) (uint, error) {
 	id, ok := idI.(uint)
 	if !ok {
@@ -37,12 +69,24 @@ type Bucket struct {
 
 	model   Model
 	Objects ManagerI
+
+	// parent is bucket of parent db, set for bucket of transaction
+	parent *Bucket
 }
 
 func (bucket *Bucket) Manager() ManagerI {
 	return bucket.Objects
 }
 
+// cache returns manager of bucket outside of transaction,
+// it is updated after commit.
+func (bucket *Bucket) cache() ManagerI {
+	if bucket.parent != nil {
+		return bucket.parent.Objects
+	}
+	return bucket.Objects
+}
+
 func (bucket *Bucket) SetManager(newManager ManagerI) {
 	bucket.Objects = newManager
 }
@@ -62,142 +106,419 @@ func (bucket *Bucket) Model() Model {
 	return bucket.model
 }
 
+// Count returns count of models of bucket, soft deleted models too.
 func (bucket *Bucket) Count() uint {
-	var count string
-	bucket.db.boltDB.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(bucket.name))
-		count = string(bucket.Get([]byte("0")))
+	var count uint
+	bucket.db.view(context.Background(), func(tx *bolt.Tx) error {
+		count = bucket.size(tx)
 		return nil
 	})
+	return count
+}
+
+// size returns count of models of bucket inside tx.
+func (bucket *Bucket) size(tx *bolt.Tx) uint {
+	value := tx.Bucket([]byte(bucket.name)).Get([]byte(sizeKey))
+	if len(value) != 8 {
+		return 0
+	}
+	return uint(binary.BigEndian.Uint64(value))
+}
+
+// setSize writes count of models of bucket inside tx.
+func (bucket *Bucket) setSize(tx *bolt.Tx, size uint) error {
+	return tx.Bucket([]byte(bucket.name)).Put([]byte(sizeKey), idKey(size))
+}
+
+// count returns value of counter of bucket inside tx, it is last id of bucket.
+func (bucket *Bucket) count(tx *bolt.Tx) uint {
+	count := string(tx.Bucket([]byte(bucket.name)).Get(idKey(0)))
 	if count == "" || count == "0" {
-		bucket.set(uint(0), "1")
-		count = "1"
+		return 0
 	}
 	return ParseUint(count) - 1
 }
 
+// records calls fn for values of models of bucket inside tx in order of ids.
+func (bucket *Bucket) records(tx *bolt.Tx, fn func(id uint, value []byte) error) error {
+	return tx.Bucket([]byte(bucket.name)).ForEach(func(key, value []byte) error {
+		id, ok := keyId(key)
+		if !ok || value == nil {
+			return nil
+		}
+		return fn(id, value)
+	})
+}
+
+// open creates bucket inside tx, migrates its keys and reindexes it.
+func (bucket *Bucket) open(tx *bolt.Tx) error {
+	if _, err := tx.CreateBucketIfNotExists([]byte(bucket.name)); err != nil {
+		return err
+	}
+	if err := bucket.migrateKeys(tx); err != nil {
+		return err
+	}
+	if tx.Bucket([]byte(bucket.name)).Get([]byte(sizeKey)) == nil {
+		var size uint
+		err := bucket.records(tx, func(id uint, value []byte) error {
+			size++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := bucket.setSize(tx, size); err != nil {
+			return err
+		}
+	}
+	return bucket.reindex(tx)
+}
+
+// migrateKeys rewrites decimal keys of models and counter to big-endian keys
+// and marks bucket by keysKey, marked bucket is skipped.
+func (bucket *Bucket) migrateKeys(tx *bolt.Tx) error {
+	b := tx.Bucket([]byte(bucket.name))
+	if b.Get([]byte(keysKey)) != nil {
+		return nil
+	}
+	old := map[uint][]byte{}
+	err := b.ForEach(func(key, value []byte) error {
+		// nested buckets have nil values
+		if value == nil {
+			return nil
+		}
+		id, err := strconv.ParseUint(string(key), 10, 64)
+		if err != nil {
+			return fmt.Errorf("bbolt: key `%s` of bucket `%v` is not id", key, bucket.name)
+		}
+		old[uint(id)] = append([]byte{}, value...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for id, value := range old {
+		if err := b.Delete([]byte(fmt.Sprint(id))); err != nil {
+			return err
+		}
+		if err := b.Put(idKey(id), value); err != nil {
+			return err
+		}
+	}
+	return b.Put([]byte(keysKey), []byte(keysFormat))
+}
+
+// get returns raw value of key inside tx.
+func (bucket *Bucket) get(tx *bolt.Tx, key uint) (string, error) {
+	value := string(tx.Bucket([]byte(bucket.name)).Get(idKey(key)))
+	if value == "" {
+		return "", fmt.Errorf("bbolt: key `%v` is not exists", key)
+	}
+	if value == _DELETE {
+		return "", NewErrValueDelete(key)
+	}
+	return value, nil
+}
+
 // Get implements getting value of key in bucket.
 func (bucket *Bucket) Get(keyI any) (Model, error) {
+	return bucket.GetContext(context.Background(), keyI)
+}
+
+func (bucket *Bucket) GetContext(ctx context.Context, keyI any) (Model, error) {
 	key, err := checkId(keyI)
 	if err != nil {
 		return nil, err
 	}
 	var value string
-	err = bucket.db.boltDB.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(bucket.name))
-		value = string(bucket.Get([]byte(fmt.Sprint(key))))
-		if value == "" {
-			return fmt.Errorf("bbolt: key `%v` is not exists", key)
-		}
-		return nil
+	err = bucket.db.view(ctx, func(tx *bolt.Tx) error {
+		value, err = bucket.get(tx, key)
+		return err
 	})
-	if value == _DELETE {
-		err = NewErrValueDelete(key)
-	}
 	if err != nil {
 		return nil, NewErrorf("bbolt: Bucket.Get: %v", err.Error())
 	}
 	return bucket.model.Create(bucket.db, value), nil
 }
 
+// GetManyContext returns models of keys read in one transaction, missing keys are skipped.
+func (bucket *Bucket) GetManyContext(ctx context.Context, keys []any) ([]Model, error) {
+	models := []Model{}
+	err := bucket.db.view(ctx, func(tx *bolt.Tx) error {
+		for _, keyI := range keys {
+			key, err := checkId(keyI)
+			if err != nil {
+				return err
+			}
+			if value, err := bucket.get(tx, key); err == nil {
+				models = append(models, bucket.model.Create(bucket.db, value))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, NewErrorf("bbolt: Bucket.GetMany: %v", err.Error())
+	}
+	return models, nil
+}
+
 // Set implements setting value of key in bucket.
-func (bucket *Bucket) set(keyI any, value string) error {
+func (bucket *Bucket) set(ctx context.Context, keyI any, value string) error {
 	key, err := checkId(keyI)
 	if err != nil {
 		return err
 	}
-	if _, err := bucket.Get(key); err != nil && err.(Error).Name() == NewErrValueDelete(0).Name() {
-		return NewErrorf("bbolt: Bucket.Set: %v", err.Error())
-	}
-	err = bucket.db.boltDB.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(bucket.name))
-		if value == _DELETE {
-			return bucket.Delete([]byte(fmt.Sprint(key)))
+	err = bucket.db.update(ctx, func(tx *bolt.Tx) error {
+		if _, err := bucket.get(tx, key); err != nil {
+			if errD, ok := err.(Error); ok && errD.Name() == NewErrValueDelete(0).Name() {
+				return err
+			}
 		}
-		return bucket.Put([]byte(fmt.Sprint(key)), []byte(value))
+		return bucket.put(tx, key, value)
 	})
 	if err != nil {
 		log.Printf("bbolt: Bucket.Set: Error of saving bucket `%v`: %v\n", bucket.Name(), err.Error())
+		return NewErrorf("bbolt: Bucket.Set: %v", err.Error())
 	}
 	return nil
 }
 
+// put writes value of key inside tx, _DELETE removes key.
+// Count of models is changed with added and removed models.
+func (bucket *Bucket) put(tx *bolt.Tx, key uint, value string) error {
+	b := tx.Bucket([]byte(bucket.name))
+	if key == 0 {
+		// counter of ids
+		return b.Put(idKey(key), []byte(value))
+	}
+	exists := b.Get(idKey(key)) != nil
+	if value == _DELETE {
+		if !exists {
+			return nil
+		}
+		if err := b.Delete(idKey(key)); err != nil {
+			return err
+		}
+		return bucket.setSize(tx, bucket.size(tx)-1)
+	}
+	if err := b.Put(idKey(key), []byte(value)); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return bucket.setSize(tx, bucket.size(tx)+1)
+}
+
 // Delete implements Deleting value of key in bucket.
 func (bucket *Bucket) Delete(keyI any) error {
+	return bucket.DeleteContext(context.Background(), keyI)
+}
+
+// DeleteContext removes model of key, soft deleted models are marked by field `deleted_at`.
+func (bucket *Bucket) DeleteContext(ctx context.Context, keyI any) error {
+	key, err := checkId(keyI)
+	if err != nil {
+		return err
+	}
+	if SoftDeleteField(bucket.model) != "" {
+		return base.SoftDeleteContext(ctx, bucket, key)
+	}
+	return bucket.hardDelete(ctx, key)
+}
+
+// HardDelete removes model of key, soft deleted models too.
+func (bucket *Bucket) HardDelete(keyI any) error {
 	key, err := checkId(keyI)
 	if err != nil {
 		return err
 	}
-	bucket.Objects.ClearId(key)
-	return bucket.set(key, _DELETE)
+	return bucket.hardDelete(context.Background(), key)
+}
+
+// Restore clears field `deleted_at` of soft deleted model of key.
+func (bucket *Bucket) Restore(keyI any) error {
+	return base.Restore(bucket, keyI)
+}
+
+// hardDelete removes model of key with actions of relations.
+func (bucket *Bucket) hardDelete(ctx context.Context, key uint) error {
+	references := bucket.db.relations.To(bucket.name)
+	hooked := base.HasDeleteHooks(bucket.model)
+	if bucket.db.tx == nil && (len(references) > 0 || hooked) {
+		return bucket.db.Tx(func(tx DB) error {
+			return tx.TableFromCache(bucket.name).(*Bucket).hardDelete(ctx, key)
+		})
+	}
+	var model Model
+	if hooked {
+		// missing key is not deleted, hooks are not called
+		if model, _ = bucket.GetContext(ctx, key); model != nil {
+			if err := base.BeforeDelete(bucket, model); err != nil {
+				return err
+			}
+		}
+	}
+	if len(references) > 0 {
+		if err := bucket.db.relations.OnDelete(bucket.db, bucket.name, key); err != nil {
+			return err
+		}
+	}
+	err := bucket.db.update(ctx, func(tx *bolt.Tx) error {
+		value, err := bucket.get(tx, key)
+		if errD, ok := err.(Error); ok && errD.Name() == NewErrValueDelete(0).Name() {
+			return err
+		}
+		if err != nil {
+			// missing key
+			return nil
+		}
+		if err := bucket.index(tx, key, bucket.model.Create(bucket.db, value), false); err != nil {
+			return err
+		}
+		return bucket.put(tx, key, _DELETE)
+	})
+	if err != nil {
+		return NewErrorf("bbolt: Bucket.Delete: %v", err.Error())
+	}
+	if model != nil {
+		if err := base.AfterDelete(bucket, model); err != nil {
+			return err
+		}
+	}
+	bucket.db.afterCommit.Do(func() { bucket.cache().ClearId(key) })
+	return nil
+}
+
+// SaveMany saves models in one transaction.
+func (bucket *Bucket) SaveMany(models []Model) error {
+	return base.SaveMany(bucket, models)
+}
+
+// DeleteMany deletes models of ids in one transaction.
+func (bucket *Bucket) DeleteMany(ids []any) error {
+	return base.DeleteMany(bucket, ids)
 }
 
 // DeleteAll implements Deleting all values in bucket.
 func (bucket *Bucket) DeleteAll() error {
-	err := bucket.db.BoltDB().Update(func(tx *bolt.Tx) error {
+	err := bucket.db.update(context.Background(), func(tx *bolt.Tx) error {
 		if err := tx.DeleteBucket([]byte(bucket.name)); err != nil {
 			return err
 		}
-		_, err := tx.CreateBucketIfNotExists([]byte(bucket.name))
-		return err
+		return bucket.open(tx)
 	})
 	if err != nil {
 		return NewErrorf("bbolt: Bucket.DeleteAll: %v", err.Error())
 	}
-	bucket.Objects.Clear()
+	bucket.db.afterCommit.Do(bucket.cache().Clear)
 	return nil
 }
 
 func (bucket *Bucket) Save(model Model) error {
+	return bucket.SaveContext(context.Background(), model)
+}
+
+// SaveContext writes model in one transaction,
+// model without id gets next id of bucket.
+func (bucket *Bucket) SaveContext(ctx context.Context, model Model) error {
 	field_id, err := Check(model, "ID")
 	if err != nil {
 		return NewErrorf("bbolt: " + err.Error())
 	}
 	idUint, err := checkId(model.Id())
 	if err != nil {
-		if GetNameModel(model) != "user" {
+		// model without id is new model
+		if model.Id() != nil && GetNameModel(model) != "user" {
 			return err
 		}
 		field_id.Set(reflect.ValueOf(uint(0)))
-		idUint, err = 0, nil
+		idUint = 0
 	}
-
-	if idUint == 0 {
-		next_id := bucket.Count() + 1
-		field_id.Set(reflect.ValueOf(uint(next_id)))
-		idUint = next_id
-		bucket.set(uint(0), fmt.Sprint(next_id+1))
-	} else if _, err := bucket.Get(idUint); err != nil {
+	version := base.ModelVersion(model)
+	if bucket.db.tx == nil && base.HasSaveHooks(model) {
+		// hooks run in transaction of write
+		err := bucket.db.Tx(func(tx DB) error {
+			return tx.TableFromCache(bucket.name).(*Bucket).SaveContext(ctx, model)
+		})
+		if err != nil {
+			field_id.Set(reflect.ValueOf(idUint))
+			base.SetVersion(model, version)
+			return err
+		}
+		// fields are bound to table, not to finished transaction
+		base.BindManyRelations(bucket, model)
+		return nil
+	}
+	if err := base.BeforeSave(bucket, model); err != nil {
 		return err
 	}
-
-	buf, err := json.Marshal(model)
-	if err != nil {
-		return NewErrorf("bbolt: " + err.Error())
+	if err := Validate(bucket.name, model); err != nil {
+		return err
 	}
 
-	if idUint == 0 {
-		next_id := bucket.Count() + 1
-		field_id.Set(reflect.ValueOf(uint(next_id)))
-		idUint = uint(next_id)
-		bucket.set(uint(0), fmt.Sprint(next_id+1))
+	var value string
+	created := false
+	err = bucket.db.update(ctx, func(tx *bolt.Tx) error {
+		// nothing is written before check, so error keeps outer transaction consistent
+		if err := bucket.checkUnique(tx, idUint, model); err != nil {
+			return err
+		}
 		if idUint == 0 {
-			return NewErrorf("bbolt: internal error")
+			next_id := bucket.count(tx) + 1
+			if err := bucket.put(tx, 0, fmt.Sprint(next_id+1)); err != nil {
+				return err
+			}
+			field_id.Set(reflect.ValueOf(next_id))
+			idUint = next_id
+			created = true
+			base.NextVersion(ctx, bucket.name, model, 0, false)
+			base.Stamp(ctx, model, time.Time{}, time.Now().UTC())
+		} else {
+			old, err := bucket.get(tx, idUint)
+			if err != nil {
+				return err
+			}
+			oldModel := bucket.model.Create(bucket.db, old)
+			if err := base.NextVersion(ctx, bucket.name, model, base.ModelVersion(oldModel), true); err != nil {
+				return err
+			}
+			base.Stamp(ctx, model, base.AutoTime(oldModel, AutoCreated), time.Now().UTC())
+			if err := bucket.index(tx, idUint, oldModel, false); err != nil {
+				return err
+			}
 		}
-		buf, err = json.Marshal(model)
+
+		buf, err := json.Marshal(model)
 		if err != nil {
-			return NewErrorf("bbolt: " + err.Error())
+			return err
 		}
+		value = string(buf)
+		if err := bucket.put(tx, idUint, value); err != nil {
+			return err
+		}
+		return bucket.index(tx, idUint, model, true)
+	})
+	if err != nil && created {
+		// id assigned in rolled back transaction
+		field_id.Set(reflect.ValueOf(uint(0)))
 	}
-
-	errr := bucket.set(idUint, string(buf))
-	if errr != nil {
-		return errr
+	if err != nil {
+		base.SetVersion(model, version)
+	}
+	if errU, ok := err.(ErrUnique); ok {
+		return errU
+	}
+	if errC, ok := err.(ErrConflict); ok {
+		return errC
+	}
+	if err != nil {
+		return NewErrorf("bbolt: Bucket.Save: %v", err.Error())
 	}
 
-	model, _ = bucket.Get(idUint)
-
-	bucket.Objects.Store(idUint, model)
-
+	base.BindManyRelations(bucket, model)
+	if err := base.AfterSave(bucket, model); err != nil {
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,217 @@
+package bbolt
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/dbtest"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// openDB returns db in temporary dir of t, it is closed by cleanup of t.
+func openDB(t *testing.T) *DataBase {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	dbtest.Run(t, dbtest.Config{Open: func(t *testing.T) DB {
+		return openDB(t)
+	}})
+}
+
+// openItems returns table of dbtest.TestItem of db.
+func openItems(t *testing.T, db *DataBase) Table {
+	t.Helper()
+	table, err := db.Table("test_item", &dbtest.TestItem{ID: uint(0)})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	return table
+}
+
+// ids returns ids of models of manager taken by Iterate.
+func ids(t *testing.T, manager ManagerI) []uint {
+	t.Helper()
+	ids := []uint{}
+	err := manager.Iterate(func(model Model) bool {
+		ids = append(ids, model.Id().(uint))
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Iterate: %v", err)
+	}
+	return ids
+}
+
+func TestIterateOrder(t *testing.T) {
+	table := openItems(t, openDB(t))
+	count := iteratePart*2 + 5
+	for i := 1; i <= count; i++ {
+		// years repeat, so ordered passes break ties by id
+		item := &dbtest.TestItem{ID: uint(0), Name: fmt.Sprint("item", i), Year: i % 7}
+		if err := table.Save(item); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	got := ids(t, table.Manager())
+	if len(got) != count || !sort.SliceIsSorted(got, func(i, j int) bool { return got[i] < got[j] }) {
+		t.Fatalf("Iterate returns %v ids not in order of ids: %v", len(got), got)
+	}
+
+	got = ids(t, table.Manager().OrderBy("-Year"))
+	models := table.Manager().OrderBy("-Year").All()
+	if len(got) != count || len(models) != count {
+		t.Fatalf("ordered Iterate returns %v ids, All returns %v, expected %v", len(got), len(models), count)
+	}
+	for i, model := range models {
+		if got[i] != model.Id().(uint) {
+			t.Fatalf("ordered Iterate returns id %v at %v, All returns %v", got[i], i, model.Id())
+		}
+		if i > 0 {
+			prev, item := models[i-1].(*dbtest.TestItem), model.(*dbtest.TestItem)
+			if prev.Year < item.Year || prev.Year == item.Year && prev.ID.(uint) > item.ID.(uint) {
+				t.Fatalf("models %v and %v are not ordered by -Year and id", prev.ID, item.ID)
+			}
+		}
+	}
+
+	if count := table.Manager().OrderBy("Year").Offset(3).Limit(4).Count(); count != 4 {
+		t.Fatalf("Count of page is %v, expected 4", count)
+	}
+}
+
+func TestMigrateKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	boltDB, err := bolt.Open(path, 0666, nil)
+	if err != nil {
+		t.Fatalf("bolt.Open: %v", err)
+	}
+	// decimal keys of previous versions, key "0" is next id plus one
+	err = boltDB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("test_item"))
+		if err != nil {
+			return err
+		}
+		for id := 1; id <= 10; id++ {
+			value := fmt.Sprintf(`{"id":%v,"name":"item%v","year":%v}`, id, id, 2000+id)
+			if err := b.Put([]byte(fmt.Sprint(id)), []byte(value)); err != nil {
+				return err
+			}
+		}
+		return b.Put([]byte("0"), []byte("11"))
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	boltDB.Close()
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	table := openItems(t, db)
+
+	want := []uint{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if got := ids(t, table.Manager()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Iterate after migration returns %v, expected %v", got, want)
+	}
+	model, err := table.Get(uint(10))
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if model.(*dbtest.TestItem).Name != "item10" {
+		t.Fatalf("Get returns %v, expected item10", model.(*dbtest.TestItem).Name)
+	}
+	if got := table.Manager().Filter(Params{"Year>=": 2009}).Count(); got != 2 {
+		t.Fatalf("Count of indexed Filter is %v, expected 2", got)
+	}
+	item := &dbtest.TestItem{ID: uint(0), Name: "item11"}
+	if err := table.Save(item); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if item.ID != uint(11) {
+		t.Fatalf("id of new item is %v, expected 11", item.ID)
+	}
+	if count := table.Count(); count != 11 {
+		t.Fatalf("Count is %v, expected 11", count)
+	}
+}
+
+func TestCount(t *testing.T) {
+	db := openDB(t)
+	table := openItems(t, db)
+	items := []*dbtest.TestItem{}
+	for i := 0; i < 3; i++ {
+		item := &dbtest.TestItem{ID: uint(0), Name: fmt.Sprint("item", i)}
+		if err := table.Save(item); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		items = append(items, item)
+	}
+	items[0].Year = 2000
+	if err := table.Save(items[0]); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := table.Delete(items[1].ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := table.Delete(uint(100)); err != nil {
+		t.Fatalf("Delete of missing id: %v", err)
+	}
+	if count := table.Count(); count != 2 {
+		t.Fatalf("Count is %v, expected 2", count)
+	}
+
+	// count is written in transaction of model
+	rollback := fmt.Errorf("rollback")
+	err := db.Tx(func(tx DB) error {
+		txTable := tx.TableFromCache("test_item")
+		if err := txTable.Save(&dbtest.TestItem{ID: uint(0), Name: "tx"}); err != nil {
+			return err
+		}
+		if count := txTable.Count(); count != 3 {
+			t.Errorf("Count inside Tx is %v, expected 3", count)
+		}
+		return rollback
+	})
+	if err != rollback {
+		t.Fatalf("Tx returns %v, expected rollback", err)
+	}
+	if count := table.Count(); count != 2 {
+		t.Fatalf("Count after rollback is %v, expected 2", count)
+	}
+
+	// bucket without count gets it on open
+	err = db.boltDB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("test_item")).Delete([]byte(sizeKey))
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := db.boltDB.Update(table.(*Bucket).open); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if count := table.Count(); count != 2 {
+		t.Fatalf("Count after open is %v, expected 2", count)
+	}
+
+	if err := table.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	if count := table.Count(); count != 0 {
+		t.Fatalf("Count after DeleteAll is %v, expected 0", count)
+	}
+}
@@ -2,6 +2,7 @@
 package bbolt
 
 import (
+	"context"
 	"sync"
 
 	bolt "go.etcd.io/bbolt"
@@ -36,14 +37,48 @@ func (m *bucketMap) LoadOK(key string) (*Bucket, bool) {
 
 // DataBase implements interface access to bbolt db.
 type DataBase struct {
-	boltDB  *bolt.DB
-	buckets bucketMap // map[string]Table
+	boltDB    *bolt.DB
+	buckets   bucketMap // map[string]Table
+	relations *base.Relations
+
+	// set for view returned by Tx
+	tx          *bolt.Tx
+	parent      *DataBase
+	afterCommit *base.Deferred
 }
 
 func (db *DataBase) BoltDB() *bolt.DB {
 	return db.boltDB
 }
 
+// view runs fn in read transaction, fn is not called if ctx is done.
+func (db *DataBase) view(ctx context.Context, fn func(tx *bolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if db.tx != nil {
+		return fn(db.tx)
+	}
+	return db.boltDB.View(fn)
+}
+
+// update runs fn in write transaction,
+// transaction is rolled back if ctx is done before commit.
+func (db *DataBase) update(ctx context.Context, fn func(tx *bolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if db.tx != nil {
+		return fn(db.tx)
+	}
+	return db.boltDB.Update(func(tx *bolt.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return ctx.Err()
+	})
+}
+
 // Open return pointer to DataBase,
 // If DataBase does not exist then error.
 func Open(path string) (*DataBase, error) {
@@ -51,11 +86,39 @@ func Open(path string) (*DataBase, error) {
 	if err != nil {
 		return nil, NewErrorf(err.Error())
 	}
-	return &DataBase{boltDB: db}, nil
+	return &DataBase{boltDB: db, relations: &base.Relations{}}, nil
+}
+
+// Tx runs fn in one bolt transaction, tables of tx share it.
+// Managers of tables are updated after commit only.
+// Writes through managers are not a part of transaction, use tables of tx.
+func (db *DataBase) Tx(fn func(tx DB) error) error {
+	if db.tx != nil {
+		return fn(db)
+	}
+	txDB := &DataBase{
+		boltDB:      db.boltDB,
+		relations:   db.relations,
+		parent:      db,
+		afterCommit: &base.Deferred{},
+	}
+	err := db.boltDB.Update(func(tx *bolt.Tx) error {
+		txDB.tx = tx
+		return fn(txDB)
+	})
+	txDB.tx = nil
+	if err != nil {
+		return err
+	}
+	txDB.afterCommit.Run()
+	return nil
 }
 
 // Close implements access to close DataBase.
 func (db *DataBase) Close() error {
+	if db.tx != nil {
+		return NewErrorf("bbolt: transaction can not be closed")
+	}
 	db.buckets = bucketMap{}
 	err := db.boltDB.Close()
 	if err == nil {
@@ -68,6 +131,12 @@ func (db *DataBase) Close() error {
 func (db *DataBase) TableFromCache(name string) Table {
 	bucket, ok := db.buckets.LoadOK(name)
 	if !ok || bucket == nil {
+		if db.tx != nil {
+			if parent := db.parent.buckets.Load(name); parent != nil {
+				table, _ := db.txTable(name, parent.model)
+				return table
+			}
+		}
 		return nil
 	}
 	return bucket
@@ -85,29 +154,49 @@ func (db *DataBase) Table(_ string, model Model) (Table, error) {
 	if !ok && name != "user" {
 		return nil, NewErrorf("bbolt: id must be uint")
 	}
-	err := db.boltDB.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(name))
-		return err
-	})
-	if err != nil {
-		return nil, NewErrorf(err.Error())
+	db.relations.Register(name, model)
+	if db.tx != nil {
+		return db.txTable(name, model)
 	}
 	bucket := &Bucket{
 		db:    db,
 		name:  name,
 		model: model,
 	}
-	bucket.Objects = base.NewManager(bucket)
+	err := db.boltDB.Update(bucket.open)
+	if err != nil {
+		return nil, NewErrorf(err.Error())
+	}
+	bucket.Objects = newManager(bucket)
 	db.buckets.Store(name, bucket)
-	now := uint(0)
-	count := bucket.Count()
-	bucket.Objects.Broadcast(func() any {
-		now++
-		if now >= count {
-			return nil
+	return bucket, nil
+}
+
+// txTable returns bucket bound to transaction of db, its manager reads
+// the transaction and manager of bucket of parent db is updated after commit.
+// Bucket is opened only if parent db has not opened it yet.
+func (db *DataBase) txTable(name string, model Model) (Table, error) {
+	parent := db.parent.buckets.Load(name)
+	if parent == nil {
+		if err := (&Bucket{db: db, name: name, model: model}).open(db.tx); err != nil {
+			return nil, NewErrorf(err.Error())
 		}
-		return now
-	})
+		parent = &Bucket{
+			db:    db.parent,
+			name:  name,
+			model: model,
+		}
+		parent.Objects = newManager(parent)
+		db.afterCommit.Do(func() { db.parent.buckets.Store(name, parent) })
+	}
+	bucket := &Bucket{
+		db:     db,
+		name:   name,
+		model:  model,
+		parent: parent,
+	}
+	bucket.Objects = newManager(bucket)
+	db.buckets.Store(name, bucket)
 	return bucket, nil
 }
 
@@ -117,7 +206,7 @@ func (db *DataBase) ExistsTable(name string) bool {
 		return true
 	}
 	var exists bool
-	db.boltDB.View(func(tx *bolt.Tx) error {
+	db.view(context.Background(), func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(name))
 		exists = (bucket != nil)
 		return nil
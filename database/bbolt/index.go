@@ -0,0 +1,325 @@
+package bbolt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// indexBucket is name of nested bucket with buckets of indexed fields.
+// Key of index is encoded value of field, zero byte and id of model,
+// so models with equal values are neighbours ordered by id.
+const indexBucket = "index"
+
+// indexes returns names of fields of model tagged `index:"true"`.
+func (bucket *Bucket) indexes() []string {
+	vModel, err := GoToStruct(reflect.ValueOf(bucket.model))
+	if err != nil {
+		return nil
+	}
+	fields := []string{}
+	modelT := vModel.Type()
+	for i := 0; i < modelT.NumField(); i++ {
+		field := modelT.Field(i)
+		if field.Tag.Get("index") == "true" && field.IsExported() {
+			fields = append(fields, field.Name)
+		}
+	}
+	return fields
+}
+
+// indexOf returns bucket of index of field inside tx, nil if field is not indexed.
+func (bucket *Bucket) indexOf(tx *bolt.Tx, field string) *bolt.Bucket {
+	index := tx.Bucket([]byte(bucket.name)).Bucket([]byte(indexBucket))
+	if index == nil {
+		return nil
+	}
+	return index.Bucket([]byte(field))
+}
+
+// reindex creates missing indexes, unique sets and join buckets and fills them by models of bucket.
+func (bucket *Bucket) reindex(tx *bolt.Tx) error {
+	if err := bucket.reindexUnique(tx); err != nil {
+		return err
+	}
+	if err := bucket.reindexJoin(tx); err != nil {
+		return err
+	}
+	fields := bucket.indexes()
+	if len(fields) == 0 {
+		return nil
+	}
+	b := tx.Bucket([]byte(bucket.name))
+	index, err := b.CreateBucketIfNotExists([]byte(indexBucket))
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if index.Bucket([]byte(field)) != nil {
+			continue
+		}
+		fieldIndex, err := index.CreateBucket([]byte(field))
+		if err != nil {
+			return err
+		}
+		err = bucket.records(tx, func(id uint, value []byte) error {
+			model := bucket.model.Create(bucket.db, string(value))
+			return putIndex(fieldIndex, model, field, id, true)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// index adds (or removes if !add) entries of model of id to indexes,
+// unique sets and join buckets inside tx.
+func (bucket *Bucket) index(tx *bolt.Tx, id uint, model Model, add bool) error {
+	if err := bucket.unique(tx, id, model, add); err != nil {
+		return err
+	}
+	if err := bucket.join(tx, id, model, add); err != nil {
+		return err
+	}
+	for _, field := range bucket.indexes() {
+		fieldIndex := bucket.indexOf(tx, field)
+		if fieldIndex == nil {
+			if err := bucket.reindex(tx); err != nil {
+				return err
+			}
+			fieldIndex = bucket.indexOf(tx, field)
+		}
+		if err := putIndex(fieldIndex, model, field, id, add); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putIndex(fieldIndex *bolt.Bucket, model Model, field string, id uint, add bool) error {
+	value, err := Check(model, field)
+	if err != nil {
+		return err
+	}
+	encoded, ok := encodeIndex(*value)
+	if !ok {
+		return nil
+	}
+	key := indexKey(encoded, id)
+	if add {
+		return fieldIndex.Put(key, []byte(fmt.Sprint(id)))
+	}
+	return fieldIndex.Delete(key)
+}
+
+func indexKey(encoded []byte, id uint) []byte {
+	key := make([]byte, 0, len(encoded)+9)
+	key = append(key, encoded...)
+	key = append(key, 0)
+	return binary.BigEndian.AppendUint64(key, uint64(id))
+}
+
+// encodeIndex returns value encoded with order of bytes equal to order of values,
+// false for nil and types that can not be indexed.
+func encodeIndex(value reflect.Value) ([]byte, bool) {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil, false
+		}
+		value = value.Elem()
+	}
+	if value.CanConvert(reflect.TypeOf(time.Time{})) && value.Kind() == reflect.Struct {
+		t := value.Convert(reflect.TypeOf(time.Time{})).Interface().(time.Time)
+		return binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano())^(1<<63)), true
+	}
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.BigEndian.AppendUint64(nil, uint64(value.Int())^(1<<63)), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return binary.BigEndian.AppendUint64(nil, value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		bits := math.Float64bits(value.Float())
+		if bits&(1<<63) != 0 {
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		return binary.BigEndian.AppendUint64(nil, bits), true
+	case reflect.Bool:
+		if value.Bool() {
+			return []byte{1}, true
+		}
+		return []byte{0}, true
+	case reflect.String:
+		return []byte(value.String()), true
+	}
+	return nil, false
+}
+
+// indexLookup is condition of q that can be answered by index.
+type indexLookup struct {
+	field string
+	op    string
+	value any
+}
+
+// lookups returns conditions of indexed fields and many-to-many fields joined by And to q,
+// related models of many-to-many fields are found at once, lookups is called outside of tx.
+func (bucket *Bucket) lookups(q Q) []indexLookup {
+	if q.Not || q.IsOr() {
+		return nil
+	}
+	indexed := map[string]bool{}
+	for _, field := range bucket.indexes() {
+		indexed[field] = true
+	}
+	found := []indexLookup{}
+	for _, key := range SortedKeys(q.Params) {
+		if lookup, ok := bucket.joinLookup(key, q.Params[key]); ok {
+			found = append(found, lookup)
+			continue
+		}
+		field, op := SplitKey(key)
+		if !indexed[field] {
+			continue
+		}
+		switch op {
+		case "=", "<", "<=", ">", ">=", "__in", "__between":
+			found = append(found, indexLookup{field, op, q.Params[key]})
+		}
+	}
+	for _, child := range q.Children {
+		found = append(found, bucket.lookups(child)...)
+	}
+	// equality selects less models than range
+	sort.SliceStable(found, func(i, j int) bool {
+		return (found[i].op == "=" || found[i].op == "__in") && found[j].op != "=" && found[j].op != "__in"
+	})
+	return found
+}
+
+// lookup returns sorted ids of models satisfying first usable lookup of lookups,
+// false if they can not be answered by index.
+func (bucket *Bucket) lookup(tx *bolt.Tx, lookups []indexLookup) ([]uint, bool) {
+	for _, lookup := range lookups {
+		if lookup.op == "__join" {
+			if fieldJoin, _, _ := bucket.joinOf(tx, lookup.field, false); fieldJoin != nil {
+				return joinIds(fieldJoin, lookup.value.([]uint)), true
+			}
+			continue
+		}
+		fieldIndex := bucket.indexOf(tx, lookup.field)
+		if fieldIndex == nil {
+			continue
+		}
+		if ids, ok := lookup.ids(bucket.model, fieldIndex); ok {
+			return ids, true
+		}
+	}
+	return nil, false
+}
+
+func (lookup indexLookup) ids(model Model, fieldIndex *bolt.Bucket) ([]uint, bool) {
+	encode := func(value any) ([]byte, bool) {
+		field, err := Check(model, lookup.field)
+		if err != nil {
+			return nil, false
+		}
+		v, ok := ConvertExact(reflect.ValueOf(value), field.Type())
+		if !ok {
+			return nil, false
+		}
+		return encodeIndex(v)
+	}
+	bounds := func(value any) ([]any, bool) {
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, false
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).Interface()
+		}
+		return items, true
+	}
+
+	ids := []uint{}
+	scan := func(lower, upper []byte, lowerIncl, upperIncl bool) {
+		cursor := fieldIndex.Cursor()
+		key, _ := cursor.First()
+		if lower != nil {
+			key, _ = cursor.Seek(lower)
+		}
+		for ; key != nil; key, _ = cursor.Next() {
+			if len(key) < 9 {
+				continue
+			}
+			value := key[:len(key)-9]
+			if lower != nil {
+				if r := bytes.Compare(value, lower); r < 0 || (r == 0 && !lowerIncl) {
+					continue
+				}
+			}
+			if upper != nil {
+				if r := bytes.Compare(value, upper); r > 0 || (r == 0 && !upperIncl) {
+					break
+				}
+			}
+			ids = append(ids, uint(binary.BigEndian.Uint64(key[len(key)-8:])))
+		}
+	}
+
+	switch lookup.op {
+	case "__in", "__between":
+		items, ok := bounds(lookup.value)
+		if !ok || (lookup.op == "__between" && len(items) != 2) {
+			return nil, false
+		}
+		encoded := make([][]byte, len(items))
+		for i, item := range items {
+			if encoded[i], ok = encode(item); !ok {
+				return nil, false
+			}
+		}
+		if lookup.op == "__between" {
+			scan(encoded[0], encoded[1], true, true)
+			break
+		}
+		for _, value := range encoded {
+			scan(value, value, true, true)
+		}
+	default:
+		encoded, ok := encode(lookup.value)
+		if !ok {
+			return nil, false
+		}
+		switch lookup.op {
+		case "=":
+			scan(encoded, encoded, true, true)
+		case "<", "<=":
+			scan(nil, encoded, false, lookup.op == "<=")
+		case ">", ">=":
+			scan(encoded, nil, lookup.op == ">=", false)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	unique := ids[:0]
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			unique = append(unique, id)
+		}
+	}
+	return unique, true
+}
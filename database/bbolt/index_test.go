@@ -0,0 +1,211 @@
+package bbolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+func TestEncodeIndex(t *testing.T) {
+	now := time.Now()
+	// values of every list are ascending
+	tests := [][]any{
+		{-5, -1, 0, 1, 300},
+		{uint(0), uint(1), uint(1 << 40)},
+		{-2.5, -0.5, 0.0, 0.25, 10.0},
+		{false, true},
+		{"", "a", "ab", "b"},
+		{now.Add(-time.Hour), now, now.Add(time.Second)},
+	}
+	for _, values := range tests {
+		for i := 1; i < len(values); i++ {
+			a, okA := encodeIndex(reflect.ValueOf(values[i-1]))
+			b, okB := encodeIndex(reflect.ValueOf(values[i]))
+			if !okA || !okB {
+				t.Fatalf("encodeIndex can not encode %v or %v", values[i-1], values[i])
+			}
+			if bytes.Compare(a, b) != -1 {
+				t.Errorf("encoded %v does not precede encoded %v", values[i-1], values[i])
+			}
+		}
+	}
+	var nilTime *time.Time
+	if _, ok := encodeIndex(reflect.ValueOf(nilTime)); ok {
+		t.Errorf("encodeIndex encodes nil")
+	}
+	if _, ok := encodeIndex(reflect.ValueOf([]int{1})); ok {
+		t.Errorf("encodeIndex encodes slice")
+	}
+}
+
+// indexItem is model of tests of indexes, Year is indexed.
+type indexItem struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Year  int    `json:"year" index:"true"`
+	Color string `json:"color"`
+}
+
+func (item indexItem) Id() any {
+	return item.ID
+}
+
+func (indexItem) Create(db DB, data string) Model {
+	item := &indexItem{}
+	json.Unmarshal([]byte(data), item)
+	return item
+}
+
+func (item *indexItem) Save(table Table) error {
+	return table.Save(item)
+}
+
+func (item *indexItem) Delete(db DB) error {
+	return db.TableFromCache("index_item").Delete(item.ID)
+}
+
+// lookupIds returns ids found by index for q, false if q is not answered by index.
+func lookupIds(t *testing.T, bucket *Bucket, q Q) ([]uint, bool) {
+	t.Helper()
+	var ids []uint
+	var ok bool
+	err := bucket.db.view(context.Background(), func(tx *bolt.Tx) error {
+		ids, ok = bucket.lookup(tx, bucket.lookups(q))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("view: %v", err)
+	}
+	return ids, ok
+}
+
+func TestIndexLookup(t *testing.T) {
+	db := openDB(t)
+	table, err := db.Table("", &indexItem{})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	bucket := table.(*Bucket)
+	for i, year := range []int{2003, 2001, 2002, 2001} {
+		item := &indexItem{Name: string(rune('a' + i)), Year: year, Color: "red"}
+		if err := table.Save(item); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	tests := []struct {
+		q    Q
+		want []uint
+	}{
+		{And(Params{"Year": 2001}), []uint{2, 4}},
+		{And(Params{"Year>": 2001}), []uint{1, 3}},
+		{And(Params{"Year<=": 2002}), []uint{2, 3, 4}},
+		{And(Params{"Year__in": []int{2003, 2001}}), []uint{1, 2, 4}},
+		{And(Params{"Year__between": []int{2002, 2003}}), []uint{1, 3}},
+		{And(Params{"Year": 2001, "Color": "red"}), []uint{2, 4}},
+		{And(Params{"Year": 1999}), []uint{}},
+	}
+	for _, test := range tests {
+		ids, ok := lookupIds(t, bucket, test.q)
+		if !ok {
+			t.Errorf("%v is not answered by index", test.q)
+			continue
+		}
+		if !reflect.DeepEqual(ids, test.want) {
+			t.Errorf("index returns %v for %v, expected %v", ids, test.q, test.want)
+		}
+	}
+
+	for _, q := range []Q{
+		And(Params{"Color": "red"}),
+		And(Params{"Year!=": 2001}),
+		Or(Params{"Year": 2001}, Params{"Color": "red"}),
+		Not(Params{"Year": 2001}),
+	} {
+		if _, ok := lookupIds(t, bucket, q); ok {
+			t.Errorf("%v is answered by index", q)
+		}
+	}
+
+	// entries of changed and deleted models are removed
+	item := table.Manager().Get(uint(2)).(*indexItem)
+	item.Year = 2005
+	if err := table.Save(item); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := table.Delete(uint(4)); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ids, _ := lookupIds(t, bucket, And(Params{"Year": 2001})); len(ids) != 0 {
+		t.Errorf("index returns %v for old year, expected no ids", ids)
+	}
+	if ids, _ := lookupIds(t, bucket, And(Params{"Year>=": 2003})); !reflect.DeepEqual(ids, []uint{1, 2}) {
+		t.Errorf("index returns %v for new year, expected [1 2]", ids)
+	}
+}
+
+// plainItem is indexItem before its field Year was indexed.
+type plainItem struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Year int    `json:"year"`
+}
+
+func (item plainItem) Id() any {
+	return item.ID
+}
+
+func (plainItem) Create(db DB, data string) Model {
+	item := &plainItem{}
+	json.Unmarshal([]byte(data), item)
+	return item
+}
+
+func (item *plainItem) Save(table Table) error {
+	return table.Save(item)
+}
+
+func (item *plainItem) Delete(db DB) error {
+	return nil
+}
+
+func TestReindex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	table, err := db.Table("", &plainItem{})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	for _, year := range []int{2000, 2010, 2020} {
+		if err := table.Save(&plainItem{Year: year}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	db.Close()
+
+	// bucket of the same name gets index of existing models when it is opened
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	bucket := &Bucket{db: db, name: "plain_item", model: &indexItem{}}
+	if err := db.boltDB.Update(bucket.open); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	ids, ok := lookupIds(t, bucket, And(Params{"Year>": 2005}))
+	if !ok || !reflect.DeepEqual(ids, []uint{2, 3}) {
+		t.Fatalf("index of reopened bucket returns %v, %v, expected [2 3]", ids, ok)
+	}
+}
@@ -0,0 +1,154 @@
+package bbolt
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// joinBucket is name of nested bucket with join buckets of many-to-many fields.
+// Key of join is id of related model and id of model, 8 bytes each,
+// so models related to one model are neighbours.
+const joinBucket = "join"
+
+// joinOf returns join bucket of many-to-many field inside tx, it is created if create.
+func (bucket *Bucket) joinOf(tx *bolt.Tx, field string, create bool) (*bolt.Bucket, bool, error) {
+	b := tx.Bucket([]byte(bucket.name))
+	join := b.Bucket([]byte(joinBucket))
+	if join != nil && join.Bucket([]byte(field)) != nil {
+		return join.Bucket([]byte(field)), false, nil
+	}
+	if !create {
+		return nil, false, nil
+	}
+	join, err := b.CreateBucketIfNotExists([]byte(joinBucket))
+	if err != nil {
+		return nil, false, err
+	}
+	fieldJoin, err := join.CreateBucket([]byte(field))
+	return fieldJoin, true, err
+}
+
+// reindexJoin creates missing join buckets and fills them by models of bucket.
+func (bucket *Bucket) reindexJoin(tx *bolt.Tx) error {
+	for _, relation := range ModelManyRelations(bucket.model) {
+		fieldJoin, created, err := bucket.joinOf(tx, relation.Field, true)
+		if err != nil {
+			return err
+		}
+		if !created {
+			continue
+		}
+		err = bucket.records(tx, func(id uint, value []byte) error {
+			model := bucket.model.Create(bucket.db, string(value))
+			return putJoin(fieldJoin, model, relation.Field, id, true)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// join adds (or removes if !add) pairs of many-to-many fields of model of id inside tx.
+func (bucket *Bucket) join(tx *bolt.Tx, id uint, model Model, add bool) error {
+	for _, relation := range ModelManyRelations(model) {
+		fieldJoin, _, err := bucket.joinOf(tx, relation.Field, false)
+		if err != nil {
+			return err
+		}
+		if fieldJoin == nil {
+			if err := bucket.reindexJoin(tx); err != nil {
+				return err
+			}
+			// reindex has added pairs of model if it is saved already
+			fieldJoin, _, _ = bucket.joinOf(tx, relation.Field, false)
+		}
+		if err := putJoin(fieldJoin, model, relation.Field, id, add); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putJoin(fieldJoin *bolt.Bucket, model Model, field string, id uint, add bool) error {
+	value, err := Check(model, field)
+	if err != nil {
+		return err
+	}
+	for _, relatedI := range value.Interface().(interface{ IDs() []any }).IDs() {
+		related, err := checkId(relatedI)
+		if err != nil {
+			continue
+		}
+		key := joinKey(related, id)
+		if !add {
+			if err := fieldJoin.Delete(key); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fieldJoin.Put(key, []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func joinKey(related, id uint) []byte {
+	key := binary.BigEndian.AppendUint64(make([]byte, 0, 16), uint64(related))
+	return binary.BigEndian.AppendUint64(key, uint64(id))
+}
+
+// joinLookup returns lookup of key `Field__...` of many-to-many field with ids of related models
+// satisfying condition on their fields, false if key is not such.
+func (bucket *Bucket) joinLookup(key string, value any) (indexLookup, bool) {
+	field, _ := SplitKey(key)
+	name, _, ok := strings.Cut(field, "__")
+	if !ok {
+		return indexLookup{}, false
+	}
+	for _, relation := range ModelManyRelations(bucket.model) {
+		if relation.Field != name {
+			continue
+		}
+		table := bucket.db.TableFromCache(relation.Table)
+		if table == nil {
+			return indexLookup{}, false
+		}
+		related := []uint{}
+		for _, model := range table.Manager().Filter(Params{key[len(name)+2:]: value}).All() {
+			if id, err := checkId(model.Id()); err == nil {
+				related = append(related, id)
+			}
+		}
+		return indexLookup{name, "__join", related}, true
+	}
+	return indexLookup{}, false
+}
+
+// joinIds returns sorted ids of models related to models of related ids.
+func joinIds(fieldJoin *bolt.Bucket, related []uint) []uint {
+	ids := []uint{}
+	cursor := fieldJoin.Cursor()
+	for _, relatedId := range related {
+		prefix := binary.BigEndian.AppendUint64(nil, uint64(relatedId))
+		for key, _ := cursor.Seek(prefix); key != nil && len(key) == 16 && string(key[:8]) == string(prefix); key, _ = cursor.Next() {
+			ids = append(ids, uint(binary.BigEndian.Uint64(key[8:])))
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	unique := ids[:0]
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,135 @@
+package bbolt
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+type joinGroup struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func (group joinGroup) Id() any {
+	return group.ID
+}
+
+func (joinGroup) Create(db DB, data string) Model {
+	group := &joinGroup{}
+	json.Unmarshal([]byte(data), group)
+	return group
+}
+
+func (group *joinGroup) Save(table Table) error {
+	return table.Save(group)
+}
+
+func (group *joinGroup) Delete(db DB) error {
+	return db.TableFromCache("join_group").Delete(group.ID)
+}
+
+// joinUser is model of tests of join buckets, Groups is many-to-many field.
+type joinUser struct {
+	ID     uint                   `json:"id"`
+	Name   string                 `json:"name"`
+	Groups ManyToMany[*joinGroup] `json:"groups"`
+}
+
+func (user joinUser) Id() any {
+	return user.ID
+}
+
+func (joinUser) Create(db DB, data string) Model {
+	user := &joinUser{}
+	json.Unmarshal([]byte(data), user)
+	return user
+}
+
+func (user *joinUser) Save(table Table) error {
+	return table.Save(user)
+}
+
+func (user *joinUser) Delete(db DB) error {
+	return db.TableFromCache("join_user").Delete(user.ID)
+}
+
+func TestJoin(t *testing.T) {
+	db := openDB(t)
+	groups, err := db.Table("", &joinGroup{})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	users, err := db.Table("", &joinUser{})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	a, b, c := &joinGroup{Name: "a"}, &joinGroup{Name: "b"}, &joinGroup{Name: "c"}
+	for _, group := range []*joinGroup{a, b, c} {
+		if err := groups.Save(group); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	x, y, z := &joinUser{Name: "x"}, &joinUser{Name: "y"}, &joinUser{Name: "z"}
+	for _, user := range []*joinUser{x, y, z} {
+		if err := users.Save(user); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	if err := x.Groups.Add(a, b); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := y.Groups.Set(b); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := z.Groups.Add(c); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	bucket := users.(*Bucket)
+	tests := []struct {
+		q    Q
+		want []uint
+	}{
+		{And(Params{"Groups__Name": "a"}), []uint{x.ID}},
+		{And(Params{"Groups__Name": "b"}), []uint{x.ID, y.ID}},
+		{And(Params{"Groups__Name__in": []string{"a", "c"}}), []uint{x.ID, z.ID}},
+		{And(Params{"Groups__Name": "none"}), []uint{}},
+	}
+	for _, test := range tests {
+		ids, ok := lookupIds(t, bucket, test.q)
+		if !ok {
+			t.Errorf("%v is not answered by join", test.q)
+			continue
+		}
+		if !reflect.DeepEqual(ids, test.want) {
+			t.Errorf("join returns %v for %v, expected %v", ids, test.q, test.want)
+		}
+		if count := users.Manager().Where(test.q).Count(); count != uint(len(test.want)) {
+			t.Errorf("Where(%v) returns %v models, expected %v", test.q, count, len(test.want))
+		}
+	}
+
+	// pairs of removed relations and deleted models are removed
+	if err := x.Groups.Remove(b); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := users.Delete(y.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ids, _ := lookupIds(t, bucket, And(Params{"Groups__Name": "b"})); len(ids) != 0 {
+		t.Errorf("join returns %v for b, expected no ids", ids)
+	}
+	if err := z.Groups.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if ids, _ := lookupIds(t, bucket, And(Params{"Groups__Name": "c"})); len(ids) != 0 {
+		t.Errorf("join returns %v for c after Clear, expected no ids", ids)
+	}
+	loaded := users.Manager().Get(x.ID).(*joinUser)
+	if got, err := loaded.Groups.All(); err != nil || len(got) != 1 || got[0].Name != "a" {
+		t.Errorf("groups of loaded x are %v, %v, expected [a]", got, err)
+	}
+}
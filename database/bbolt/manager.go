@@ -0,0 +1,231 @@
+package bbolt
+
+import (
+	"context"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// newManager returns manager of bucket, its queries read bucket,
+// models are not kept in memory.
+func newManager(bucket *Bucket) *base.Manager {
+	manager := base.NewManager(bucket)
+	manager.OnAll = ManagerAll
+	manager.OnFilter = ManagerFilter
+	manager.OnCount = ManagerCount
+	manager.OnIterate = ManagerIterate
+	return manager
+}
+
+// ManagerAll reads models of manager ordered and paged by its query,
+// instance managers returned by Filter return their stored models.
+func ManagerAll(ctx context.Context, manager ManagerI) ([]Model, error) {
+	baseManager := manager.(*base.Manager)
+	if manager.IsInstance() {
+		return baseManager.Cached(ctx)
+	}
+	// manager has no conditions but scope of soft deleted models
+	query := baseManager.Query()
+	models, err := collect(ctx, baseManager, query.Q(), nil, bound(query))
+	if err != nil {
+		return nil, err
+	}
+	return query.Page(models), nil
+}
+
+// ManagerFilter reads models found by index of field of q,
+// bucket is scanned if q has no conditions of indexed fields.
+func ManagerFilter(ctx context.Context, manager ManagerI, q Q) ([]Model, error) {
+	bucket := manager.Table().(*Bucket)
+	baseManager := manager.(*base.Manager)
+	query := baseManager.Query()
+
+	var models []Model
+	indexed := false
+	lookups := bucket.lookups(q)
+	err := bucket.db.view(ctx, func(tx *bolt.Tx) error {
+		ids, ok := bucket.lookup(tx, lookups)
+		if !ok {
+			return nil
+		}
+		indexed = true
+		for _, id := range ids {
+			value, err := bucket.get(tx, id)
+			if err != nil {
+				continue
+			}
+			model := bucket.model.Create(bucket.db, value)
+			baseManager.CheckPointers(model)
+			if baseManager.CheckQ(model, q) {
+				models = append(models, model)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if indexed {
+		query.Sort(models)
+	} else if models, err = collect(ctx, baseManager, q, nil, bound(query)); err != nil {
+		return nil, err
+	}
+	return query.Page(models), nil
+}
+
+// ManagerCount counts models of manager by scan of bucket,
+// instance managers count their stored models.
+func ManagerCount(ctx context.Context, manager ManagerI) (uint, error) {
+	baseManager := manager.(*base.Manager)
+	if manager.IsInstance() {
+		objects, err := baseManager.Cached(ctx)
+		return uint(len(objects)), err
+	}
+	query := baseManager.Query()
+	q := query.Q()
+	var count uint
+	err := scan(ctx, manager.Table().(*Bucket), func(model Model) bool {
+		baseManager.CheckPointers(model)
+		if baseManager.CheckQ(model, q) {
+			count++
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if query.Offset >= count {
+		return 0, nil
+	}
+	count -= query.Offset
+	if query.Limit > 0 && query.Limit < count {
+		count = query.Limit
+	}
+	return count, nil
+}
+
+// iteratePart is count of models read in one transaction by scan,
+// and count of ordered models found by one pass of ManagerIterate
+const iteratePart = 100
+
+// ManagerIterate streams models of bucket by cursor in order of ids.
+// Models are read by parts, so fn may write to db.
+// With Order every pass over bucket finds next part of ordered models,
+// so only one part is kept in memory.
+func ManagerIterate(ctx context.Context, manager ManagerI, q Q, fn func(model Model) bool) error {
+	baseManager := manager.(*base.Manager)
+	if len(baseManager.Query().Order) == 0 {
+		var models []Model
+		return scanParts(ctx, manager.Table().(*Bucket), func(part []Model) bool {
+			models = models[:0]
+			for _, model := range part {
+				baseManager.CheckPointers(model)
+				if baseManager.CheckQ(model, q) {
+					models = append(models, model)
+				}
+			}
+			for _, model := range models {
+				if !fn(model) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	var after Model
+	for {
+		models, err := collect(ctx, baseManager, q, after, iteratePart)
+		if err != nil {
+			return err
+		}
+		for _, model := range models {
+			if !fn(model) {
+				return nil
+			}
+		}
+		if len(models) < iteratePart {
+			return nil
+		}
+		after = models[len(models)-1]
+	}
+}
+
+// bound returns count of first ordered models needed by page of query, 0 if all are needed.
+func bound(query base.Query) int {
+	if query.Limit == 0 {
+		return 0
+	}
+	return int(query.Offset + query.Limit)
+}
+
+// collect returns models of bucket of manager satisfying q which follow model after
+// in order of query of manager, only first limit models are kept if limit is not 0.
+func collect(ctx context.Context, manager *base.Manager, q Q, after Model, limit int) ([]Model, error) {
+	query := manager.Query()
+	models := []Model{}
+	err := scan(ctx, manager.Table().(*Bucket), func(model Model) bool {
+		if after != nil && !query.Less(after, model) {
+			return true
+		}
+		manager.CheckPointers(model)
+		if !manager.CheckQ(model, q) {
+			return true
+		}
+		i := sort.Search(len(models), func(i int) bool { return query.Less(model, models[i]) })
+		if limit > 0 && i >= limit {
+			return true
+		}
+		models = append(models, nil)
+		copy(models[i+1:], models[i:])
+		models[i] = model
+		if limit > 0 && len(models) > limit {
+			models = models[:limit]
+		}
+		return true
+	})
+	return models, err
+}
+
+// scan calls fn for models of bucket in order of ids until fn returns false.
+func scan(ctx context.Context, bucket *Bucket, fn func(model Model) bool) error {
+	return scanParts(ctx, bucket, func(part []Model) bool {
+		for _, model := range part {
+			if !fn(model) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// scanParts reads models of bucket in order of ids by parts of iteratePart,
+// every part is read in one transaction and fn is called outside of it.
+func scanParts(ctx context.Context, bucket *Bucket, fn func(part []Model) bool) error {
+	var last uint
+	for {
+		part := []Model{}
+		err := bucket.db.view(ctx, func(tx *bolt.Tx) error {
+			cursor := tx.Bucket([]byte(bucket.name)).Cursor()
+			for key, value := cursor.Seek(idKey(last + 1)); key != nil && len(part) < iteratePart; key, value = cursor.Next() {
+				id, ok := keyId(key)
+				if !ok || value == nil {
+					continue
+				}
+				last = id
+				part = append(part, bucket.model.Create(bucket.db, string(value)))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if !fn(part) || len(part) < iteratePart {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package bbolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// uniqueBucket is name of nested bucket with buckets of unique sets of fields.
+// Key of unique set is encoded values of fields, value is id of model.
+const uniqueBucket = "unique"
+
+// uniqueOf returns bucket of unique set inside tx, it is created if create.
+func (bucket *Bucket) uniqueOf(tx *bolt.Tx, set []string, create bool) (*bolt.Bucket, bool, error) {
+	b := tx.Bucket([]byte(bucket.name))
+	name := []byte(strings.Join(set, ","))
+	unique := b.Bucket([]byte(uniqueBucket))
+	if unique != nil && unique.Bucket(name) != nil {
+		return unique.Bucket(name), false, nil
+	}
+	if !create {
+		return nil, false, nil
+	}
+	unique, err := b.CreateBucketIfNotExists([]byte(uniqueBucket))
+	if err != nil {
+		return nil, false, err
+	}
+	setBucket, err := unique.CreateBucket(name)
+	return setBucket, true, err
+}
+
+// reindexUnique creates missing unique sets and fills them by models of bucket,
+// existing duplicates are reported by ErrUnique.
+func (bucket *Bucket) reindexUnique(tx *bolt.Tx) error {
+	for _, set := range UniqueSets(bucket.model) {
+		setBucket, created, err := bucket.uniqueOf(tx, set, true)
+		if err != nil {
+			return err
+		}
+		if !created {
+			continue
+		}
+		err = bucket.records(tx, func(id uint, value []byte) error {
+			model := bucket.model.Create(bucket.db, string(value))
+			return bucket.putUnique(setBucket, set, id, model, true)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unique adds (or removes if !add) values of unique sets of model of id inside tx.
+func (bucket *Bucket) unique(tx *bolt.Tx, id uint, model Model, add bool) error {
+	for _, set := range UniqueSets(bucket.model) {
+		setBucket, _, _ := bucket.uniqueOf(tx, set, false)
+		if setBucket == nil {
+			if err := bucket.reindexUnique(tx); err != nil {
+				return err
+			}
+			setBucket, _, _ = bucket.uniqueOf(tx, set, false)
+		}
+		if err := bucket.putUnique(setBucket, set, id, model, add); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (bucket *Bucket) putUnique(setBucket *bolt.Bucket, set []string, id uint, model Model, add bool) error {
+	key, ok, err := uniqueKey(model, set)
+	if err != nil || !ok {
+		return err
+	}
+	if !add {
+		if string(setBucket.Get(key)) == fmt.Sprint(id) {
+			return setBucket.Delete(key)
+		}
+		return nil
+	}
+	if other := setBucket.Get(key); other != nil && string(other) != fmt.Sprint(id) {
+		return NewErrUnique(bucket.name, JSONNames(bucket.model, set))
+	}
+	return setBucket.Put(key, []byte(fmt.Sprint(id)))
+}
+
+// checkUnique returns ErrUnique if values of unique set of model belong to other model.
+func (bucket *Bucket) checkUnique(tx *bolt.Tx, id uint, model Model) error {
+	for _, set := range UniqueSets(bucket.model) {
+		setBucket, _, _ := bucket.uniqueOf(tx, set, false)
+		if setBucket == nil {
+			continue
+		}
+		key, ok, err := uniqueKey(model, set)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if other := setBucket.Get(key); other != nil && string(other) != fmt.Sprint(id) {
+			return NewErrUnique(bucket.name, JSONNames(bucket.model, set))
+		}
+	}
+	return nil
+}
+
+// uniqueKey returns encoded values of fields of set, false if some value is nil.
+func uniqueKey(model Model, set []string) ([]byte, bool, error) {
+	key := []byte{}
+	for _, field := range set {
+		value, err := Check(model, field)
+		if err != nil {
+			return nil, false, err
+		}
+		if !value.IsValid() || (value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface) && value.IsNil() {
+			return nil, false, nil
+		}
+		encoded, ok := encodeIndex(*value)
+		if !ok {
+			if encoded, err = json.Marshal(value.Interface()); err != nil {
+				return nil, false, err
+			}
+		}
+		key = binary.AppendUvarint(key, uint64(len(encoded)))
+		key = append(key, encoded...)
+	}
+	return key, true, nil
+}
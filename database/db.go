@@ -4,8 +4,10 @@ import (
 	pb "github.com/pocketbase/pocketbase"
 
 	bbolt "github.com/PoulIgorson/sub_engine_fiber/database/bbolt"
+	memory "github.com/PoulIgorson/sub_engine_fiber/database/memory"
 	pocketbase "github.com/PoulIgorson/sub_engine_fiber/database/pocketbase"
 	pocketbaselocal "github.com/PoulIgorson/sub_engine_fiber/database/pocketbaselocal"
+	sqlite "github.com/PoulIgorson/sub_engine_fiber/database/sqlite"
 )
 
 func OpenBbolt(path string) (*bbolt.DataBase, error) {
@@ -16,7 +18,14 @@ func OpenPocketBase(address, identity, password string, isAdmin bool, updateColl
 	return pocketbase.Open(address, identity, password, isAdmin, updateCollections...), nil
 }
 
-
 func OpenPocketBaseLocal(app ...*pb.PocketBase) (*pocketbaselocal.DataBase, error) {
 	return pocketbaselocal.New(app...), nil
 }
+
+func OpenSqlite(path string) (*sqlite.DataBase, error) {
+	return sqlite.Open(path)
+}
+
+func OpenMemory() (*memory.DataBase, error) {
+	return memory.New(), nil
+}
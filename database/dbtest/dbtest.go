@@ -0,0 +1,1273 @@
+// Package dbtest implements standard checks of backends of DB,
+// tests of every backend run them to keep behaviors of backends equal:
+//
+//	func TestBackend(t *testing.T) {
+//		dbtest.Run(t, dbtest.Config{
+//			Open: func(t *testing.T) DB {
+//				db, _ := OpenMemory()
+//				t.Cleanup(func() { db.Close() })
+//				return db
+//			},
+//		})
+//	}
+package dbtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// Config is backend checked by Run.
+type Config struct {
+	// Open returns db of test, it may be shared by checks,
+	// table of checks is cleared before every check.
+	Open func(t *testing.T) DB
+	// StringIds is true for backends of string ids as pocketbase, ids are uint else.
+	StringIds bool
+	// RacyVersions is true for backends reading stored version by separate request,
+	// as remote pocketbase of admin, concurrent updates of one version are not checked.
+	RacyVersions bool
+}
+
+// TestItem is model of checks, its id is uint or string by Config.
+type TestItem struct {
+	ID     any     `json:"id"`
+	Name   string  `json:"name" unique:"true" validate:"required"`
+	Year   int     `json:"year" index:"true"`
+	Price  float64 `json:"price"`
+	Active bool    `json:"active"`
+}
+
+func (item TestItem) Id() any {
+	return item.ID
+}
+
+func (TestItem) Create(db DB, data string) Model {
+	item := &TestItem{}
+	json.Unmarshal([]byte(data), item)
+	if id, ok := item.ID.(float64); ok {
+		item.ID = uint(id)
+	}
+	return item
+}
+
+func (item *TestItem) Save(table Table) error {
+	return table.Save(item)
+}
+
+func (item *TestItem) Delete(db DB) error {
+	return db.TableFromCache("test_item").Delete(item.ID)
+}
+
+// VersionItem is model of check of optimistic locking by version,
+// its id is uint or string by Config.
+type VersionItem struct {
+	ID      any    `json:"id"`
+	Name    string `json:"name"`
+	Version uint64 `json:"version"`
+}
+
+func (item VersionItem) Id() any {
+	return item.ID
+}
+
+func (VersionItem) Create(db DB, data string) Model {
+	item := &VersionItem{}
+	JSONParse([]byte(data), item)
+	if id, ok := item.ID.(float64); ok {
+		item.ID = uint(id)
+	}
+	return item
+}
+
+func (item *VersionItem) Save(table Table) error {
+	return table.Save(item)
+}
+
+func (item *VersionItem) Delete(db DB) error {
+	return db.TableFromCache("test_version_item").Delete(item.ID)
+}
+
+// SoftItem is model of check of soft delete, its id is uint or string by Config.
+type SoftItem struct {
+	ID        any       `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (item SoftItem) Id() any {
+	return item.ID
+}
+
+func (SoftItem) Create(db DB, data string) Model {
+	item := &SoftItem{}
+	JSONParse([]byte(data), item)
+	if id, ok := item.ID.(float64); ok {
+		item.ID = uint(id)
+	}
+	return item
+}
+
+func (item *SoftItem) Save(table Table) error {
+	return table.Save(item)
+}
+
+func (item *SoftItem) Delete(db DB) error {
+	return db.TableFromCache("test_soft_item").Delete(item.ID)
+}
+
+// UniqueItem is model of check of composite unique set of Model and Year,
+// its id is uint or string by Config.
+type UniqueItem struct {
+	ID    any    `json:"id"`
+	Model string `json:"model" unique:"model_year"`
+	Year  int    `json:"year" unique:"model_year"`
+	Code  string `json:"code"`
+}
+
+func (item UniqueItem) Id() any {
+	return item.ID
+}
+
+func (UniqueItem) Create(db DB, data string) Model {
+	item := &UniqueItem{}
+	JSONParse([]byte(data), item)
+	if id, ok := item.ID.(float64); ok {
+		item.ID = uint(id)
+	}
+	return item
+}
+
+func (item *UniqueItem) Save(table Table) error {
+	return table.Save(item)
+}
+
+func (item *UniqueItem) Delete(db DB) error {
+	return db.TableFromCache("test_unique_item").Delete(item.ID)
+}
+
+// HookItem is model of check of hooks, its id is uint or string by Config.
+// Hooks normalize name, reject names "reject" and "keep", fail after save of "fail"
+// and log writes as TestItem named "saved <name>" and "deleted <name>".
+type HookItem struct {
+	ID   any    `json:"id"`
+	Name string `json:"name"`
+}
+
+func (item HookItem) Id() any {
+	return item.ID
+}
+
+func (HookItem) Create(db DB, data string) Model {
+	item := &HookItem{}
+	JSONParse([]byte(data), item)
+	if id, ok := item.ID.(float64); ok {
+		item.ID = uint(id)
+	}
+	return item
+}
+
+func (item *HookItem) Save(table Table) error {
+	return table.Save(item)
+}
+
+func (item *HookItem) Delete(db DB) error {
+	return db.TableFromCache("test_hook_item").Delete(item.ID)
+}
+
+func (item *HookItem) BeforeSave(table Table) error {
+	if item.Name == "reject" {
+		return fmt.Errorf("name is rejected")
+	}
+	item.Name = strings.ToLower(strings.TrimSpace(item.Name))
+	return nil
+}
+
+func (item *HookItem) AfterSave(table Table) error {
+	if item.Name == "fail" {
+		return fmt.Errorf("save is failed")
+	}
+	return item.log(table, "saved")
+}
+
+func (item *HookItem) BeforeDelete(table Table) error {
+	if item.Name == "keep" {
+		return fmt.Errorf("item is kept")
+	}
+	return nil
+}
+
+func (item *HookItem) AfterDelete(table Table) error {
+	return item.log(table, "deleted")
+}
+
+// log saves TestItem of action to table test_item of db of table.
+func (item *HookItem) log(table Table, action string) error {
+	entry := &TestItem{ID: uint(0), Name: action + " " + item.Name}
+	if _, ok := item.ID.(string); ok {
+		entry.ID = ""
+	}
+	return table.DB().TableFromCache("test_item").Save(entry)
+}
+
+// AutoItem is model of check of auto timestamps, its id is uint or string by Config.
+type AutoItem struct {
+	ID      any       `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created_at" auto:"created"`
+	Updated PBTime    `json:"updated_at" auto:"updated"`
+}
+
+func (item AutoItem) Id() any {
+	return item.ID
+}
+
+func (AutoItem) Create(db DB, data string) Model {
+	item := &AutoItem{}
+	JSONParse([]byte(data), item)
+	if id, ok := item.ID.(float64); ok {
+		item.ID = uint(id)
+	}
+	return item
+}
+
+func (item *AutoItem) Save(table Table) error {
+	return table.Save(item)
+}
+
+func (item *AutoItem) Delete(db DB) error {
+	return db.TableFromCache("test_auto_item").Delete(item.ID)
+}
+
+// RefItem is model of check of relations inside transaction, it is deleted
+// with TestItem of OwnerID and forbids delete of TestItem of LockID.
+// Its id is uint or string by Config.
+type RefItem struct {
+	ID      any    `json:"id"`
+	Name    string `json:"name"`
+	OwnerID any    `json:"owner_id" relation:"test_item" ondelete:"cascade"`
+	LockID  any    `json:"lock_id" relation:"test_item" ondelete:"restrict"`
+}
+
+func (item RefItem) Id() any {
+	return item.ID
+}
+
+func (RefItem) Create(db DB, data string) Model {
+	item := &RefItem{}
+	JSONParse([]byte(data), item)
+	for _, id := range []*any{&item.ID, &item.OwnerID, &item.LockID} {
+		if number, ok := (*id).(float64); ok {
+			*id = uint(number)
+		}
+	}
+	return item
+}
+
+func (item *RefItem) Save(table Table) error {
+	return table.Save(item)
+}
+
+func (item *RefItem) Delete(db DB) error {
+	return db.TableFromCache("test_ref_item").Delete(item.ID)
+}
+
+type check struct {
+	name string
+	fn   func(t *testing.T, table Table, config Config)
+}
+
+var checks = []check{
+	{"CRUD", checkCRUD},
+	{"NilId", checkNilId},
+	{"DeleteMissing", checkDeleteMissing},
+	{"Batch", checkBatch},
+	{"Filter", checkFilter},
+	{"Lookups", checkLookups},
+	{"Order", checkOrder},
+	{"Aggregate", checkAggregate},
+	{"Concurrency", checkConcurrency},
+	{"Errors", checkErrors},
+	{"Version", checkVersion},
+	{"Unique", checkUnique},
+	{"Hooks", checkHooks},
+	{"Auto", checkAuto},
+	{"Tx", checkTx},
+	{"SoftDelete", checkSoftDelete},
+	{"Context", checkContext},
+}
+
+// Run runs checks of CRUD, filters, lookups, ordering, aggregations, concurrency, types of errors,
+// versions, unique sets, hooks, auto timestamps, soft delete
+// and cancelled contexts as subtests of t.
+func Run(t *testing.T, config Config) {
+	for _, check := range checks {
+		check := check
+		t.Run(check.name, func(t *testing.T) {
+			check.fn(t, openTable(t, config), config)
+		})
+	}
+}
+
+// openTable returns empty table of TestItem of db of config.
+func openTable(t *testing.T, config Config) Table {
+	t.Helper()
+	db := config.Open(t)
+	table, err := db.Table("test_item", newItem(config, "", 0))
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	if err := table.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	return table
+}
+
+// newItem returns new item with zero id of config.
+func newItem(config Config, name string, year int) *TestItem {
+	item := &TestItem{ID: uint(0), Name: name, Year: year}
+	if config.StringIds {
+		item.ID = ""
+	}
+	return item
+}
+
+// missingId returns id of config which is not stored.
+func missingId(config Config) any {
+	if config.StringIds {
+		return "dbtestmissing00"
+	}
+	return uint(1 << 30)
+}
+
+// checkId fails t if id is zero or has not type of ids of config.
+func checkId(t *testing.T, config Config, id any) {
+	t.Helper()
+	switch id := id.(type) {
+	case uint:
+		if !config.StringIds && id != 0 {
+			return
+		}
+	case string:
+		if config.StringIds && id != "" {
+			return
+		}
+	}
+	t.Fatalf("id `%v` of type %T is not id of backend", id, id)
+}
+
+// save saves items and fails t on error.
+func save(t *testing.T, table Table, items ...*TestItem) {
+	t.Helper()
+	for _, item := range items {
+		if err := table.Save(item); err != nil {
+			t.Fatalf("Save %v: %v", item.Name, err)
+		}
+	}
+}
+
+// names returns names of models joined by ",".
+func names(models []Model) string {
+	names := make([]string, len(models))
+	for i, model := range models {
+		names[i] = model.(*TestItem).Name
+	}
+	return strings.Join(names, ",")
+}
+
+func checkCRUD(t *testing.T, table Table, config Config) {
+	item := newItem(config, "a", 2000)
+	item.Price, item.Active = 1.5, true
+	save(t, table, item)
+	checkId(t, config, item.ID)
+
+	model, err := table.Get(item.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got := model.(*TestItem)
+	if got.ID != item.ID || got.Name != "a" || got.Year != 2000 || got.Price != 1.5 || !got.Active {
+		t.Fatalf("Get returns %+v, expected %+v", got, item)
+	}
+
+	got.Year = 2001
+	save(t, table, got)
+	if got.ID != item.ID {
+		t.Fatalf("id is changed by update: %v != %v", got.ID, item.ID)
+	}
+	model, err = table.Get(item.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := model.(*TestItem); got.Year != 2001 {
+		t.Fatalf("Get after update returns %+v", got)
+	}
+
+	other := newItem(config, "b", 2002)
+	save(t, table, other)
+	if other.ID == item.ID {
+		t.Fatalf("ids of items are equal: %v", other.ID)
+	}
+	if count := table.Count(); count != 2 {
+		t.Fatalf("Count is %v, expected 2", count)
+	}
+	if count := table.Manager().Count(); count != 2 {
+		t.Fatalf("Manager.Count is %v, expected 2", count)
+	}
+
+	if err := table.Delete(item.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := table.Get(item.ID); err == nil {
+		t.Fatalf("Get of deleted item returns no error")
+	}
+	if count := table.Count(); count != 1 {
+		t.Fatalf("Count after Delete is %v, expected 1", count)
+	}
+	if got := names(table.Manager().All()); got != "b" {
+		t.Fatalf("All after Delete returns %v, expected b", got)
+	}
+}
+
+func checkNilId(t *testing.T, table Table, config Config) {
+	item := &TestItem{Name: "nil"}
+	if err := table.Save(item); err != nil {
+		t.Fatalf("Save of item without id: %v", err)
+	}
+	checkId(t, config, item.ID)
+	if _, err := table.Get(item.ID); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+}
+
+func checkDeleteMissing(t *testing.T, table Table, config Config) {
+	save(t, table, newItem(config, "a", 2000))
+	if err := table.Delete(missingId(config)); err != nil {
+		t.Fatalf("Delete of missing id: %v", err)
+	}
+	if err := table.DeleteMany([]any{missingId(config)}); err != nil {
+		t.Fatalf("DeleteMany of missing id: %v", err)
+	}
+	if count := table.Count(); count != 1 {
+		t.Fatalf("Count is %v, expected 1", count)
+	}
+}
+
+func checkBatch(t *testing.T, table Table, config Config) {
+	items := []Model{newItem(config, "a", 2000), newItem(config, "b", 2001), newItem(config, "c", 2002)}
+	if err := table.SaveMany(items); err != nil {
+		t.Fatalf("SaveMany: %v", err)
+	}
+	ids := []any{}
+	for _, item := range items {
+		checkId(t, config, item.Id())
+		ids = append(ids, item.Id())
+	}
+	if count := table.Count(); count != 3 {
+		t.Fatalf("Count after SaveMany is %v, expected 3", count)
+	}
+	if err := table.DeleteMany(ids[:2]); err != nil {
+		t.Fatalf("DeleteMany: %v", err)
+	}
+	if got := names(table.Manager().All()); got != "c" {
+		t.Fatalf("All after DeleteMany returns %v, expected c", got)
+	}
+
+	failed := []Model{newItem(config, "d", 2003), newItem(config, "c", 2004)}
+	err := table.SaveMany(failed)
+	if batch, ok := err.(ErrBatch); !ok || batch.Failed() != 1 || batch.Errors[1] == nil {
+		t.Fatalf("SaveMany with duplicate returns %T: %v, expected ErrBatch of second item", err, err)
+	}
+	if got := names(table.Manager().All()); got != "c" {
+		t.Fatalf("All after failed SaveMany returns %v, expected c", got)
+	}
+	if id := failed[0].Id(); id != newItem(config, "", 0).ID {
+		t.Fatalf("rolled back SaveMany keeps id %v", id)
+	}
+	if err := table.DeleteMany([]any{ids[2], true}); err == nil {
+		t.Fatalf("DeleteMany with bad id returns no error")
+	}
+	if got := names(table.Manager().All()); got != "c" {
+		t.Fatalf("All after failed DeleteMany returns %v, expected c", got)
+	}
+
+	if err := table.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	if count := table.Count(); count != 0 {
+		t.Fatalf("Count after DeleteAll is %v, expected 0", count)
+	}
+	if got := names(table.Manager().All()); got != "" {
+		t.Fatalf("All after DeleteAll returns %v", got)
+	}
+}
+
+// saveFour saves items a, b, c, d of years 2000-2003, prices 0.5-3.5, a and c are active.
+func saveFour(t *testing.T, table Table, config Config) {
+	t.Helper()
+	for i, name := range []string{"a", "b", "c", "d"} {
+		item := newItem(config, name, 2000+i)
+		item.Price = float64(i) + 0.5
+		item.Active = i%2 == 0
+		save(t, table, item)
+	}
+}
+
+func checkFilter(t *testing.T, table Table, config Config) {
+	saveFour(t, table, config)
+	manager := table.Manager().OrderBy("Name")
+	tests := []struct {
+		q    Q
+		want string
+	}{
+		{And(Params{"Name": "b"}), "b"},
+		{And(Params{"Year>=": 2002}), "c,d"},
+		{And(Params{"Year<": 2002}), "a,b"},
+		{And(Params{"Year!=": 2001}), "a,c,d"},
+		{And(Params{"Year__in": []int{2000, 2003}}), "a,d"},
+		{And(Params{"Name__startswith": "d"}), "d"},
+		{And(Params{"Price__between": []float64{1, 3}}), "b,c"},
+		{And(Params{"Active": true}), "a,c"},
+		{And(Params{"Active": true, "Year>": 2000}), "c"},
+		{Or(Params{"Name": "a"}, Params{"Year>": 2002}), "a,d"},
+		{Not(Params{"Active": true}), "b,d"},
+		{And(Params{"Year>": 2000}, Not(Params{"Name": "c"})), "b,d"},
+	}
+	for _, test := range tests {
+		if got := names(manager.Where(test.q).All()); got != test.want {
+			t.Errorf("Where(%v) returns %v, expected %v", test.q, got, test.want)
+		}
+	}
+	if got := names(manager.Filter(Params{"Year>": 2000}, Params{"Name": "c"}).All()); got != "b,d" {
+		t.Errorf("Filter with exclude returns %v, expected b,d", got)
+	}
+	if count := manager.Filter(Params{"Active": true}).Count(); count != 2 {
+		t.Errorf("Count of Filter is %v, expected 2", count)
+	}
+	if count := manager.Filter(Params{"Name": "z"}).Count(); count != 0 {
+		t.Errorf("Count of empty Filter is %v, expected 0", count)
+	}
+}
+
+// checkLookups checks that lookups give equal results on every backend,
+// lookups which backend can not express exactly are reported by ErrLookup.
+func checkLookups(t *testing.T, table Table, config Config) {
+	for i, name := range []string{"Ab%1", "ab_2", "abc3", "xAB4"} {
+		save(t, table, newItem(config, name, 2000+i))
+	}
+	manager := table.Manager().OrderBy("Name").(ContextManager)
+	tests := []struct {
+		q    Q
+		want string
+		// backend may report ErrLookup instead of result
+		inexpressible bool
+	}{
+		{And(Params{"Name__contains": "b"}), "Ab%1,ab_2,abc3", true},
+		{And(Params{"Name__icontains": "ab"}), "Ab%1,ab_2,abc3,xAB4", false},
+		{And(Params{"Name__icontains": "%"}), "Ab%1", false},
+		{And(Params{"Name__icontains": "b_"}), "ab_2", false},
+		{And(Params{"Name__startswith": "ab"}), "ab_2,abc3", false},
+		{And(Params{"Name__startswith": "Ab%"}), "Ab%1", false},
+		{Not(Params{"Name__startswith": "ab"}), "Ab%1,xAB4", false},
+		{And(Params{"Year": 2001.0}), "ab_2", false},
+	}
+	for _, test := range tests {
+		filtered, err := manager.WhereContext(context.Background(), test.q)
+		if _, ok := err.(ErrLookup); ok && test.inexpressible {
+			continue
+		}
+		if err != nil {
+			t.Errorf("Where(%v): %v", test.q, err)
+			continue
+		}
+		if got := names(filtered.All()); got != test.want {
+			t.Errorf("Where(%v) returns %v, expected %v", test.q, got, test.want)
+		}
+	}
+
+	for _, q := range []Q{
+		And(Params{"Year>": 2000.5}),
+		And(Params{"Year__in": []float64{2001, 2001.5}}),
+		And(Params{"Year>=": -1.5}),
+	} {
+		if _, err := manager.WhereContext(context.Background(), q); err == nil {
+			t.Errorf("Where(%v) with number not representable by field returns no error", q)
+		} else if _, ok := err.(ErrLookup); !ok {
+			t.Errorf("Where(%v) returns %T: %v, expected ErrLookup", q, err, err)
+		}
+	}
+}
+
+func checkOrder(t *testing.T, table Table, config Config) {
+	saveFour(t, table, config)
+	manager := table.Manager()
+	tests := []struct {
+		manager ManagerI
+		want    string
+	}{
+		{manager.OrderBy("Year"), "a,b,c,d"},
+		{manager.OrderBy("-Price"), "d,c,b,a"},
+		{manager.OrderBy("-Active", "Year"), "a,c,b,d"},
+		{manager.OrderBy("Year").Limit(2), "a,b"},
+		{manager.OrderBy("-Year").Limit(2).Offset(1), "c,b"},
+		{manager.OrderBy("Year").Offset(3), "d"},
+	}
+	for i, test := range tests {
+		if got := names(test.manager.All()); got != test.want {
+			t.Errorf("order %v returns %v, expected %v", i, got, test.want)
+		}
+	}
+	if first := manager.OrderBy("Year").First(); first == nil || first.(*TestItem).Name != "a" {
+		t.Errorf("First returns %v, expected a", first)
+	}
+	if last := manager.OrderBy("Year").Last(); last == nil || last.(*TestItem).Name != "d" {
+		t.Errorf("Last returns %v, expected d", last)
+	}
+	if first := manager.OrderBy("-Price").Filter(Params{"Active": true}).First(); first == nil || first.(*TestItem).Name != "c" {
+		t.Errorf("First of Filter returns %v, expected c", first)
+	}
+	if count := manager.OrderBy("Year").Limit(3).Count(); count != 3 {
+		t.Errorf("Count of Limit is %v, expected 3", count)
+	}
+	visited := []string{}
+	err := manager.OrderBy("Year").Iterate(func(model Model) bool {
+		visited = append(visited, model.(*TestItem).Name)
+		return len(visited) < 2
+	}, Params{"Year>": 2000})
+	if err != nil || strings.Join(visited, ",") != "b,c" {
+		t.Errorf("Iterate visits %v, expected b,c: %v", visited, err)
+	}
+}
+
+func checkAggregate(t *testing.T, table Table, config Config) {
+	saveFour(t, table, config)
+	manager := table.Manager()
+	aggregations := []Aggregation{
+		{Func: AggSum, Field: "Price"},
+		{Func: AggAvg, Field: "Year"},
+		{Func: AggMin, Field: "Year"},
+		{Func: AggMax, Field: "Price"},
+		{Func: AggCount, Field: "Name"},
+		{Func: AggCount},
+	}
+	tests := []struct {
+		manager ManagerI
+		want    Params
+	}{
+		{manager, Params{"sum_Price": 8.0, "avg_Year": 2001.5, "min_Year": 2000, "max_Price": 3.5, "count_Name": uint(4), "count": uint(4)}},
+		{manager.Filter(Params{"Active": true}), Params{"sum_Price": 3.0, "avg_Year": 2001.0, "min_Year": 2000, "max_Price": 2.5, "count_Name": uint(2), "count": uint(2)}},
+		{manager.OrderBy("-Year").Limit(2), Params{"sum_Price": 6.0, "avg_Year": 2002.5, "min_Year": 2002, "max_Price": 3.5, "count_Name": uint(2), "count": uint(2)}},
+		{manager.Filter(Params{"Name": "z"}), Params{"sum_Price": 0.0, "avg_Year": nil, "min_Year": nil, "max_Price": nil, "count_Name": uint(0), "count": uint(0)}},
+	}
+	for i, test := range tests {
+		result, err := test.manager.Aggregate(aggregations...)
+		if err != nil {
+			t.Fatalf("Aggregate %v: %v", i, err)
+		}
+		if !reflect.DeepEqual(result, test.want) {
+			t.Errorf("Aggregate %v returns %v, expected %v", i, result, test.want)
+		}
+	}
+
+	groups, err := manager.GroupBy("Active").Aggregate(Aggregation{Func: AggSum, Field: "Year"}, Aggregation{Func: AggCount})
+	if err != nil {
+		t.Fatalf("GroupBy.Aggregate: %v", err)
+	}
+	wantGroups := []Group{
+		{Values: Params{"Active": false}, Result: Params{"sum_Year": 4004.0, "count": uint(2)}},
+		{Values: Params{"Active": true}, Result: Params{"sum_Year": 4002.0, "count": uint(2)}},
+	}
+	if !reflect.DeepEqual(groups, wantGroups) {
+		t.Errorf("GroupBy.Aggregate returns %v, expected %v", groups, wantGroups)
+	}
+	groups, err = manager.Filter(Params{"Name": "z"}).GroupBy("Active").Count()
+	if err != nil {
+		t.Fatalf("GroupBy.Count: %v", err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("GroupBy.Count of empty Filter returns %v, expected no groups", groups)
+	}
+
+	values, err := manager.Distinct("Active")
+	if err != nil {
+		t.Fatalf("Distinct: %v", err)
+	}
+	if want := []any{false, true}; !reflect.DeepEqual(values, want) {
+		t.Errorf("Distinct returns %v, expected %v", values, want)
+	}
+
+	if _, err := manager.Aggregate(Aggregation{Func: "median", Field: "Year"}); err == nil {
+		t.Errorf("Aggregate of unknown function returns no error")
+	} else if _, ok := err.(ErrNotSupported); !ok {
+		t.Errorf("Aggregate of unknown function returns %T: %v, expected ErrNotSupported", err, err)
+	}
+}
+
+func checkConcurrency(t *testing.T, table Table, config Config) {
+	const n = 20
+	items := make([]*TestItem, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range items {
+		items[i] = newItem(config, fmt.Sprint("item", i), 2000+i)
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = table.Save(items[i])
+		}(i)
+		go func() {
+			defer wg.Done()
+			table.Count()
+			table.Manager().Filter(Params{"Year>": 2010}).All()
+		}()
+	}
+	wg.Wait()
+	ids := map[any]bool{}
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent Save %v: %v", i, err)
+		}
+		checkId(t, config, items[i].ID)
+		ids[items[i].ID] = true
+	}
+	if len(ids) != n {
+		t.Fatalf("concurrent Save assigned %v distinct ids to %v items", len(ids), n)
+	}
+	if count := table.Count(); count != n {
+		t.Fatalf("Count is %v, expected %v", count, n)
+	}
+	if count := len(table.Manager().All()); count != n {
+		t.Fatalf("All returns %v items, expected %v", count, n)
+	}
+}
+
+func checkErrors(t *testing.T, table Table, config Config) {
+	item := newItem(config, "a", 2000)
+	save(t, table, item)
+
+	duplicate := newItem(config, "a", 2001)
+	err := table.Save(duplicate)
+	if _, ok := err.(ErrUnique); !ok {
+		t.Errorf("Save of duplicate returns %T: %v, expected ErrUnique", err, err)
+	} else if fields := err.(ErrUnique).Fields; !reflect.DeepEqual(fields, []string{"name"}) {
+		t.Errorf("ErrUnique has fields %v, expected [name]", fields)
+	}
+	if duplicate.ID != newItem(config, "", 0).ID {
+		t.Errorf("failed Save assigns id %v", duplicate.ID)
+	}
+
+	err = table.Save(newItem(config, "", 2002))
+	if errV, ok := err.(ValidationError); !ok {
+		t.Errorf("Save of invalid item returns %T: %v, expected ValidationError", err, err)
+	} else if _, ok := errV.Fields["name"]; !ok {
+		t.Errorf("ValidationError has fields %v, expected name", errV.Fields)
+	}
+
+	if _, err := table.Get(missingId(config)); err == nil {
+		t.Errorf("Get of missing id returns no error")
+	}
+	wrongId := any("a")
+	if config.StringIds {
+		wrongId = uint(1)
+	}
+	if _, err := table.Get(wrongId); err == nil {
+		t.Errorf("Get of id of wrong type returns no error")
+	}
+	if count := table.Count(); count != 1 {
+		t.Errorf("Count after failed saves is %v, expected 1", count)
+	}
+}
+
+func checkVersion(t *testing.T, table Table, config Config) {
+	table, err := table.DB().Table("test_version_item", &VersionItem{ID: newItem(config, "", 0).ID})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	if err := table.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	item := &VersionItem{ID: newItem(config, "", 0).ID, Name: "a"}
+	if err := table.Save(item); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if item.Version != 1 {
+		t.Fatalf("version of new item is %v, expected 1", item.Version)
+	}
+	item.Name = "b"
+	if err := table.Save(item); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	model, err := table.Get(item.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := model.(*VersionItem); got.Name != "b" || got.Version != 2 {
+		t.Fatalf("Get after update returns %+v, expected version 2", got)
+	}
+
+	first, _ := table.Get(item.ID)
+	second, _ := table.Get(item.ID)
+	if first == nil || second == nil {
+		t.Fatalf("Get of saved item returns nil")
+	}
+	first.(*VersionItem).Name = "first"
+	if err := table.Save(first); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	second.(*VersionItem).Name = "second"
+	err = table.Save(second)
+	if _, ok := err.(ErrConflict); !ok {
+		t.Errorf("Save of stale item returns %T: %v, expected ErrConflict", err, err)
+	}
+	if version := second.(*VersionItem).Version; version != 2 {
+		t.Errorf("failed Save changes version to %v", version)
+	}
+
+	if config.RacyVersions {
+		return
+	}
+	// concurrent updates of one item conflict by version or follow each other
+	const n = 20
+	model, err = table.Get(item.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	saved := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range saved {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			item := *model.(*VersionItem)
+			item.Name = fmt.Sprint("item", i)
+			saved[i] = table.Save(&item)
+		}(i)
+	}
+	wg.Wait()
+	succeeded := 0
+	for _, err := range saved {
+		if err == nil {
+			succeeded++
+		} else if _, ok := err.(ErrConflict); !ok {
+			t.Fatalf("concurrent update returns %T: %v, expected ErrConflict", err, err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%v concurrent updates of one version succeeded, expected 1", succeeded)
+	}
+}
+
+func checkUnique(t *testing.T, table Table, config Config) {
+	prototype := &UniqueItem{ID: newItem(config, "", 0).ID}
+	table, err := table.DB().Table("test_unique_item", prototype)
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	if err := table.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	item := func(model string, year int) *UniqueItem {
+		return &UniqueItem{ID: prototype.ID, Model: model, Year: year}
+	}
+	first := item("a", 2000)
+	for _, saved := range []*UniqueItem{first, item("a", 2001), item("b", 2000)} {
+		if err := table.Save(saved); err != nil {
+			t.Fatalf("Save of %v %v: %v", saved.Model, saved.Year, err)
+		}
+	}
+
+	err = table.Save(item("a", 2001))
+	if _, ok := err.(ErrUnique); !ok {
+		t.Fatalf("Save of duplicate set returns %T: %v, expected ErrUnique", err, err)
+	}
+	if fields := err.(ErrUnique).Fields; !reflect.DeepEqual(fields, []string{"model", "year"}) {
+		t.Errorf("ErrUnique has fields %v, expected [model year]", fields)
+	}
+
+	// model keeps its own values
+	first.Code = "x"
+	if err := table.Save(first); err != nil {
+		t.Fatalf("Save of unchanged set: %v", err)
+	}
+	first.Year = 2001
+	if _, ok := table.Save(first).(ErrUnique); !ok {
+		t.Errorf("update to duplicate set returns no ErrUnique")
+	}
+
+	// values of deleted model are free
+	model, err := table.Get(first.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := table.Delete(model.Id()); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := table.Save(item("a", 2000)); err != nil {
+		t.Errorf("Save of set of deleted model: %v", err)
+	}
+	if count := table.Count(); count != 3 {
+		t.Errorf("Count is %v, expected 3", count)
+	}
+}
+
+func checkHooks(t *testing.T, table Table, config Config) {
+	logs := table
+	prototype := &HookItem{ID: newItem(config, "", 0).ID}
+	table, err := table.DB().Table("test_hook_item", prototype)
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	if err := table.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	logged := func(name string) bool {
+		return logs.Manager().Filter(Params{"Name": name}).Count() == 1
+	}
+
+	item := &HookItem{ID: prototype.ID, Name: " A "}
+	if err := table.Save(item); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if model, err := table.Get(item.ID); err != nil || model.(*HookItem).Name != "a" {
+		t.Errorf("Get returns %v, %v, expected name normalized by BeforeSave", model, err)
+	}
+	if !logged("saved a") {
+		t.Errorf("AfterSave is not called")
+	}
+
+	// error of BeforeSave aborts write
+	if err := table.Save(&HookItem{ID: prototype.ID, Name: "reject"}); err == nil {
+		t.Errorf("Save rejected by BeforeSave returns no error")
+	}
+	if count := table.Count(); count != 1 {
+		t.Errorf("Count after rejected Save is %v, expected 1", count)
+	}
+
+	// error of AfterSave rolls back write and writes of hooks
+	_, noTx := table.DB().Tx(func(tx DB) error { return nil }).(ErrNotSupported)
+	if err := table.Save(&HookItem{ID: prototype.ID, Name: "fail"}); err == nil {
+		t.Errorf("Save failed by AfterSave returns no error")
+	} else if !noTx {
+		if count := table.Count(); count != 1 {
+			t.Errorf("Count after failed Save is %v, expected 1", count)
+		}
+	}
+
+	// error of BeforeDelete aborts delete
+	kept := &HookItem{ID: prototype.ID, Name: "keep"}
+	if err := table.Save(kept); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := table.Delete(kept.ID); err == nil {
+		t.Errorf("Delete rejected by BeforeDelete returns no error")
+	}
+	if _, err := table.Get(kept.ID); err != nil {
+		t.Errorf("model of rejected Delete is deleted: %v", err)
+	}
+
+	if err := table.Delete(item.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := table.Get(item.ID); err == nil {
+		t.Errorf("model is not deleted")
+	}
+	if !logged("deleted a") {
+		t.Errorf("AfterDelete is not called")
+	}
+}
+
+func checkAuto(t *testing.T, table Table, config Config) {
+	prototype := &AutoItem{ID: newItem(config, "", 0).ID}
+	table, err := table.DB().Table("test_auto_item", prototype)
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	if err := table.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	items := []*AutoItem{}
+	for _, name := range []string{"a", "b", "c"} {
+		item := &AutoItem{ID: prototype.ID, Name: name}
+		before := time.Now().Truncate(time.Millisecond)
+		if err := table.Save(item); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if item.Created.Before(before) || !item.Created.Equal(time.Time(item.Updated)) {
+			t.Errorf("Save of new item stamps created %v and updated %v, expected equal times after %v",
+				item.Created, time.Time(item.Updated), before)
+		}
+		items = append(items, item)
+		// times are stored in milliseconds
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	model, err := table.Get(items[0].ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	stored := model.(*AutoItem)
+	if !stored.Created.Equal(items[0].Created) || !time.Time(stored.Updated).Equal(time.Time(items[0].Updated)) {
+		t.Errorf("Get returns created %v and updated %v, expected saved %v and %v",
+			stored.Created, time.Time(stored.Updated), items[0].Created, time.Time(items[0].Updated))
+	}
+
+	// update keeps created, even if it is changed by model
+	stored.Name = "a2"
+	stored.Created = time.Time{}
+	if err := table.Save(stored); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	model, err = table.Get(items[0].ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	updated := model.(*AutoItem)
+	if !updated.Created.Equal(items[0].Created) {
+		t.Errorf("update changes created from %v to %v", items[0].Created, updated.Created)
+	}
+	if !time.Time(updated.Updated).After(time.Time(items[0].Updated)) {
+		t.Errorf("update keeps updated %v, expected later time", time.Time(updated.Updated))
+	}
+
+	names := func(manager ManagerI) string {
+		names := []string{}
+		for _, model := range manager.All() {
+			names = append(names, model.(*AutoItem).Name)
+		}
+		return strings.Join(names, ",")
+	}
+	if got := names(table.Manager().OrderBy("-Created")); got != "c,b,a2" {
+		t.Errorf("OrderBy(-Created) returns %v, expected c,b,a2", got)
+	}
+	if got := names(table.Manager().OrderBy("-Updated")); got != "a2,c,b" {
+		t.Errorf("OrderBy(-Updated) returns %v, expected a2,c,b", got)
+	}
+	if got := names(table.Manager().Filter(Params{"Created>": items[0].Created}).OrderBy("Created")); got != "b,c" {
+		t.Errorf("Filter by Created returns %v, expected b,c", got)
+	}
+}
+
+func checkTx(t *testing.T, table Table, config Config) {
+	db := table.DB()
+	err := db.Tx(func(tx DB) error {
+		return tx.TableFromCache(table.Name()).Save(newItem(config, "a", 2000))
+	})
+	if _, ok := err.(ErrNotSupported); ok {
+		t.Skip("transactions are not supported")
+	}
+	if err != nil {
+		t.Fatalf("Tx: %v", err)
+	}
+
+	rollback := fmt.Errorf("rollback")
+	err = db.Tx(func(tx DB) error {
+		txTable := tx.TableFromCache(table.Name())
+		if err := txTable.Save(newItem(config, "b", 2001)); err != nil {
+			return err
+		}
+		if count := txTable.Count(); count != 2 {
+			t.Errorf("Count inside Tx is %v, expected 2", count)
+		}
+		return rollback
+	})
+	if err != rollback {
+		t.Fatalf("Tx returns %v, expected error of fn", err)
+	}
+	if got := names(table.Manager().OrderBy("Name").All()); got != "a" {
+		t.Fatalf("All after rollback returns %v, expected a", got)
+	}
+	if count := table.Count(); count != 1 {
+		t.Fatalf("Count after rollback is %v, expected 1", count)
+	}
+
+	// managers of tables of tx see its writes, relations of models written in tx are applied
+	prototype := &RefItem{ID: newItem(config, "", 0).ID}
+	refs, err := db.Table("test_ref_item", prototype)
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	if err := refs.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	err = db.Tx(func(tx DB) error {
+		txTable, txRefs := tx.TableFromCache(table.Name()), tx.TableFromCache(refs.Name())
+		owner, locked := newItem(config, "owner", 2010), newItem(config, "locked", 2011)
+		save(t, txTable, owner, locked)
+		if got := names(txTable.Manager().Filter(Params{"Year>=": 2010}).OrderBy("Name").All()); got != "locked,owner" {
+			t.Errorf("Filter inside Tx returns %v, expected locked,owner", got)
+		}
+		if count := txTable.Manager().Count(); count != 3 {
+			t.Errorf("Manager.Count inside Tx is %v, expected 3", count)
+		}
+		cascaded := &RefItem{ID: prototype.ID, Name: "cascaded", OwnerID: owner.ID}
+		lock := &RefItem{ID: prototype.ID, Name: "lock", LockID: locked.ID}
+		for _, ref := range []*RefItem{cascaded, lock} {
+			if err := txRefs.Save(ref); err != nil {
+				return err
+			}
+		}
+		if err := txTable.Delete(owner.ID); err != nil {
+			return err
+		}
+		if _, err := txRefs.Get(cascaded.ID); err == nil {
+			t.Errorf("model of cascade saved inside Tx is not deleted with owner")
+		}
+		if err := txTable.Delete(locked.ID); err == nil {
+			t.Errorf("Delete of model restricted by model saved inside Tx returns no error")
+		} else if _, ok := err.(ErrRestrict); !ok {
+			t.Errorf("Delete of restricted model returns %T: %v, expected ErrRestrict", err, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Tx: %v", err)
+	}
+	if got := names(table.Manager().OrderBy("Name").All()); got != "a,locked" {
+		t.Errorf("All after Tx returns %v, expected a,locked", got)
+	}
+	if count := refs.Count(); count != 1 {
+		t.Errorf("Count of references after Tx is %v, expected 1", count)
+	}
+}
+
+func checkSoftDelete(t *testing.T, table Table, config Config) {
+	prototype := &SoftItem{ID: newItem(config, "", 0).ID}
+	table, err := table.DB().Table("test_soft_item", prototype)
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	if err := table.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	a, b := &SoftItem{ID: prototype.ID, Name: "a"}, &SoftItem{ID: prototype.ID, Name: "b"}
+	for _, item := range []*SoftItem{a, b} {
+		if err := table.Save(item); err != nil {
+			t.Fatalf("Save %v: %v", item.Name, err)
+		}
+	}
+	softNames := func(manager ManagerI) string {
+		names := []string{}
+		for _, model := range manager.OrderBy("Name").All() {
+			names = append(names, model.(*SoftItem).Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	if err := table.Delete(a.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := table.Delete(a.ID); err != nil {
+		t.Fatalf("Delete of deleted item: %v", err)
+	}
+	model, err := table.Get(a.ID)
+	if err != nil {
+		t.Fatalf("Get of soft deleted item: %v", err)
+	}
+	if model.(*SoftItem).DeletedAt.IsZero() {
+		t.Fatalf("Delete does not set deleted_at")
+	}
+	if got := softNames(table.Manager()); got != "b" {
+		t.Errorf("All after Delete returns %v, expected b", got)
+	}
+	if got := softNames(table.Manager().OnlyDeleted()); got != "a" {
+		t.Errorf("OnlyDeleted returns %v, expected a", got)
+	}
+	if count := table.Manager().Count(); count != 1 {
+		t.Errorf("Manager.Count is %v, expected 1", count)
+	}
+	if count := table.Manager().WithDeleted().Count(); count != 2 {
+		t.Errorf("WithDeleted.Count is %v, expected 2", count)
+	}
+	if count := table.Count(); count != 2 {
+		t.Errorf("Table.Count is %v, expected 2 with soft deleted item", count)
+	}
+
+	if err := table.DeleteMany([]any{b.ID}); err != nil {
+		t.Fatalf("DeleteMany: %v", err)
+	}
+	if count := table.Count(); count != 2 {
+		t.Errorf("Table.Count after DeleteMany is %v, expected 2", count)
+	}
+	if err := table.Restore(a.ID); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got := softNames(table.Manager()); got != "a" {
+		t.Errorf("All after Restore returns %v, expected a", got)
+	}
+	if err := table.HardDelete(b.ID); err != nil {
+		t.Fatalf("HardDelete: %v", err)
+	}
+	if count := table.Count(); count != 1 {
+		t.Errorf("Table.Count after HardDelete is %v, expected 1", count)
+	}
+}
+
+func checkContext(t *testing.T, table Table, config Config) {
+	contextTable, ok := table.(ContextTable)
+	if !ok {
+		t.Skip("table is not ContextTable")
+	}
+	contextManager, ok := table.Manager().(ContextManager)
+	if !ok {
+		t.Skip("manager is not ContextManager")
+	}
+	item := newItem(config, "a", 2000)
+	save(t, table, item)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	for _, c := range []struct {
+		name string
+		ctx  context.Context
+		err  error
+	}{{"cancelled", cancelled, context.Canceled}, {"expired", expired, context.DeadlineExceeded}} {
+		aborted := func(method string, err error) {
+			t.Helper()
+			if err == nil {
+				t.Errorf("%v of %v context returns no error", method, c.name)
+			} else if !errors.Is(err, c.err) && !strings.Contains(err.Error(), c.err.Error()) {
+				t.Errorf("%v of %v context returns %v, expected %v", method, c.name, err, c.err)
+			}
+		}
+
+		_, err := contextTable.GetContext(c.ctx, item.ID)
+		aborted("GetContext", err)
+		aborted("SaveContext", contextTable.SaveContext(c.ctx, newItem(config, "b"+c.name, 2001)))
+		updated := *item
+		updated.Year = 2002
+		aborted("SaveContext of update", contextTable.SaveContext(c.ctx, &updated))
+		aborted("DeleteContext", contextTable.DeleteContext(c.ctx, item.ID))
+
+		_, err = contextManager.AllContext(c.ctx)
+		aborted("AllContext", err)
+		_, err = contextManager.FilterContext(c.ctx, Params{"Year": 2000})
+		aborted("FilterContext", err)
+		_, err = contextManager.CountContext(c.ctx)
+		aborted("CountContext", err)
+		aborted("IterateContext", contextManager.IterateContext(c.ctx, func(model Model) bool { return true }))
+	}
+
+	// aborted calls change nothing
+	if count := table.Count(); count != 1 {
+		t.Errorf("Count after aborted calls is %v, expected 1", count)
+	}
+	model, err := table.Get(item.ID)
+	if err != nil {
+		t.Fatalf("Get after aborted DeleteContext: %v", err)
+	}
+	if got := model.(*TestItem); got.Year != 2000 {
+		t.Errorf("aborted SaveContext changes year to %v", got.Year)
+	}
+}
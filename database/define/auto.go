@@ -0,0 +1,93 @@
+package define
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// Values of tag `auto` of time fields filled by Save, they are names
+// of system fields of pocketbase records mapped to them.
+const (
+	AutoCreated = "created"
+	AutoUpdated = "updated"
+)
+
+// AutoField returns name of field of type time.Time or PBTime tagged `auto:"kind"`.
+func AutoField(model Model, kind string) string {
+	if model == nil {
+		return ""
+	}
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil {
+		return ""
+	}
+	modelT := vModel.Type()
+	for i := 0; i < modelT.NumField(); i++ {
+		if field := modelT.Field(i); autoKind(field) == kind {
+			return field.Name
+		}
+	}
+	return ""
+}
+
+// autoKind returns value of tag `auto` of time field or empty string.
+func autoKind(field reflect.StructField) string {
+	kind := field.Tag.Get("auto")
+	if (kind != AutoCreated && kind != AutoUpdated) || !field.IsExported() {
+		return ""
+	}
+	if field.Type != reflect.TypeOf(time.Time{}) && field.Type != reflect.TypeOf(PBTime{}) {
+		return ""
+	}
+	return kind
+}
+
+// autoKindOf returns value of tag `auto` of field of model.
+func autoKindOf(model Model, name string) string {
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil {
+		return ""
+	}
+	field, ok := vModel.Type().FieldByName(name)
+	if !ok {
+		return ""
+	}
+	return autoKind(field)
+}
+
+// MapAutoFields copies system fields created and updated of data of pocketbase record
+// to json names of auto fields of model.
+func MapAutoFields(model Model, data map[string]any) {
+	for _, kind := range []string{AutoCreated, AutoUpdated} {
+		if json := autoJSON(model, kind); json != "" {
+			data[json] = data[kind]
+		}
+	}
+}
+
+// DropAutoFields removes auto fields of model from data of pocketbase record,
+// pocketbase sets system fields itself.
+func DropAutoFields(model Model, data map[string]any) {
+	for _, kind := range []string{AutoCreated, AutoUpdated} {
+		if json := autoJSON(model, kind); json != "" {
+			delete(data, json)
+		}
+	}
+}
+
+// autoJSON returns json name of field `auto:"kind"` of model.
+func autoJSON(model Model, kind string) string {
+	name := AutoField(model, kind)
+	if name == "" {
+		return ""
+	}
+	json, _, _ := strings.Cut(GetTagField(model, name, "json"), ",")
+	if json == "-" {
+		return ""
+	}
+	return json
+}
@@ -0,0 +1,46 @@
+package define
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+type autoCar struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created_at,omitempty" auto:"created"`
+	Updated PBTime    `json:"updated_at" auto:"updated"`
+	Seen    time.Time `json:"seen" auto:"seen"`
+	Other   string    `json:"other" auto:"created"`
+}
+
+func (car autoCar) Id() any                     { return car.ID }
+func (autoCar) Create(db DB, data string) Model { return &autoCar{} }
+func (car *autoCar) Save(table Table) error     { return table.Save(car) }
+func (car *autoCar) Delete(db DB) error         { return nil }
+
+func TestAutoFields(t *testing.T) {
+	if field := AutoField(&autoCar{}, AutoCreated); field != "Created" {
+		t.Errorf("AutoField of created is %v, expected Created", field)
+	}
+	if field := AutoField(&autoCar{}, AutoUpdated); field != "Updated" {
+		t.Errorf("AutoField of updated is %v, expected Updated", field)
+	}
+	if field := AutoField(&filterCar{}, AutoCreated); field != "" {
+		t.Errorf("AutoField of model without auto fields is %v", field)
+	}
+
+	data := map[string]any{"id": "a", "name": "x", "created": "2024-01-02 03:04:05.000Z", "updated": "2024-01-03 03:04:05.000Z"}
+	MapAutoFields(&autoCar{}, data)
+	if data["created_at"] != data["created"] || data["updated_at"] != data["updated"] {
+		t.Errorf("MapAutoFields returns %v, expected created_at and updated_at", data)
+	}
+	DropAutoFields(&autoCar{}, data)
+	want := map[string]any{"id": "a", "name": "x", "created": "2024-01-02 03:04:05.000Z", "updated": "2024-01-03 03:04:05.000Z"}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("DropAutoFields returns %v, expected %v", data, want)
+	}
+}
@@ -3,10 +3,12 @@ package define
 import (
 	"net/url"
 	"reflect"
+	"strings"
 	"time"
 
 	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
 )
 
 func GetNameModel(model Model) string {
@@ -71,11 +73,31 @@ func GetType(valueV reflect.Value) string {
 }
 
 func getPBField(fieldT reflect.StructField, modelV reflect.Value) map[string]any {
-	if fieldT.Name == "ID" {
+	if fieldT.Name == "ID" || autoKind(fieldT) != "" {
+		// auto fields are system fields of records
 		return nil
 	}
+	name, _, _ := strings.Cut(fieldT.Tag.Get("json"), ",")
 	data := map[string]any{
-		"name": fieldT.Tag.Get("json"),
+		"name": name,
+	}
+	if IsManyToMany(fieldT) {
+		data["type"] = "relation"
+		data["options"] = map[string]any{
+			"collectionId":  ManyTable(fieldT),
+			"cascadeDelete": false,
+			"maxSelect":     nil,
+		}
+		return data
+	}
+	if table, _, _ := strings.Cut(fieldT.Tag.Get("relation"), ","); table != "" {
+		data["type"] = "relation"
+		data["options"] = map[string]any{
+			"collectionId":  table,
+			"cascadeDelete": fieldT.Tag.Get("ondelete") == OnDeleteCascade,
+			"maxSelect":     1,
+		}
+		return data
 	}
 	if fieldT.Tag.Get("typePB") != "" {
 		data["type"] = fieldT.Tag.Get("typePB")
@@ -123,6 +145,25 @@ func CreateDataCollection(name string, model Model) (map[string]any, error) {
 		}
 	}
 	data["schema"] = schema
+	data["indexes"] = uniqueIndexes(name, model)
 
 	return data, nil
 }
+
+// PBSort returns pocketbase sort of order by names of fields of model.
+func PBSort(model Model, order []string) string {
+	fields := []string{}
+	for _, field := range order {
+		prefix := ""
+		if strings.HasPrefix(field, "-") {
+			prefix, field = "-", field[1:]
+		}
+		if kind := autoKindOf(model, field); kind != "" {
+			field = kind
+		} else if tag := GetTagField(model, field, "json"); tag != "" && tag != "-" {
+			field = tag
+		}
+		fields = append(fields, prefix+field)
+	}
+	return strings.Join(fields, ",")
+}
@@ -0,0 +1,442 @@
+package define
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// operators of keys of Params, two-char operators are first
+var operators = []string{"!=", "<=", ">=", "!~", "<", ">", "=", "~"}
+
+// negations of operators
+var negations = map[string]string{
+	"=": "!=", "!=": "=",
+	"<": ">=", ">=": "<",
+	">": "<=", "<=": ">",
+	"~": "!~", "!~": "~",
+}
+
+// lookups of keys of Params written as `Field__lookup`.
+// PBFilter of pocketbase backends returns ErrLookup for `__contains` of field
+// which is not multiple value field, pocketbase has case insensitive contains only,
+// and for `__regex`, pocketbase has no regular expressions.
+var lookups = []string{"in", "contains", "icontains", "startswith", "isnull", "between", "regex"}
+
+// SplitKey returns name of field and operator of key of Params,
+// operator is "=" if key has not it. Lookups are returned with prefix "__".
+func SplitKey(key string) (string, string) {
+	if i := strings.LastIndex(key, "__"); i > 0 {
+		for _, lookup := range lookups {
+			if key[i+2:] == lookup {
+				return key[:i], key[i:]
+			}
+		}
+	}
+	for _, op := range operators {
+		if len(key) > len(op) && strings.HasSuffix(key, op) {
+			return key[:len(key)-len(op)], op
+		}
+	}
+	return key, "="
+}
+
+// SortedKeys returns keys of params in stable order.
+func SortedKeys(params Params) []string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PBFilter returns pocketbase filter of q, names of fields of model are replaced by json tags.
+// Empty filter matches all records.
+func PBFilter(model Model, q Q) (string, error) {
+	return pbFilter(model, q, false)
+}
+
+// pbFalse is filter matching no records
+const pbFalse = `id=""`
+
+func pbFilter(model Model, q Q, negate bool) (string, error) {
+	if q.Not {
+		negate = !negate
+	}
+	or := q.IsOr() != negate
+
+	terms := []string{}
+	if len(q.Params) > 0 {
+		term, err := pbParams(model, q.Params, negate)
+		if err != nil {
+			return "", err
+		}
+		terms = append(terms, term)
+	}
+	for _, child := range q.Children {
+		term, err := pbFilter(model, child, negate)
+		if err != nil {
+			return "", err
+		}
+		terms = append(terms, term)
+	}
+	return pbJoin(terms, or), nil
+}
+
+// pbParams returns conditions of params joined by And, or by Or if negate.
+func pbParams(model Model, params Params, negate bool) (string, error) {
+	terms := []string{}
+	for _, key := range SortedKeys(params) {
+		field, op := SplitKey(key)
+		field, list, anyOf := pbPath(model, field)
+		if anyOf && negate {
+			return "", NewErrLookup(key, "condition on multiple relation can not be negated")
+		}
+		term, err := pbTerm(field, op, params[key], negate, list, anyOf)
+		if err != nil {
+			return "", NewErrLookup(key, err.Error())
+		}
+		terms = append(terms, term)
+	}
+	return pbJoin(terms, negate), nil
+}
+
+// pbPath returns pocketbase name of field of model, names of fields of related models
+// are joined by "__", `Groups__Name` is `groups.name`. list is true for multiple value field,
+// anyOf is true for field of related models of multiple relation.
+func pbPath(model Model, field string) (path string, list bool, anyOf bool) {
+	names := []string{}
+	current := model
+	parts := strings.Split(field, "__")
+	for i, part := range parts {
+		last := i == len(parts)-1
+		structField, related, ok := relationField(current, part)
+		if !ok {
+			names = append(names, part)
+			current = nil
+			continue
+		}
+		name, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+		if kind := autoKind(structField); kind != "" {
+			name = kind
+		} else if name == "" || name == "-" {
+			name = part
+		}
+		names = append(names, name)
+		if IsManyToMany(structField) {
+			list = list || last
+			anyOf = anyOf || !last
+		} else if last {
+			list = structField.Type.Kind() == reflect.Slice
+		}
+		current = related
+	}
+	return strings.Join(names, "."), list, anyOf
+}
+
+// relationField returns field of model by name, field of relation is returned for its pointer.
+// related is empty related model of relation, many-to-many field, or nil.
+func relationField(model Model, name string) (field reflect.StructField, related Model, ok bool) {
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil {
+		return field, nil, false
+	}
+	modelT := vModel.Type()
+	field, ok = modelT.FieldByName(name)
+	if ok && IsManyToMany(field) {
+		return field, reflect.New(field.Type).Interface().(ManyToManyField).Related(), true
+	}
+	for _, relation := range ModelRelations(model) {
+		if relation.Pointer == "" || (relation.Pointer != name && relation.Field != name) {
+			continue
+		}
+		field, _ = modelT.FieldByName(relation.Field)
+		pointer, _ := modelT.FieldByName(relation.Pointer)
+		related, _ = reflect.New(pointer.Type.Elem()).Interface().(Model)
+		return field, related, true
+	}
+	return field, nil, ok
+}
+
+// pbTerm returns condition of field, list is true for multiple value fields,
+// anyOf is true for fields of related models of multiple relation.
+// Operator `~` of pocketbase is case insensitive, so it expresses `__icontains` only,
+// `__startswith` is range of strings starting with value.
+func pbTerm(field, op string, value any, negate, list, anyOf bool) (string, error) {
+	switch op {
+	case "__in":
+		if !isList(value) {
+			return "", fmt.Errorf("value of type %T is not list", value)
+		}
+		terms := []string{}
+		items := reflect.ValueOf(value)
+		for i := 0; i < items.Len(); i++ {
+			lookup := "="
+			if list {
+				lookup = "__contains"
+			}
+			term, err := pbTerm(field, lookup, items.Index(i).Interface(), negate, list, anyOf)
+			if err != nil {
+				return "", err
+			}
+			terms = append(terms, term)
+		}
+		return pbJoin(terms, !negate), nil
+	case "__contains":
+		if list {
+			if negate {
+				return "", fmt.Errorf("lookup `%v` of multiple value field can not be negated", op)
+			}
+			return pbTerm(field, "?=", value, false, false, false)
+		}
+		return "", fmt.Errorf("pocketbase has no case sensitive contains, use `__icontains`")
+	case "__icontains":
+		if list {
+			return "", fmt.Errorf("pocketbase has no case insensitive equality of items")
+		}
+		return pbTerm(field, "~", pbLike(fmt.Sprint(value)), negate, false, anyOf)
+	case "__startswith":
+		prefix, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("value of type %T is not string", value)
+		}
+		if anyOf {
+			// bounds could be satisfied by different related models
+			return "", fmt.Errorf("lookup `%v` of multiple relation is not supported", op)
+		}
+		lower, err := pbTerm(field, ">=", prefix, negate, false, anyOf)
+		if err != nil {
+			return "", err
+		}
+		upper, ok := prefixEnd(prefix)
+		if !ok {
+			return lower, nil
+		}
+		upperTerm, err := pbTerm(field, "<", upper, negate, false, anyOf)
+		if err != nil {
+			return "", err
+		}
+		return pbJoin([]string{lower, upperTerm}, negate), nil
+	case "__isnull":
+		null, ok := value.(bool)
+		if !ok {
+			return "", fmt.Errorf("value of type %T is not bool", value)
+		}
+		prefix := ""
+		if anyOf {
+			prefix = "?"
+		}
+		if null == negate {
+			return field + prefix + "!=null", nil
+		}
+		return field + prefix + "=null", nil
+	case "__between":
+		bounds := reflect.ValueOf(value)
+		if !isList(value) || bounds.Len() != 2 {
+			return "", fmt.Errorf("value of type %T is not pair of bounds", value)
+		}
+		lower, err := pbTerm(field, ">=", bounds.Index(0).Interface(), negate, false, anyOf)
+		if err != nil {
+			return "", err
+		}
+		upper, err := pbTerm(field, "<=", bounds.Index(1).Interface(), negate, false, anyOf)
+		if err != nil {
+			return "", err
+		}
+		return pbJoin([]string{lower, upper}, negate), nil
+	case "__regex":
+		return "", fmt.Errorf("pocketbase has no regular expressions")
+	}
+
+	if negate {
+		negation, ok := negations[op]
+		if !ok {
+			return "", fmt.Errorf("operator `%v` can not be negated", op)
+		}
+		op = negation
+	}
+	literal, err := PBValue(value)
+	if err != nil {
+		return "", err
+	}
+	if anyOf && !strings.HasPrefix(op, "?") {
+		op = "?" + op
+	}
+	return field + op + literal, nil
+}
+
+// pbLike returns pattern of LIKE containing value, wildcards of value are escaped.
+func pbLike(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+	return "%" + value + "%"
+}
+
+// prefixEnd returns least string greater than all strings starting with prefix,
+// false if there is not such string. Order of utf-8 bytes is order of runes,
+// so last rune of prefix is incremented.
+func prefixEnd(prefix string) (string, bool) {
+	runes := []rune(prefix)
+	for len(runes) > 0 && runes[len(runes)-1] == utf8.MaxRune {
+		runes = runes[:len(runes)-1]
+	}
+	if len(runes) == 0 {
+		return "", false
+	}
+	last := runes[len(runes)-1] + 1
+	if last >= 0xD800 && last <= 0xDFFF {
+		// surrogates are not valid runes
+		last = 0xE000
+	}
+	runes[len(runes)-1] = last
+	return string(runes), true
+}
+
+// ConvertExact converts value to type if value is not changed by conversion.
+func ConvertExact(value reflect.Value, typ reflect.Type) (reflect.Value, bool) {
+	if !value.IsValid() || !value.CanConvert(typ) {
+		return value, false
+	}
+	if (value.Kind() == reflect.String) != (typ.Kind() == reflect.String) {
+		return value, false
+	}
+	if value.CanInt() && value.Int() < 0 && typ.Kind() >= reflect.Uint && typ.Kind() <= reflect.Uintptr {
+		return value, false
+	}
+	converted := value.Convert(typ)
+	if converted.CanConvert(value.Type()) && !converted.Convert(value.Type()).Equal(value) {
+		return value, false
+	}
+	return converted, true
+}
+
+// CheckValues returns ErrLookup for number compared with number field of model
+// by condition of q if the number can not be represented by type of field exactly,
+// as 1999.5 or -1 compared with uint field.
+func CheckValues(model Model, q Q) error {
+	for _, key := range SortedKeys(q.Params) {
+		field, op := SplitKey(key)
+		fieldValue, err := Check(model, field)
+		if err != nil || !IsNumber(fieldValue.Kind()) {
+			continue
+		}
+		values := []any{q.Params[key]}
+		switch op {
+		case "=", "!=", "<", ">", "<=", ">=":
+		case "__in", "__between":
+			if !isList(values[0]) {
+				continue
+			}
+			items := reflect.ValueOf(values[0])
+			values = make([]any, items.Len())
+			for i := range values {
+				values[i] = items.Index(i).Interface()
+			}
+		default:
+			continue
+		}
+		for _, value := range values {
+			v := reflect.ValueOf(value)
+			if !v.IsValid() || !IsNumber(v.Kind()) {
+				continue
+			}
+			if _, ok := ConvertExact(v, fieldValue.Type()); !ok {
+				return NewErrLookup(key, fmt.Sprintf("%v can not be represented by %v", value, fieldValue.Type()))
+			}
+		}
+	}
+	for _, child := range q.Children {
+		if err := CheckValues(model, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsNumber reports if kind is kind of integer or float.
+func IsNumber(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
+
+func isList(value any) bool {
+	kind := reflect.ValueOf(value).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+// pbJoin joins terms, empty term is true.
+func pbJoin(terms []string, or bool) string {
+	if or {
+		if len(terms) == 0 {
+			return pbFalse
+		}
+		for _, term := range terms {
+			if term == "" {
+				return ""
+			}
+		}
+		if len(terms) == 1 {
+			return terms[0]
+		}
+		return "(" + strings.Join(terms, "||") + ")"
+	}
+	nonEmpty := []string{}
+	for _, term := range terms {
+		if term != "" {
+			nonEmpty = append(nonEmpty, term)
+		}
+	}
+	if len(nonEmpty) <= 1 {
+		return strings.Join(nonEmpty, "")
+	}
+	return "(" + strings.Join(nonEmpty, "&&") + ")"
+}
+
+// PBValue returns value as literal of pocketbase filter.
+// Slices, maps and structs other than time have no literal.
+func PBValue(value any) (string, error) {
+	if value == nil {
+		return "null", nil
+	}
+	if model, ok := value.(Model); ok && reflect.ValueOf(model).Kind() == reflect.Pointer && !reflect.ValueOf(model).IsNil() {
+		value = model.Id()
+	}
+	switch v := value.(type) {
+	case string:
+		return pbString(v), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	case time.Time:
+		return pbString(v.UTC().Format("2006-01-02 15:04:05.000Z")), nil
+	case PBTime:
+		return pbString(time.Time(v).UTC().Format("2006-01-02 15:04:05.000Z")), nil
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(value), nil
+	case reflect.String:
+		return pbString(reflect.ValueOf(value).String()), nil
+	case reflect.Bool:
+		return fmt.Sprint(reflect.ValueOf(value).Bool()), nil
+	case reflect.Pointer:
+		if reflect.ValueOf(value).IsNil() {
+			return "null", nil
+		}
+		return PBValue(reflect.ValueOf(value).Elem().Interface())
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct, reflect.Func, reflect.Chan:
+		return "", fmt.Errorf("value of type %T has no pocketbase literal", value)
+	}
+	return pbString(fmt.Sprint(value)), nil
+}
+
+func pbString(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,128 @@
+package define
+
+import (
+	"testing"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+type filterCar struct {
+	ID    string  `json:"id"`
+	Name  string  `json:"name"`
+	Year  uint    `json:"year"`
+	Price float64 `json:"price"`
+}
+
+func (car filterCar) Id() any                     { return car.ID }
+func (filterCar) Create(db DB, data string) Model { return &filterCar{} }
+func (car *filterCar) Save(table Table) error     { return table.Save(car) }
+func (car *filterCar) Delete(db DB) error         { return nil }
+
+func TestPBFilterQ(t *testing.T) {
+	tests := []struct {
+		name string
+		q    Q
+		want string
+	}{
+		{"Empty", And(), ``},
+		{"Params", And(Params{"Name": "a", "Year>": 2000}), `(name="a"&&year>2000)`},
+		{"Or", Or(Params{"Name": "a"}, Params{"Name": "b"}), `(name="a"||name="b")`},
+		{"Not", Not(Params{"Name": "a", "Year<=": 2000}), `(name!="a"||year>2000)`},
+		{"NotOr", Not(Or(Params{"Name": "a"}, Params{"Year": 2000})), `(name!="a"&&year!=2000)`},
+		{"Nested", And(Params{"Price>=": 1.5}, Or(Params{"Name": "a"}, Not(Params{"Year": 2000}))), `(price>=1.5&&(name="a"||year!=2000))`},
+		{"EmptyOr", Or(), pbFalse},
+		{"Exclude", FilterQ(Params{"Name": "a"}, Params{"Year": 2000, "Price": 1.5}), `(name="a"&&(price!=1.5&&year!=2000))`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := PBFilter(&filterCar{}, test.q)
+			if err != nil {
+				t.Fatalf("PBFilter: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("PBFilter = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPBFilterLookups(t *testing.T) {
+	tests := []struct {
+		name string
+		q    Q
+		want string
+	}{
+		{"In", And(Params{"Year__in": []int{2000, 2001}}), `(year=2000||year=2001)`},
+		{"IContains", And(Params{"Name__icontains": `a%_\`}), `name~"%a\%\_\\%"`},
+		{"StartsWith", And(Params{"Name__startswith": "ab"}), `(name>="ab"&&name<"ac")`},
+		{"NotStartsWith", Not(Params{"Name__startswith": "ab"}), `(name<"ab"||name>="ac")`},
+		{"StartsWithEmpty", And(Params{"Name__startswith": ""}), `name>=""`},
+		{"IsNull", And(Params{"Name__isnull": true}), `name=null`},
+		{"Between", And(Params{"Price__between": []float64{1, 2}}), `(price>=1&&price<=2)`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := PBFilter(&filterCar{}, test.q)
+			if err != nil {
+				t.Fatalf("PBFilter: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("PBFilter = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	for _, q := range []Q{
+		And(Params{"Name__contains": "a"}),
+		And(Params{"Name__startswith": 1}),
+		And(Params{"Name__regex": "^a"}),
+	} {
+		if _, err := PBFilter(&filterCar{}, q); err == nil {
+			t.Errorf("PBFilter(%v) returns no error", q)
+		}
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+		ok     bool
+	}{
+		{"ab", "ac", true},
+		{"é", "ê", true},
+		{"a\U0010FFFF", "b", true},
+		{"\U0010FFFF", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		if got, ok := prefixEnd(test.prefix); got != test.want || ok != test.ok {
+			t.Errorf("prefixEnd(%q) = %q, %v, want %q, %v", test.prefix, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestCheckValues(t *testing.T) {
+	valid := []Q{
+		And(Params{"Year": 2000.0}),
+		And(Params{"Price>": 1}),
+		And(Params{"Year__between": []float64{1999, 2001}}),
+		And(Params{"Name": "a"}),
+	}
+	for _, q := range valid {
+		if err := CheckValues(&filterCar{}, q); err != nil {
+			t.Errorf("CheckValues(%v): %v", q, err)
+		}
+	}
+	invalid := []Q{
+		And(Params{"Year>": 1999.5}),
+		Or(Params{"Name": "a"}, Params{"Year": -1}),
+		And(Params{"Year__in": []float64{2000, 2000.5}}),
+	}
+	for _, q := range invalid {
+		if _, ok := CheckValues(&filterCar{}, q).(ErrLookup); !ok {
+			t.Errorf("CheckValues(%v) returns no ErrLookup", q)
+		}
+	}
+}
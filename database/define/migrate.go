@@ -0,0 +1,255 @@
+package define
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// MigrationsCollection is name of collection of log of applied migrations.
+const MigrationsCollection = "schema_migrations"
+
+// Operations of SchemaChange
+const (
+	SchemaAdd     = "add"
+	SchemaRemove  = "remove"
+	SchemaRename  = "rename"
+	SchemaRetype  = "retype"  // field is recreated, values are lost
+	SchemaIndexes = "indexes" // unique indexes of models are replaced, other indexes are kept
+)
+
+// SchemaChange is operation of migration of schema of collection.
+type SchemaChange struct {
+	Op    string `json:"op"`
+	Field string `json:"field,omitempty"`
+	To    string `json:"to,omitempty"`   // new name of renamed field
+	Type  string `json:"type,omitempty"` // type of added or retyped field
+}
+
+func (change SchemaChange) String() string {
+	switch change.Op {
+	case SchemaAdd:
+		return fmt.Sprintf("add %v %v", change.Field, change.Type)
+	case SchemaRemove:
+		return fmt.Sprintf("remove %v", change.Field)
+	case SchemaRename:
+		return fmt.Sprintf("rename %v to %v", change.Field, change.To)
+	case SchemaRetype:
+		return fmt.Sprintf("retype %v to %v", change.Field, change.Type)
+	case SchemaIndexes:
+		return "replace unique indexes"
+	}
+	return change.Op
+}
+
+// Migration is changes bringing collection to schema of model.
+// Schema and Indexes are full schema and indexes of collection after migration,
+// ids of kept and renamed fields of collection are preserved.
+// Indexes not created for unique fields, e.g. added by admin, are kept.
+type Migration struct {
+	Collection string
+	Version    uint
+	Changes    []SchemaChange
+
+	Schema  []map[string]any
+	Indexes []string
+}
+
+// String returns plan of migration, one change on line.
+func (migration Migration) String() string {
+	if len(migration.Changes) == 0 {
+		return fmt.Sprintf("%v: schema is up to date", migration.Collection)
+	}
+	lines := []string{fmt.Sprintf("%v: migration %v", migration.Collection, migration.Version)}
+	for _, change := range migration.Changes {
+		lines = append(lines, "  "+change.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PlanMigration returns migration of collection from current schema and indexes
+// to schema of model. Renamed field is tagged by its previous name, `was:"name"`.
+func PlanMigration(name string, model Model, current []map[string]any, currentIndexes []string) (Migration, error) {
+	data, err := CreateDataCollection(name, model)
+	if err != nil {
+		return Migration{}, err
+	}
+	target := data["schema"].([]map[string]any)
+	migration := Migration{Collection: name, Indexes: data["indexes"].([]string)}
+
+	existing := map[string]map[string]any{}
+	for _, field := range current {
+		existing[fmt.Sprint(field["name"])] = field
+	}
+	was := previousNames(model)
+
+	kept := map[string]bool{}
+	for _, field := range target {
+		fieldName := fmt.Sprint(field["name"])
+		old, ok := existing[fieldName]
+		if !ok && was[fieldName] != "" && existing[was[fieldName]] != nil {
+			old = existing[was[fieldName]]
+			migration.Changes = append(migration.Changes, SchemaChange{Op: SchemaRename, Field: was[fieldName], To: fieldName})
+			ok = true
+		}
+		switch {
+		case !ok:
+			migration.Changes = append(migration.Changes, SchemaChange{Op: SchemaAdd, Field: fieldName, Type: fmt.Sprint(field["type"])})
+		case old["type"] != field["type"]:
+			migration.Changes = append(migration.Changes, SchemaChange{Op: SchemaRetype, Field: fieldName, Type: fmt.Sprint(field["type"])})
+			kept[fmt.Sprint(old["name"])] = true
+		default:
+			// keep id and options of field
+			merged := map[string]any{}
+			for key, value := range old {
+				merged[key] = value
+			}
+			merged["name"] = fieldName
+			field = merged
+			kept[fmt.Sprint(old["name"])] = true
+		}
+		migration.Schema = append(migration.Schema, field)
+	}
+
+	for _, field := range current {
+		fieldName := fmt.Sprint(field["name"])
+		if !kept[fieldName] && field["system"] != true {
+			migration.Changes = append(migration.Changes, SchemaChange{Op: SchemaRemove, Field: fieldName})
+		}
+	}
+	unique := []string{}
+	for _, index := range currentIndexes {
+		if isUniqueIndex(index) {
+			unique = append(unique, index)
+		} else {
+			migration.Indexes = append(migration.Indexes, index)
+		}
+	}
+	if strings.Join(unique, ";") != strings.Join(data["indexes"].([]string), ";") {
+		migration.Changes = append(migration.Changes, SchemaChange{Op: SchemaIndexes})
+	}
+	return migration, nil
+}
+
+// isUniqueIndex reports if index is created by uniqueIndexes.
+func isUniqueIndex(index string) bool {
+	return strings.HasPrefix(index, "CREATE UNIQUE INDEX `idx_unique_")
+}
+
+// Additive returns part of migration keeping data of collection of current schema and indexes:
+// removed and retyped fields are kept as they are, indexes are only added.
+// Skipped changes are returned too, they are applied by full migration only.
+func (migration Migration) Additive(current []map[string]any, currentIndexes []string) (Migration, []SchemaChange) {
+	existing := map[string]map[string]any{}
+	for _, field := range current {
+		existing[fmt.Sprint(field["name"])] = field
+	}
+	renamed := map[string]string{} // previous names by new names
+	retyped := map[string]bool{}
+	for _, change := range migration.Changes {
+		switch change.Op {
+		case SchemaRename:
+			renamed[change.To] = change.Field
+		case SchemaRetype:
+			retyped[change.Field] = true
+		}
+	}
+
+	additive := Migration{Collection: migration.Collection, Version: migration.Version}
+	skipped := []SchemaChange{}
+	for _, change := range migration.Changes {
+		switch {
+		case change.Op == SchemaRemove:
+			additive.Schema = append(additive.Schema, existing[change.Field])
+			skipped = append(skipped, change)
+		case change.Op == SchemaRetype, change.Op == SchemaRename && retyped[change.To]:
+			skipped = append(skipped, change)
+		case change.Op == SchemaIndexes:
+		default:
+			additive.Changes = append(additive.Changes, change)
+		}
+	}
+	for _, field := range migration.Schema {
+		fieldName := fmt.Sprint(field["name"])
+		if retyped[fieldName] {
+			// old field is kept with its type and name
+			if previous, ok := renamed[fieldName]; ok {
+				fieldName = previous
+			}
+			field = existing[fieldName]
+		}
+		additive.Schema = append(additive.Schema, field)
+	}
+
+	additive.Indexes = append(additive.Indexes, currentIndexes...)
+	added := false
+	for _, index := range migration.Indexes {
+		if !contains(currentIndexes, index) {
+			additive.Indexes = append(additive.Indexes, index)
+			added = true
+		}
+	}
+	for _, index := range currentIndexes {
+		if !contains(migration.Indexes, index) {
+			skipped = append(skipped, SchemaChange{Op: SchemaIndexes})
+			break
+		}
+	}
+	if added {
+		additive.Changes = append(additive.Changes, SchemaChange{Op: SchemaIndexes})
+	}
+	return additive, skipped
+}
+
+// contains reports if values contain value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// previousNames returns json names of fields tagged `was:"name"` by their previous names.
+func previousNames(model Model) map[string]string {
+	names := map[string]string{}
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil {
+		return names
+	}
+	modelT := vModel.Type()
+	for i := 0; i < modelT.NumField(); i++ {
+		field := modelT.Field(i)
+		if previous := field.Tag.Get("was"); previous != "" {
+			names[JSONNames(model, []string{field.Name})[0]] = previous
+		}
+	}
+	return names
+}
+
+// CreateDataMigrations returns data of collection of log of migrations.
+func CreateDataMigrations() map[string]any {
+	return map[string]any{
+		"type": "base",
+		"name": MigrationsCollection,
+		"schema": []map[string]any{
+			{"name": "collection", "type": "text", "required": true},
+			{"name": "version", "type": "number"},
+			{"name": "changes", "type": "json"},
+		},
+		"indexes": []string{},
+	}
+}
+
+// Record returns record of migration in log of migrations.
+func (migration Migration) Record() map[string]any {
+	return map[string]any{
+		"collection": migration.Collection,
+		"version":    migration.Version,
+		"changes":    migration.Changes,
+	}
+}
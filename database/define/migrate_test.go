@@ -0,0 +1,129 @@
+package define
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// migrateCar is model of migration from schema of currentCar:
+// caption is renamed to title, year is retyped, color is added and note is removed.
+type migrateCar struct {
+	ID    string `json:"id"`
+	Name  string `json:"name" unique:"true"`
+	Title string `json:"title" was:"caption"`
+	Year  int    `json:"year"`
+	Color string `json:"color"`
+}
+
+func (car migrateCar) Id() any                     { return car.ID }
+func (migrateCar) Create(db DB, data string) Model { return &migrateCar{} }
+func (car *migrateCar) Save(table Table) error     { return table.Save(car) }
+func (car *migrateCar) Delete(db DB) error         { return nil }
+
+// currentCar returns schema and indexes of collection before migration.
+func currentCar() ([]map[string]any, []string) {
+	schema := []map[string]any{
+		{"id": "f1", "name": "name", "type": "text", "options": map[string]any{"max": 10}},
+		{"id": "f2", "name": "caption", "type": "text"},
+		{"id": "f3", "name": "year", "type": "text"},
+		{"id": "f4", "name": "note", "type": "text"},
+	}
+	indexes := []string{"CREATE INDEX `idx_admin` ON `car` (`note`)"}
+	return schema, indexes
+}
+
+func TestPlanMigration(t *testing.T) {
+	current, indexes := currentCar()
+	migration, err := PlanMigration("car", &migrateCar{}, current, indexes)
+	if err != nil {
+		t.Fatalf("PlanMigration: %v", err)
+	}
+	want := []SchemaChange{
+		{Op: SchemaRename, Field: "caption", To: "title"},
+		{Op: SchemaRetype, Field: "year", Type: "number"},
+		{Op: SchemaAdd, Field: "color", Type: "text"},
+		{Op: SchemaRemove, Field: "note"},
+		{Op: SchemaIndexes},
+	}
+	if !reflect.DeepEqual(migration.Changes, want) {
+		t.Fatalf("PlanMigration returns changes %v, expected %v", migration.Changes, want)
+	}
+
+	fields := map[string]map[string]any{}
+	for _, field := range migration.Schema {
+		fields[field["name"].(string)] = field
+	}
+	if len(fields) != 4 || fields["note"] != nil {
+		t.Fatalf("schema after migration has fields %v, expected name, title, year and color", fields)
+	}
+	if fields["name"]["id"] != "f1" || fields["name"]["options"] == nil {
+		t.Errorf("kept field lost its id or options: %v", fields["name"])
+	}
+	if fields["title"]["id"] != "f2" {
+		t.Errorf("renamed field lost its id: %v", fields["title"])
+	}
+	if fields["year"]["type"] != "number" {
+		t.Errorf("retyped field has type %v, expected number", fields["year"]["type"])
+	}
+	wantIndexes := []string{
+		"CREATE UNIQUE INDEX `idx_unique_car_name` ON `car` (`name`)",
+		"CREATE INDEX `idx_admin` ON `car` (`note`)",
+	}
+	if !reflect.DeepEqual(migration.Indexes, wantIndexes) {
+		t.Errorf("indexes after migration are %v, expected %v", migration.Indexes, wantIndexes)
+	}
+
+	// applied migration plans no changes
+	migration, err = PlanMigration("car", &migrateCar{}, migration.Schema, migration.Indexes)
+	if err != nil {
+		t.Fatalf("PlanMigration: %v", err)
+	}
+	if len(migration.Changes) != 0 {
+		t.Errorf("PlanMigration of migrated schema returns changes %v", migration.Changes)
+	}
+}
+
+func TestAdditiveMigration(t *testing.T) {
+	current, indexes := currentCar()
+	migration, err := PlanMigration("car", &migrateCar{}, current, indexes)
+	if err != nil {
+		t.Fatalf("PlanMigration: %v", err)
+	}
+	additive, skipped := migration.Additive(current, indexes)
+
+	wantChanges := []SchemaChange{
+		{Op: SchemaRename, Field: "caption", To: "title"},
+		{Op: SchemaAdd, Field: "color", Type: "text"},
+		{Op: SchemaIndexes},
+	}
+	if !reflect.DeepEqual(additive.Changes, wantChanges) {
+		t.Errorf("Additive returns changes %v, expected %v", additive.Changes, wantChanges)
+	}
+	wantSkipped := []SchemaChange{
+		{Op: SchemaRetype, Field: "year", Type: "number"},
+		{Op: SchemaRemove, Field: "note"},
+	}
+	if !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Errorf("Additive skips %v, expected %v", skipped, wantSkipped)
+	}
+
+	fields := map[string]map[string]any{}
+	for _, field := range additive.Schema {
+		fields[field["name"].(string)] = field
+	}
+	if fields["note"]["id"] != "f4" {
+		t.Errorf("removed field is not kept: %v", fields["note"])
+	}
+	if fields["year"]["type"] != "text" {
+		t.Errorf("retyped field has type %v, expected old type text", fields["year"]["type"])
+	}
+	if fields["title"]["id"] != "f2" || fields["color"] == nil {
+		t.Errorf("renamed or added field is missing: %v", fields)
+	}
+	wantIndexes := append(indexes, "CREATE UNIQUE INDEX `idx_unique_car_name` ON `car` (`name`)")
+	if !reflect.DeepEqual(additive.Indexes, wantIndexes) {
+		t.Errorf("Additive returns indexes %v, expected %v", additive.Indexes, wantIndexes)
+	}
+}
@@ -0,0 +1,129 @@
+package define
+
+import (
+	"reflect"
+	"strings"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// Behaviours of relation on delete of related model
+const (
+	OnDeleteSetNull  = "setnull" // relation field is cleared, default
+	OnDeleteCascade  = "cascade" // model is deleted
+	OnDeleteRestrict = "restrict"
+)
+
+// Relation is field storing id of model of table Table, `relation:"table"`.
+// Pointer is name of field resolved to related model on load, by default
+// it is name of field without suffix ID, `relation:"table,Pointer"` sets it.
+// OnDelete is set by tag `ondelete:"cascade"`.
+type Relation struct {
+	Field    string
+	Name     string // json name of field
+	Table    string
+	Pointer  string
+	OnDelete string
+}
+
+// ModelRelations returns relations of model.
+func ModelRelations(model Model) []Relation {
+	relations := []Relation{}
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil {
+		return relations
+	}
+	modelT := vModel.Type()
+	for i := 0; i < modelT.NumField(); i++ {
+		field := modelT.Field(i)
+		tag := field.Tag.Get("relation")
+		if tag == "" || IsManyToMany(field) {
+			continue
+		}
+		table, pointer, _ := strings.Cut(tag, ",")
+		if pointer == "" {
+			pointer = strings.TrimSuffix(strings.TrimSuffix(field.Name, "ID"), "Id")
+		}
+		if pointerT, ok := modelT.FieldByName(pointer); !ok || pointerT.Type.Kind() != reflect.Pointer || pointer == field.Name {
+			pointer = ""
+		}
+		onDelete := field.Tag.Get("ondelete")
+		if onDelete == "" {
+			onDelete = OnDeleteSetNull
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		relations = append(relations, Relation{
+			Field:    field.Name,
+			Name:     name,
+			Table:    table,
+			Pointer:  pointer,
+			OnDelete: onDelete,
+		})
+	}
+	return relations
+}
+
+// ManyRelation is many-to-many field of model storing ids of models of table Table.
+type ManyRelation struct {
+	Field string
+	Name  string // json name of field
+	Table string
+}
+
+var manyToManyType = reflect.TypeOf((*ManyToManyField)(nil)).Elem()
+
+// IsManyToMany reports if field is many-to-many field.
+func IsManyToMany(field reflect.StructField) bool {
+	return reflect.PointerTo(field.Type).Implements(manyToManyType)
+}
+
+// ManyTable returns table of related models of many-to-many field,
+// it is set by tag `relation:"table"` or is name of related model.
+func ManyTable(field reflect.StructField) string {
+	if table, _, _ := strings.Cut(field.Tag.Get("relation"), ","); table != "" {
+		return table
+	}
+	many := reflect.New(field.Type).Interface().(ManyToManyField)
+	return GetNameModel(many.Related())
+}
+
+// ModelManyRelations returns many-to-many fields of model.
+func ModelManyRelations(model Model) []ManyRelation {
+	relations := []ManyRelation{}
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil {
+		return relations
+	}
+	modelT := vModel.Type()
+	for i := 0; i < modelT.NumField(); i++ {
+		field := modelT.Field(i)
+		if !field.IsExported() || !IsManyToMany(field) {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		relations = append(relations, ManyRelation{field.Name, name, ManyTable(field)})
+	}
+	return relations
+}
+
+// ResolveRelations replaces names of collections in relation fields of schema by their ids.
+func ResolveRelations(schema []map[string]any, collectionId func(name string) (string, error)) error {
+	for _, field := range schema {
+		if field["type"] != "relation" {
+			continue
+		}
+		options, ok := field["options"].(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _ := options["collectionId"].(string)
+		id, err := collectionId(name)
+		if err != nil {
+			return NewErrorf("relation `%v`: collection `%v` does not exist", field["name"], name)
+		}
+		options["collectionId"] = id
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package define
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// DeletedAt is json name of field marking soft deleted models.
+const DeletedAt = "deleted_at"
+
+// SoftDeleteField returns name of field `deleted_at` of type time.Time or PBTime,
+// models having it are soft deleted. Empty name means model is deleted at once.
+func SoftDeleteField(model Model) string {
+	if model == nil {
+		return ""
+	}
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil {
+		return ""
+	}
+	modelT := vModel.Type()
+	for i := 0; i < modelT.NumField(); i++ {
+		field := modelT.Field(i)
+		if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != DeletedAt || !field.IsExported() {
+			continue
+		}
+		if field.Type == reflect.TypeOf(time.Time{}) || field.Type == reflect.TypeOf(PBTime{}) {
+			return field.Name
+		}
+	}
+	return ""
+}
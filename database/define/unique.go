@@ -0,0 +1,84 @@
+package define
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// UniqueSets returns sets of names of fields of model that must be unique together.
+// Field tagged `unique:"true"` is a set itself, fields tagged by equal
+// other value, e.g. `unique:"model_year"`, are a composite set.
+func UniqueSets(model Model) [][]string {
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil {
+		return nil
+	}
+	sets := [][]string{}
+	composite := map[string]int{}
+	modelT := vModel.Type()
+	for i := 0; i < modelT.NumField(); i++ {
+		field := modelT.Field(i)
+		tag := field.Tag.Get("unique")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+		if tag == "true" {
+			sets = append(sets, []string{field.Name})
+			continue
+		}
+		if j, ok := composite[tag]; ok {
+			sets[j] = append(sets[j], field.Name)
+			continue
+		}
+		composite[tag] = len(sets)
+		sets = append(sets, []string{field.Name})
+	}
+	return sets
+}
+
+// JSONNames returns json names of fields of model.
+func JSONNames(model Model, fields []string) []string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i], _, _ = strings.Cut(GetTagField(model, field, "json"), ",")
+		if names[i] == "" || names[i] == "-" {
+			names[i] = field
+		}
+	}
+	return names
+}
+
+// uniqueIndexes returns sql of pocketbase unique indexes of collection name.
+func uniqueIndexes(name string, model Model) []string {
+	indexes := []string{}
+	for _, set := range UniqueSets(model) {
+		fields := JSONNames(model, set)
+		indexes = append(indexes, fmt.Sprintf(
+			"CREATE UNIQUE INDEX `idx_unique_%v_%v` ON `%v` (`%v`)",
+			name, strings.Join(fields, "_"), name, strings.Join(fields, "`, `"),
+		))
+	}
+	return indexes
+}
+
+// UniqueColumns returns columns of sqlite error "UNIQUE constraint failed: car.model, car.year",
+// nil if err is not about unique constraint.
+func UniqueColumns(err error) []string {
+	_, failed, ok := strings.Cut(err.Error(), "UNIQUE constraint failed: ")
+	if !ok {
+		return nil
+	}
+	failed, _, _ = strings.Cut(failed, " (")
+	columns := []string{}
+	for _, column := range strings.Split(failed, ", ") {
+		if _, field, ok := strings.Cut(column, "."); ok {
+			column = field
+		}
+		columns = append(columns, strings.TrimSpace(column))
+	}
+	return columns
+}
@@ -0,0 +1,62 @@
+package define
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+type uniqueCar struct {
+	ID     string `json:"id"`
+	Login  string `json:"login" unique:"true"`
+	Model  string `json:"model" unique:"model_year"`
+	Year   int    `json:"year,omitempty" unique:"model_year"`
+	Color  string `json:"color"`
+	Serial string `unique:"true"`
+	hidden string `unique:"true"`
+}
+
+func (car uniqueCar) Id() any                     { return car.ID }
+func (uniqueCar) Create(db DB, data string) Model { return &uniqueCar{} }
+func (car *uniqueCar) Save(table Table) error     { return table.Save(car) }
+func (car *uniqueCar) Delete(db DB) error         { return nil }
+
+func TestUniqueSets(t *testing.T) {
+	want := [][]string{{"Login"}, {"Model", "Year"}, {"Serial"}}
+	if sets := UniqueSets(&uniqueCar{}); !reflect.DeepEqual(sets, want) {
+		t.Fatalf("UniqueSets returns %v, expected %v", sets, want)
+	}
+	if names := JSONNames(&uniqueCar{}, []string{"Model", "Year", "Serial"}); !reflect.DeepEqual(names, []string{"model", "year", "Serial"}) {
+		t.Fatalf("JSONNames returns %v, expected [model year Serial]", names)
+	}
+	if sets := UniqueSets(&filterCar{}); len(sets) != 0 {
+		t.Fatalf("UniqueSets of model without unique fields returns %v", sets)
+	}
+
+	indexes := []string{
+		"CREATE UNIQUE INDEX `idx_unique_car_login` ON `car` (`login`)",
+		"CREATE UNIQUE INDEX `idx_unique_car_model_year` ON `car` (`model`, `year`)",
+		"CREATE UNIQUE INDEX `idx_unique_car_Serial` ON `car` (`Serial`)",
+	}
+	if got := uniqueIndexes("car", &uniqueCar{}); !reflect.DeepEqual(got, indexes) {
+		t.Fatalf("uniqueIndexes returns %v, expected %v", got, indexes)
+	}
+}
+
+func TestUniqueColumns(t *testing.T) {
+	tests := []struct {
+		err  error
+		want []string
+	}{
+		{errors.New("UNIQUE constraint failed: car.login"), []string{"login"}},
+		{errors.New("constraint failed: UNIQUE constraint failed: car.model, car.year (2067)"), []string{"model", "year"}},
+		{errors.New("no such table: car"), nil},
+	}
+	for _, test := range tests {
+		if columns := UniqueColumns(test.err); !reflect.DeepEqual(columns, test.want) {
+			t.Errorf("UniqueColumns(%v) returns %v, expected %v", test.err, columns, test.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package define
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// Validate checks fields of model by rules of their tags `validate`,
+// e.g. `validate:"required,min=4,max=64,email"`, and returns ValidationError of table.
+// min and max limit length of strings, slices and maps and value of numbers,
+// email requires address of string. Empty values are checked by required only.
+func Validate(table string, model Model) error {
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil {
+		return nil
+	}
+	fields, rules := map[string]string{}, map[string]string{}
+	if err := validateStruct(*vModel, fields, rules); err != nil {
+		return err
+	}
+	if len(fields) > 0 {
+		return NewValidationError(table, fields, rules)
+	}
+	return nil
+}
+
+// validateStruct writes messages and broken rules of invalid fields of vStruct to fields and rules.
+func validateStruct(vStruct reflect.Value, fields, rules map[string]string) error {
+	typ := vStruct.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		// fields of embedded struct are promoted even if it is unexported
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := validateStruct(vStruct.Field(i), fields, rules); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+		rule, msg, err := validateField(vStruct.Field(i), tag)
+		if err != nil {
+			return NewErrorf("validate: field `%v`: %v", field.Name, err)
+		}
+		if msg != "" {
+			fields[name] = msg
+			rules[name] = rule
+		}
+	}
+	return nil
+}
+
+// validateField returns first broken rule of tag and its message, error is about wrong tag.
+func validateField(value reflect.Value, tag string) (string, string, error) {
+	if value.CanAddr() && value.CanInterface() {
+		if many, ok := value.Addr().Interface().(ManyToManyField); ok {
+			value = reflect.ValueOf(many.IDs())
+		}
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		rule, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if rule == "required" {
+			if isEmpty(value) {
+				return rule, "is required", nil
+			}
+			continue
+		}
+		for value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+		if isEmpty(value) {
+			continue
+		}
+		switch rule {
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return "", "", fmt.Errorf("%v expects number, got `%v`", rule, arg)
+			}
+			size, unit, ok := sizeOf(value)
+			if !ok {
+				return "", "", fmt.Errorf("%v is not supported by %v", rule, value.Kind())
+			}
+			if rule == "min" && size < limit {
+				return rule, fmt.Sprintf("must be at least %v%v", arg, unit), nil
+			}
+			if rule == "max" && size > limit {
+				return rule, fmt.Sprintf("must be at most %v%v", arg, unit), nil
+			}
+		case "email":
+			if value.Kind() != reflect.String {
+				return "", "", fmt.Errorf("email is not supported by %v", value.Kind())
+			}
+			address, err := mail.ParseAddress(value.String())
+			if err != nil || address.Address != value.String() {
+				return rule, "must be a valid email", nil
+			}
+		default:
+			return "", "", fmt.Errorf("unknown rule `%v`", rule)
+		}
+	}
+	return "", "", nil
+}
+
+// isEmpty reports whether value is zero or has no items.
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+// sizeOf returns length of strings, slices and maps or number value.
+func sizeOf(value reflect.Value) (float64, string, bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), "", true
+	}
+	return 0, "", false
+}
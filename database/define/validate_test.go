@@ -0,0 +1,81 @@
+package define
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+type validateContact struct {
+	Email string `json:"email" validate:"email"`
+}
+
+type validateCar struct {
+	ID    string   `json:"id"`
+	Login string   `json:"login" validate:"required,min=4,max=8"`
+	Year  int      `json:"year" validate:"min=1900"`
+	Tags  []string `json:"tags" validate:"max=2"`
+	Note  string   `validate:"required"`
+	validateContact
+}
+
+func (car validateCar) Id() any                     { return car.ID }
+func (validateCar) Create(db DB, data string) Model { return &validateCar{} }
+func (car *validateCar) Save(table Table) error     { return table.Save(car) }
+func (car *validateCar) Delete(db DB) error         { return nil }
+
+func TestValidate(t *testing.T) {
+	valid := validateCar{Login: "abcd", Year: 2000, Tags: []string{"a"}, Note: "n"}
+	if err := Validate("car", &valid); err != nil {
+		t.Fatalf("Validate of valid model returns %v", err)
+	}
+	// empty values are checked by required only
+	empty := validateCar{Login: "abcd", Note: "n"}
+	if err := Validate("car", &empty); err != nil {
+		t.Fatalf("Validate of empty optional fields returns %v", err)
+	}
+
+	tests := []struct {
+		car   validateCar
+		rules map[string]string
+	}{
+		{validateCar{Note: "n"}, map[string]string{"login": "required"}},
+		{validateCar{Login: "abc", Note: "n"}, map[string]string{"login": "min"}},
+		{validateCar{Login: "абвгдежз", Note: "n"}, nil},
+		{validateCar{Login: "abcdefghi", Year: 1800, Note: "n"}, map[string]string{"login": "max", "year": "min"}},
+		{validateCar{Login: "abcd", Tags: []string{"a", "b", "c"}}, map[string]string{"tags": "max", "Note": "required"}},
+		{validateCar{Login: "abcd", Note: "n", validateContact: validateContact{"a@"}}, map[string]string{"email": "email"}},
+	}
+	for _, test := range tests {
+		err := Validate("car", &test.car)
+		if test.rules == nil {
+			if err != nil {
+				t.Errorf("Validate(%+v) returns %v, expected nil", test.car, err)
+			}
+			continue
+		}
+		errV, ok := err.(ValidationError)
+		if !ok {
+			t.Errorf("Validate(%+v) returns %T: %v, expected ValidationError", test.car, err, err)
+			continue
+		}
+		if !reflect.DeepEqual(errV.Rules, test.rules) {
+			t.Errorf("Validate(%+v) breaks rules %v, expected %v", test.car, errV.Rules, test.rules)
+		}
+		if len(errV.Fields) != len(test.rules) || errV.Table != "car" {
+			t.Errorf("Validate(%+v) returns %+v, expected messages of %v", test.car, errV, test.rules)
+		}
+	}
+
+	wrong := struct {
+		validateCar
+		Size int `validate:"min=a"`
+	}{validateCar: valid, Size: 1}
+	if err := Validate("car", &wrong); err == nil {
+		t.Errorf("Validate of wrong tag returns no error")
+	} else if _, ok := err.(ValidationError); ok {
+		t.Errorf("Validate of wrong tag returns ValidationError")
+	}
+}
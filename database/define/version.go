@@ -0,0 +1,42 @@
+package define
+
+import (
+	"reflect"
+	"strings"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// Version is json name of field of version of model.
+const Version = "version"
+
+// VersionRule is update rule of pocketbase collection of versioned model,
+// record is updated by authorized user only if new version follows stored one.
+const VersionRule = `@request.auth.id != "" && version = @request.data.version - 1`
+
+// VersionField returns name of integer field `version`, it is incremented by every save
+// and save of model with version other than stored one fails with ErrConflict.
+// Remote pocketbase checks it by VersionRule of collection.
+func VersionField(model Model) string {
+	if model == nil {
+		return ""
+	}
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil {
+		return ""
+	}
+	modelT := vModel.Type()
+	for i := 0; i < modelT.NumField(); i++ {
+		field := modelT.Field(i)
+		if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != Version || !field.IsExported() {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return field.Name
+		}
+	}
+	return ""
+}
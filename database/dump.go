@@ -0,0 +1,375 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// DumpField is field of schema of dumped model.
+type DumpField struct {
+	Name     string `json:"name"` // json name of field
+	Type     string `json:"type,omitempty"`
+	Relation string `json:"relation,omitempty"` // table of related models
+	Many     bool   `json:"many,omitempty"`     // many-to-many field
+}
+
+// DumpSchema is schema of dumped model, Id is type of ids: uint, string or any.
+type DumpSchema struct {
+	Id     string      `json:"id"`
+	Fields []DumpField `json:"fields"`
+}
+
+// dumpLine is line of dump, header of table has Model and Schema, line of model has Record.
+type dumpLine struct {
+	Model  string          `json:"model,omitempty"`
+	Schema *DumpSchema     `json:"schema,omitempty"`
+	Record json.RawMessage `json:"record,omitempty"`
+}
+
+// NewDumpSchema returns schema of model, fields without json tag are skipped.
+func NewDumpSchema(model Model) DumpSchema {
+	schema := DumpSchema{Id: "any", Fields: []DumpField{}}
+	switch model.Id().(type) {
+	case uint:
+		schema.Id = "uint"
+	case string:
+		schema.Id = "string"
+	}
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil {
+		return schema
+	}
+	relations := map[string]string{}
+	for _, relation := range ModelRelations(model) {
+		relations[relation.Field] = relation.Table
+	}
+	modelT := vModel.Type()
+	for i := 0; i < modelT.NumField(); i++ {
+		fieldT := modelT.Field(i)
+		name, _, _ := strings.Cut(fieldT.Tag.Get("json"), ",")
+		if !fieldT.IsExported() || name == "" || name == "-" || fieldT.Name == "ID" {
+			continue
+		}
+		field := DumpField{Name: name, Type: GetType(vModel.Field(i)), Relation: relations[fieldT.Name]}
+		if IsManyToMany(fieldT) {
+			field.Type, field.Relation, field.Many = "relation", ManyTable(fieldT), true
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+	return schema
+}
+
+// Dump writes tables of models to w as json lines, every table is header
+// {"model": name, "schema": schema} and lines {"record": model} of its models,
+// soft deleted models are written too. Related tables are written before tables
+// of their relations, so Restore sets most relations without second save.
+func Dump(db DB, w io.Writer, models ...Model) error {
+	encoder := json.NewEncoder(w)
+	tables := make([]*restoredTable, len(models))
+	for i, model := range models {
+		name := GetNameModel(model)
+		table, err := db.Table(name, model)
+		if err != nil {
+			return NewErrorf("dump: table `%v`: %v", name, err)
+		}
+		tables[i] = &restoredTable{name: name, schema: NewDumpSchema(model), table: table}
+	}
+	for _, table := range orderTables(tables) {
+		if err := encoder.Encode(dumpLine{Model: table.name, Schema: &table.schema}); err != nil {
+			return NewErrorf("dump: %v", err)
+		}
+		var errWrite error
+		err := table.table.Manager().WithDeleted().Iterate(func(model Model) bool {
+			record, err := json.Marshal(model)
+			if err == nil {
+				err = encoder.Encode(dumpLine{Record: record})
+			}
+			errWrite = err
+			return err == nil
+		})
+		if errWrite != nil {
+			return NewErrorf("dump: table `%v`: %v", table.name, errWrite)
+		}
+		if err != nil {
+			return NewErrorf("dump: table `%v`: %v", table.name, err)
+		}
+	}
+	return nil
+}
+
+// restorePart is count of models of table read from dump before they are saved
+const restorePart = 100
+
+// restoredTable is table of dump, records are its models read and not saved yet.
+type restoredTable struct {
+	name    string
+	schema  DumpSchema
+	table   Table
+	records []map[string]any
+}
+
+// deferredRelation is relation of restored model of id to model of table
+// restored later or missing in dump.
+type deferredRelation struct {
+	table *restoredTable
+	id    any // new id of model
+	field DumpField
+	value any // value of field in dump
+}
+
+// restorer saves models of dump to db.
+type restorer struct {
+	ctx      context.Context
+	db       DB
+	ids      map[string]map[string]any // new ids by tables and old ids
+	restored map[string]bool           // tables of which all models are saved
+	deferred []deferredRelation
+}
+
+// Restore reads dump written by Dump and saves its models to tables of db,
+// tables of models of dump must be opened by db.Table before.
+// Models get new ids of db, so ids of type uint become string ones and back,
+// relations and many-to-many fields are changed to new ids of related models.
+// Relations to models missing in dump are cleared, relations to tables missing in dump are kept.
+// Models keep their dumped auto fields and version, remote pocketbase sets its own times.
+//
+// Dump is read table by table and only restorePart models are kept in memory,
+// relations to tables restored later are kept until the end.
+// Restore runs in one transaction if db supports them,
+// otherwise models saved before error are kept.
+func Restore(db DB, r io.Reader) error {
+	ctx := base.WithRestored(context.Background())
+	called := false
+	err := db.Tx(func(tx DB) error {
+		called = true
+		return restore(ctx, tx, r)
+	})
+	if _, ok := err.(ErrNotSupported); ok && !called {
+		return restore(ctx, db, r)
+	}
+	return err
+}
+
+// restore reads dump of r and saves its models to tables of db.
+func restore(ctx context.Context, db DB, r io.Reader) error {
+	restorer := &restorer{ctx: ctx, db: db, ids: map[string]map[string]any{}, restored: map[string]bool{}}
+	var table *restoredTable
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	for {
+		var line dumpLine
+		err := decoder.Decode(&line)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return NewErrorf("restore: %v", err)
+		}
+		if line.Schema != nil {
+			if table != nil {
+				if err := restorer.finish(table); err != nil {
+					return err
+				}
+			}
+			opened := db.TableFromCache(line.Model)
+			if opened == nil {
+				return NewErrorf("restore: table `%v` is not opened, open it by db.Table", line.Model)
+			}
+			table = &restoredTable{name: line.Model, schema: *line.Schema, table: opened}
+			restorer.ids[table.name] = map[string]any{}
+			continue
+		}
+		if table == nil {
+			return NewErrorf("restore: record without header of table")
+		}
+		record := map[string]any{}
+		recordDecoder := json.NewDecoder(strings.NewReader(string(line.Record)))
+		recordDecoder.UseNumber()
+		if err := recordDecoder.Decode(&record); err != nil {
+			return NewErrorf("restore: table `%v`: %v", table.name, err)
+		}
+		table.records = append(table.records, record)
+		if len(table.records) == restorePart {
+			if err := restorer.save(table); err != nil {
+				return err
+			}
+		}
+	}
+	if table != nil {
+		if err := restorer.finish(table); err != nil {
+			return err
+		}
+	}
+	return restorer.resolve()
+}
+
+// finish saves rest of models of table, table is restored.
+func (restorer *restorer) finish(table *restoredTable) error {
+	if err := restorer.save(table); err != nil {
+		return err
+	}
+	restorer.restored[table.name] = true
+	return nil
+}
+
+// save saves read models of table with relations to restored tables,
+// other relations are cleared and deferred.
+func (restorer *restorer) save(table *restoredTable) error {
+	for _, record := range table.records {
+		oldId := fmt.Sprint(record["id"])
+		record["id"] = nil
+		deferred := []deferredRelation{}
+		for _, field := range table.schema.Fields {
+			value, ok := record[field.Name]
+			if !ok || field.Relation == "" {
+				continue
+			}
+			if !restorer.restored[field.Relation] {
+				// related table is restored later or is missing in dump, field is set by resolve
+				record[field.Name] = nil
+				deferred = append(deferred, deferredRelation{table, nil, field, value})
+				continue
+			}
+			record[field.Name] = remap(field, value, restorer.ids[field.Relation])
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return NewErrorf("restore: table `%v`: %v", table.name, err)
+		}
+		model := table.table.Model().Create(restorer.db, string(data))
+		if err := saveContext(restorer.ctx, table.table, model); err != nil {
+			return NewErrorf("restore: table `%v`: %v", table.name, err)
+		}
+		restorer.ids[table.name][oldId] = model.Id()
+		for _, relation := range deferred {
+			relation.id = model.Id()
+			restorer.deferred = append(restorer.deferred, relation)
+		}
+	}
+	table.records = table.records[:0]
+	return nil
+}
+
+// resolve sets deferred relations of saved models, every model is saved once.
+// Relations to tables missing in dump get their dumped values.
+func (restorer *restorer) resolve() error {
+	type key struct {
+		table *restoredTable
+		id    string
+	}
+	keys := []key{}
+	relations := map[key][]deferredRelation{}
+	for _, relation := range restorer.deferred {
+		k := key{relation.table, fmt.Sprint(relation.id)}
+		if _, ok := relations[k]; !ok {
+			keys = append(keys, k)
+		}
+		relations[k] = append(relations[k], relation)
+	}
+	for _, k := range keys {
+		table := k.table
+		model, err := table.table.Get(relations[k][0].id)
+		if err != nil {
+			return NewErrorf("restore: table `%v`: %v", table.name, err)
+		}
+		data, err := json.Marshal(model)
+		if err != nil {
+			return NewErrorf("restore: table `%v`: %v", table.name, err)
+		}
+		record := map[string]any{}
+		decoder := json.NewDecoder(strings.NewReader(string(data)))
+		decoder.UseNumber()
+		if err := decoder.Decode(&record); err != nil {
+			return NewErrorf("restore: table `%v`: %v", table.name, err)
+		}
+		for _, relation := range relations[k] {
+			if ids, ok := restorer.ids[relation.field.Relation]; ok {
+				record[relation.field.Name] = remap(relation.field, relation.value, ids)
+			} else {
+				record[relation.field.Name] = relation.value
+			}
+		}
+		data, _ = json.Marshal(record)
+		if err := saveContext(restorer.ctx, table.table, model.Create(restorer.db, string(data))); err != nil {
+			return NewErrorf("restore: table `%v`: %v", table.name, err)
+		}
+	}
+	return nil
+}
+
+// saveContext saves model by SaveContext of ContextTable or by Save.
+func saveContext(ctx context.Context, table Table, model Model) error {
+	if contextTable, ok := table.(ContextTable); ok {
+		return contextTable.SaveContext(ctx, model)
+	}
+	return table.Save(model)
+}
+
+// orderTables returns tables ordered so that related tables are before tables of relations,
+// tables of cycles keep order of dump.
+func orderTables(tables []*restoredTable) []*restoredTable {
+	byName := map[string]*restoredTable{}
+	for _, table := range tables {
+		byName[table.name] = table
+	}
+	ordered := []*restoredTable{}
+	visited := map[string]bool{}
+	var visit func(table *restoredTable)
+	visit = func(table *restoredTable) {
+		if visited[table.name] {
+			return
+		}
+		visited[table.name] = true
+		for _, field := range table.schema.Fields {
+			if related, ok := byName[field.Relation]; ok {
+				visit(related)
+			}
+		}
+		ordered = append(ordered, table)
+	}
+	for _, table := range tables {
+		visit(table)
+	}
+	return ordered
+}
+
+// remap returns value of relation field with new ids, ids missing in ids are cleared.
+func remap(field DumpField, value any, ids map[string]any) any {
+	if !field.Many {
+		if isZeroId(value) {
+			return nil
+		}
+		return ids[fmt.Sprint(value)]
+	}
+	values, _ := value.([]any)
+	newValues := []any{}
+	for _, value := range values {
+		if id, ok := ids[fmt.Sprint(value)]; ok {
+			newValues = append(newValues, id)
+		}
+	}
+	return newValues
+}
+
+// isZeroId reports if value of relation field is empty relation.
+func isZeroId(value any) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case json.Number:
+		return value == "0"
+	}
+	return false
+}
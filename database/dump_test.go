@@ -0,0 +1,216 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// dumpId returns id of json, whole numbers are uint.
+func dumpId(id any) any {
+	if number, ok := id.(float64); ok {
+		return uint(number)
+	}
+	return id
+}
+
+// dumpOwner is related model of dump tests, its id is uint or string.
+type dumpOwner struct {
+	ID      any       `json:"id"`
+	Name    string    `json:"name" validate:"required"`
+	Created time.Time `json:"created_at" auto:"created"`
+	Updated PBTime    `json:"updated_at" auto:"updated"`
+	Version uint64    `json:"version"`
+}
+
+func (owner dumpOwner) Id() any { return owner.ID }
+func (dumpOwner) Create(db DB, data string) Model {
+	owner := &dumpOwner{}
+	JSONParse([]byte(data), owner)
+	owner.ID = dumpId(owner.ID)
+	return owner
+}
+func (owner *dumpOwner) Save(table Table) error { return table.Save(owner) }
+func (owner *dumpOwner) Delete(db DB) error     { return db.TableFromCache("dump_owner").Delete(owner.ID) }
+
+// dumpCar relates to owner and to next car, which may be dumped later.
+type dumpCar struct {
+	ID      any    `json:"id"`
+	Name    string `json:"name"`
+	OwnerID any    `json:"owner_id" relation:"dump_owner,"`
+	NextID  any    `json:"next_id" relation:"dump_car,"`
+}
+
+func (car dumpCar) Id() any { return car.ID }
+func (dumpCar) Create(db DB, data string) Model {
+	car := &dumpCar{}
+	json.Unmarshal([]byte(data), car)
+	car.ID, car.OwnerID, car.NextID = dumpId(car.ID), dumpId(car.OwnerID), dumpId(car.NextID)
+	return car
+}
+func (car *dumpCar) Save(table Table) error { return table.Save(car) }
+func (car *dumpCar) Delete(db DB) error     { return db.TableFromCache("dump_car").Delete(car.ID) }
+
+// openDump returns tables of owners and cars of db, id is zero id of models.
+func openDump(t *testing.T, db DB, id any) (Table, Table) {
+	t.Helper()
+	owners, err := db.Table("dump_owner", &dumpOwner{ID: id})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	cars, err := db.Table("dump_car", &dumpCar{ID: id})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	return owners, cars
+}
+
+func TestDumpRestore(t *testing.T) {
+	source, err := OpenBbolt(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("OpenBbolt: %v", err)
+	}
+	t.Cleanup(func() { source.Close() })
+	owners, cars := openDump(t, source, uint(0))
+	a, b := &dumpOwner{ID: uint(0), Name: "a"}, &dumpOwner{ID: uint(0), Name: "b"}
+	for _, owner := range []*dumpOwner{a, b, a} {
+		if err := owners.Save(owner); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	// every car but last relates to next car, so parts of restore relate to later parts
+	count := restorePart + 50
+	for i := 0; i < count; i++ {
+		car := &dumpCar{ID: uint(0), Name: fmt.Sprint("car", i), OwnerID: a.ID}
+		if i%2 == 1 {
+			car.OwnerID = b.ID
+		}
+		if i < count-1 {
+			car.NextID = uint(i + 2)
+		}
+		if err := cars.Save(car); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	var dump bytes.Buffer
+	if err := Dump(source, &dump, &dumpCar{ID: uint(0)}, &dumpOwner{ID: uint(0)}); err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	if header, _, _ := strings.Cut(dump.String(), "\n"); !strings.Contains(header, `"model":"dump_owner"`) {
+		t.Fatalf("first table of dump is %v, expected related dump_owner", header)
+	}
+
+	target, _ := OpenMemory()
+	owners, cars = openDump(t, target, "")
+	if err := Restore(target, &dump); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if owners.Count() != 2 || cars.Count() != uint(count) {
+		t.Fatalf("Restore saves %v owners and %v cars, expected 2 and %v", owners.Count(), cars.Count(), count)
+	}
+
+	// dumped times and version are kept
+	restoredA := owners.Manager().Filter(Params{"Name": "a"}).First().(*dumpOwner)
+	if _, ok := restoredA.ID.(string); !ok {
+		t.Fatalf("id of restored owner is %T, expected string", restoredA.ID)
+	}
+	if restoredA.Version != a.Version || !restoredA.Created.Equal(a.Created) || !time.Time(restoredA.Updated).Equal(time.Time(a.Updated)) {
+		t.Errorf("restored owner has version %v, times %v and %v, expected %v, %v and %v", restoredA.Version,
+			restoredA.Created, time.Time(restoredA.Updated), a.Version, a.Created, time.Time(a.Updated))
+	}
+
+	byId := map[any]*dumpCar{}
+	byName := map[string]*dumpCar{}
+	for _, model := range cars.Manager().All() {
+		car := model.(*dumpCar)
+		byId[car.ID] = car
+		byName[car.Name] = car
+	}
+	for i := 0; i < count; i++ {
+		car := byName[fmt.Sprint("car", i)]
+		owner, err := owners.Get(car.OwnerID)
+		if err != nil || owner.(*dumpOwner).Name != map[bool]string{true: "b", false: "a"}[i%2 == 1] {
+			t.Fatalf("owner of %v is %v, %v", car.Name, owner, err)
+		}
+		if i == count-1 {
+			if car.NextID != nil {
+				t.Fatalf("last car relates to %v", car.NextID)
+			}
+			continue
+		}
+		if next := byId[car.NextID]; next == nil || next.Name != fmt.Sprint("car", i+1) {
+			t.Fatalf("next car of %v is %v, expected car%v", car.Name, next, i+1)
+		}
+	}
+}
+
+func TestRestoreAtomic(t *testing.T) {
+	dump := strings.Join([]string{
+		`{"model":"dump_owner","schema":{"id":"uint","fields":[{"name":"name","type":"text"}]}}`,
+		`{"record":{"id":1,"name":"a"}}`,
+		`{"model":"dump_car","schema":{"id":"uint","fields":[{"name":"owner_id","type":"number","relation":"dump_owner"}]}}`,
+		`{"record":{"id":1,"name":"x","owner_id":1}}`,
+		`{"model":"dump_owner","schema":{"id":"uint","fields":[{"name":"name","type":"text"}]}}`,
+		`{"record":{"id":2,"name":""}}`,
+	}, "\n")
+	db, _ := OpenMemory()
+	owners, cars := openDump(t, db, uint(0))
+	if err := Restore(db, strings.NewReader(dump)); err == nil {
+		t.Fatalf("Restore of invalid owner returns no error")
+	}
+	if owners.Count() != 0 || cars.Count() != 0 {
+		t.Fatalf("failed Restore keeps %v owners and %v cars", owners.Count(), cars.Count())
+	}
+
+	if err := Restore(db, strings.NewReader(`{"model":"missing","schema":{"id":"uint","fields":[]}}`)); err == nil {
+		t.Errorf("Restore of not opened table returns no error")
+	}
+	if err := Restore(db, strings.NewReader(`{"record":{"id":1}}`)); err == nil {
+		t.Errorf("Restore of record without header returns no error")
+	}
+}
+
+// countTxDB counts calls of Tx, without transactions Tx returns ErrNotSupported
+// as remote pocketbase.
+type countTxDB struct {
+	DB
+	noTx  bool
+	calls *int
+}
+
+func (db countTxDB) Tx(fn func(tx DB) error) error {
+	*db.calls++
+	if db.noTx {
+		return NewErrNotSupported("transactions")
+	}
+	return db.DB.Tx(fn)
+}
+
+func TestRestoreTx(t *testing.T) {
+	dump := strings.Join([]string{
+		`{"model":"dump_owner","schema":{"id":"uint","fields":[{"name":"name","type":"text"}]}}`,
+		`{"record":{"id":1,"name":"a"}}`,
+	}, "\n")
+	for _, noTx := range []bool{false, true} {
+		memDB, _ := OpenMemory()
+		owners, _ := openDump(t, memDB, uint(0))
+		calls := 0
+		if err := Restore(countTxDB{memDB, noTx, &calls}, strings.NewReader(dump)); err != nil {
+			t.Fatalf("Restore without transactions %v: %v", noTx, err)
+		}
+		if calls != 1 {
+			t.Errorf("Restore without transactions %v calls Tx %v times, expected 1", noTx, calls)
+		}
+		if count := owners.Count(); count != 1 {
+			t.Errorf("Restore without transactions %v restores %v owners, expected 1", noTx, count)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package errors
 
 import (
 	"fmt" // for Sprintf()
+	"sort"
+	"strings"
 )
 
 // Error is interface error
@@ -31,6 +33,54 @@ type ErrOutOfRange struct {
 	index any
 }
 
+// ErrNotSupported is error about feature that backend does not provide
+type ErrNotSupported struct {
+	feature string
+}
+
+// ErrLookup is error about filter condition that can not be expressed
+type ErrLookup struct {
+	key    any
+	reason string
+}
+
+// ErrBatch is error of bulk operation,
+// Errors[i] is error of i-th item or nil
+type ErrBatch struct {
+	Errors []error
+}
+
+// ErrUnique is error about saving model with values of unique fields
+// equal to values of other model, Fields are json names of fields.
+type ErrUnique struct {
+	Table  string
+	Fields []string
+}
+
+// ErrRestrict is error about deleting model of Table
+// referenced by models of table By with restricted delete.
+type ErrRestrict struct {
+	Table string
+	By    string
+}
+
+// ValidationError is error about values of fields of model of Table
+// breaking rules of their tags `validate`, Fields are messages by json names of fields,
+// Rules are names of broken rules by json names of fields: required, min, max or email.
+type ValidationError struct {
+	Table  string
+	Fields map[string]string
+	Rules  map[string]string
+}
+
+// ErrConflict is error about saving model of Table changed by other save
+// since Version of model was read.
+type ErrConflict struct {
+	Table   string
+	Id      any
+	Version uint64
+}
+
 // New functions creating error
 
 func ToError(err error) Error {
@@ -70,6 +120,41 @@ func NewErrOutOfRange(index uint) ErrOutOfRange {
 	return ErrOutOfRange{index}
 }
 
+// NewErrNotSupported create ErrNotSupported
+func NewErrNotSupported(feature string) ErrNotSupported {
+	return ErrNotSupported{feature}
+}
+
+// NewErrLookup create ErrLookup
+func NewErrLookup(key any, reason string) ErrLookup {
+	return ErrLookup{key, reason}
+}
+
+// NewErrBatch create ErrBatch
+func NewErrBatch(errs []error) ErrBatch {
+	return ErrBatch{errs}
+}
+
+// NewErrUnique create ErrUnique
+func NewErrUnique(table string, fields []string) ErrUnique {
+	return ErrUnique{table, fields}
+}
+
+// NewErrRestrict create ErrRestrict
+func NewErrRestrict(table, by string) ErrRestrict {
+	return ErrRestrict{table, by}
+}
+
+// NewValidationError create ValidationError
+func NewValidationError(table string, fields, rules map[string]string) ValidationError {
+	return ValidationError{table, fields, rules}
+}
+
+// NewErrConflict create ErrConflict
+func NewErrConflict(table string, id any, version uint64) ErrConflict {
+	return ErrConflict{table, id, version}
+}
+
 // Name functions return error's names
 
 // Name return "CustomError"
@@ -97,6 +182,52 @@ func (err ErrOutOfRange) Name() string {
 	return "ErrIndexOutOfRange"
 }
 
+// Name return "ErrNotSupported"
+func (err ErrNotSupported) Name() string {
+	return "ErrNotSupported"
+}
+
+// Name return "ErrLookup"
+func (err ErrLookup) Name() string {
+	return "ErrLookup"
+}
+
+// Name return "ErrBatch"
+func (err ErrBatch) Name() string {
+	return "ErrBatch"
+}
+
+// Name return "ErrUnique"
+func (err ErrUnique) Name() string {
+	return "ErrUnique"
+}
+
+// Name return "ErrRestrict"
+func (err ErrRestrict) Name() string {
+	return "ErrRestrict"
+}
+
+// Name return "ValidationError"
+func (err ValidationError) Name() string {
+	return "ValidationError"
+}
+
+// Name return "ErrConflict"
+func (err ErrConflict) Name() string {
+	return "ErrConflict"
+}
+
+// Failed returns count of failed items
+func (err ErrBatch) Failed() int {
+	count := 0
+	for _, e := range err.Errors {
+		if e != nil {
+			count++
+		}
+	}
+	return count
+}
+
 // Error functions return string error
 
 // Error return string error
@@ -123,3 +254,48 @@ func (err ErrNilBucket) Error() string {
 func (err ErrOutOfRange) Error() string {
 	return fmt.Sprintf("index `%v` does not exists", err.index)
 }
+
+// Error return string error
+func (err ErrNotSupported) Error() string {
+	return fmt.Sprintf("%v is not supported", err.feature)
+}
+
+// Error return string error
+func (err ErrLookup) Error() string {
+	return fmt.Sprintf("lookup `%v` can not be expressed: %v", err.key, err.reason)
+}
+
+// Error return string error
+func (err ErrBatch) Error() string {
+	for i, e := range err.Errors {
+		if e != nil {
+			return fmt.Sprintf("%v of %v items failed, item %v: %v", err.Failed(), len(err.Errors), i, e)
+		}
+	}
+	return "no items failed"
+}
+
+// Error return string error
+func (err ErrUnique) Error() string {
+	return fmt.Sprintf("unique `%v` (%v) already exists", err.Table, strings.Join(err.Fields, ", "))
+}
+
+// Error return string error
+func (err ErrRestrict) Error() string {
+	return fmt.Sprintf("model of `%v` is referenced by `%v`, delete is restricted", err.Table, err.By)
+}
+
+// Error return string error
+func (err ValidationError) Error() string {
+	fields := make([]string, 0, len(err.Fields))
+	for field, msg := range err.Fields {
+		fields = append(fields, field+" "+msg)
+	}
+	sort.Strings(fields)
+	return fmt.Sprintf("model of `%v` is not valid: %v", err.Table, strings.Join(fields, ", "))
+}
+
+// Error return string error
+func (err ErrConflict) Error() string {
+	return fmt.Sprintf("model `%v` of `%v` is changed since version %v", err.Id, err.Table, err.Version)
+}
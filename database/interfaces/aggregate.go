@@ -0,0 +1,44 @@
+package interfaces
+
+import (
+	"context"
+)
+
+// Functions of Aggregation
+const (
+	AggSum   = "sum"
+	AggAvg   = "avg"
+	AggMin   = "min"
+	AggMax   = "max"
+	AggCount = "count"
+)
+
+// Aggregation is aggregate function of field of models.
+// Sum and Avg are float64, Min and Max have type of field, Count is uint.
+// Avg, Min and Max are nil for no values.
+type Aggregation struct {
+	Func  string
+	Field string // empty for Count of models
+}
+
+// Key returns key of result of aggregation, "sum_Year" or "count".
+func (aggregation Aggregation) Key() string {
+	if aggregation.Field == "" {
+		return aggregation.Func
+	}
+	return aggregation.Func + "_" + aggregation.Field
+}
+
+// Group is result of aggregation of models with equal values of grouping fields.
+type Group struct {
+	Values Params // name of field -> value
+	Result Params // key of aggregation -> result
+}
+
+// Grouping aggregates models of manager by values of fields,
+// groups are ordered by values.
+type Grouping interface {
+	Count() ([]Group, error)
+	Aggregate(aggregations ...Aggregation) ([]Group, error)
+	AggregateContext(ctx context.Context, aggregations ...Aggregation) ([]Group, error)
+}
@@ -0,0 +1,26 @@
+package interfaces
+
+// Models may implement hooks of lifecycle, tables call them on Save and Delete.
+// Hooks get table of the write, inside transaction it is table of transaction.
+// Error of Before* hook aborts the write, on backends with transactions
+// error of any hook rolls back the write with changes of hooks.
+
+// BeforeSaver is Model called before it is written.
+type BeforeSaver interface {
+	BeforeSave(table Table) error
+}
+
+// AfterSaver is Model called after it is written.
+type AfterSaver interface {
+	AfterSave(table Table) error
+}
+
+// BeforeDeleter is Model called before it is deleted.
+type BeforeDeleter interface {
+	BeforeDelete(table Table) error
+}
+
+// AfterDeleter is Model called after it is deleted.
+type AfterDeleter interface {
+	AfterDelete(table Table) error
+}
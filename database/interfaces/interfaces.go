@@ -1,10 +1,12 @@
 package interfaces
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"reflect"
+	"strings"
 	"time"
 )
 
@@ -14,6 +16,11 @@ type DB interface {
 	Table(name string, model Model) (Table, error)
 	ExistsTable(name string) bool
 	TableFromCache(name string) Table
+
+	// Tx runs fn in one transaction, tables of tx share it.
+	// Error of fn rolls back all changes. Managers of tables of tx read the transaction,
+	// managers of tables of db are updated after commit only.
+	Tx(fn func(tx DB) error) error
 }
 
 type Table interface {
@@ -22,10 +29,26 @@ type Table interface {
 	Model() Model
 
 	Get(id any) (Model, error)
+	// Save returns ValidationError if fields of model break rules of their tags `validate`.
 	Save(model Model) error
 	Delete(id any) error
 
+	// SaveMany and DeleteMany apply items in one transaction, failed item rolls back
+	// the batch and ErrBatch with errors of failed items is returned.
+	// DB without transactions, as remote pocketbase, keeps applied items.
+	SaveMany(models []Model) error
+	DeleteMany(ids []any) error
+
 	DeleteAll() error
+
+	// Models with field `deleted_at` of type time.Time or PBTime are soft deleted,
+	// Delete sets the field and managers exclude them by default.
+	// Restore clears the field, HardDelete removes model as Delete of other models.
+	Restore(id any) error
+	HardDelete(id any) error
+
+	// Count returns count of stored models, soft deleted models too,
+	// Manager().Count() excludes them.
 	Count() uint
 	Manager() ManagerI
 	SetManager(ManagerI)
@@ -55,18 +78,79 @@ type ManagerI interface {
 	Broadcast(Nexter)
 	All() []Model
 	Filter(include Params, exclude ...Params) ManagerI
+	Where(q Q) ManagerI
 
 	Count() uint
 	First() Model
 	Last() Model
+
+	// OrderBy, Limit and Offset return copy of manager,
+	// they are applied by All, Filter, Count, First and Last.
+	// OrderBy takes names of fields, "-" prefix means descending order.
+	OrderBy(fields ...string) ManagerI
+	Limit(n uint) ManagerI
+	Offset(n uint) ManagerI
+	// With returns copy of manager loading related models of relations in bulk,
+	// relations are names of pointer fields, "Owner.Company" loads relation of related model.
+	// They are applied by All, Get, First and Last.
+	With(relations ...string) ManagerI
+	// WithDeleted and OnlyDeleted return copy of manager selecting
+	// soft deleted models too or only them.
+	WithDeleted() ManagerI
+	OnlyDeleted() ManagerI
+
+	// Aggregate returns results of aggregations over models of manager by their keys.
+	Aggregate(aggregations ...Aggregation) (Params, error)
+	GroupBy(fields ...string) Grouping
+	// Distinct returns ordered unique values of field.
+	Distinct(field string) ([]any, error)
+
+	// Iterate calls fn for models satisfying conditions until fn returns false,
+	// models are loaded by parts and are not stored in manager.
+	Iterate(fn func(model Model) (continue_ bool), conditions ...Condition) error
+}
+
+// ContextTable is a Table whose reads and writes can be cancelled through ctx.
+// Get, Save and Delete are equal to the Context variants with context.Background().
+type ContextTable interface {
+	Table
+
+	GetContext(ctx context.Context, id any) (Model, error)
+	SaveContext(ctx context.Context, model Model) error
+	DeleteContext(ctx context.Context, id any) error
+}
+
+// ManyGetter is Table getting models of ids in one query, missing ids are skipped.
+type ManyGetter interface {
+	GetManyContext(ctx context.Context, ids []any) ([]Model, error)
+}
+
+// Expander is Table loading related models of relations of its models by itself.
+type Expander interface {
+	Expand(ctx context.Context, models []Model, relations []string) error
 }
 
-// Pocketbase return time in `2006-01-02 15:04:05Z` format.
+// ContextManager is a ManagerI whose queries can be cancelled through ctx.
+// Unlike All, Filter and Count, the Context variants report backend errors.
+type ContextManager interface {
+	ManagerI
+
+	AllContext(ctx context.Context) ([]Model, error)
+	FilterContext(ctx context.Context, include Params, exclude ...Params) (ManagerI, error)
+	WhereContext(ctx context.Context, q Q) (ManagerI, error)
+	CountContext(ctx context.Context) (uint, error)
+	AggregateContext(ctx context.Context, aggregations ...Aggregation) (Params, error)
+	DistinctContext(ctx context.Context, field string) ([]any, error)
+	IterateContext(ctx context.Context, fn func(model Model) (continue_ bool), conditions ...Condition) error
+}
+
+// Pocketbase return time in `2006-01-02 15:04:05.000Z` format.
 // And package time parsing in `2006-01-02T15:04:05Z07:00` format.
 type PBTime time.Time
 
+// Unmarshal parses time of data by layouts of times of models, quotes of json are trimmed.
 func (pbt *PBTime) Unmarshal(data []byte) error {
-	t, err := time.Parse("2006-01-02 15:04:05Z", string(data))
+	t, err := parseTime(strings.Trim(string(data), `"`))
 	if err != nil {
 		return err
 	}
@@ -74,8 +158,13 @@ func (pbt *PBTime) Unmarshal(data []byte) error {
 	return nil
 }
 
+func (pbt *PBTime) UnmarshalJSON(data []byte) error {
+	return pbt.Unmarshal(data)
+}
+
 func (pbt *PBTime) MarshalJSON() ([]byte, error) {
-	return []byte(`"` + time.Time(*pbt).Format("2006-01-02 15:04:05Z") + `"`), nil
+	// milliseconds keep order of auto fields
+	return []byte(`"` + time.Time(*pbt).UTC().Format("2006-01-02 15:04:05.000Z") + `"`), nil
 }
 
 func JSONParse(data []byte, model Model) error {
@@ -107,7 +196,13 @@ func JSONParse(data []byte, model Model) error {
 		if fieldValue.CanSet() {
 			fieldType := fieldValue.Type()
 			dataValue := reflect.ValueOf(value)
-			if dataValue.Type().ConvertibleTo(fieldType) {
+			if str, ok := value.(string); ok && isTime(fieldType) {
+				if t, err := parseTime(str); err == nil {
+					fieldValue.Set(reflect.ValueOf(t).Convert(fieldType))
+				} else {
+					log.Printf("%s: cannot parse time of field '%s': %v\n", modelT.Name(), field.Name, err)
+				}
+			} else if dataValue.Type().ConvertibleTo(fieldType) {
 				convertedValue := dataValue.Convert(fieldType)
 				fieldValue.Set(convertedValue)
 			} else if unmarshaler, ok := fieldValue.Interface().(json.Unmarshaler); ok {
@@ -130,3 +225,24 @@ func JSONParse(data []byte, model Model) error {
 
 	return nil
 }
+
+// timeLayouts are layouts of times in json of backends, empty time is zero.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00"}
+
+func isTime(typ reflect.Type) bool {
+	return typ == reflect.TypeOf(time.Time{}) || typ == reflect.TypeOf(PBTime{})
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
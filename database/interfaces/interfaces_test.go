@@ -0,0 +1,44 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPBTimeRoundTrip(t *testing.T) {
+	want := time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.UTC)
+	pbt := PBTime(want)
+	data, err := json.Marshal(&pbt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"2024-05-06 07:08:09.123Z"` {
+		t.Fatalf("MarshalJSON = %s", data)
+	}
+	var got PBTime
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !time.Time(got).Equal(want) {
+		t.Fatalf("UnmarshalJSON = %v, want %v", time.Time(got), want)
+	}
+}
+
+func TestPBTimeUnmarshalLayouts(t *testing.T) {
+	for _, value := range []string{
+		"2024-05-06 07:08:09Z",
+		"2024-05-06 07:08:09.123Z",
+		"2024-05-06T07:08:09Z",
+		"2024-05-06T07:08:09.123+00:00",
+	} {
+		var pbt PBTime
+		if err := pbt.Unmarshal([]byte(value)); err != nil {
+			t.Errorf("Unmarshal(%q): %v", value, err)
+		}
+	}
+	var pbt PBTime
+	if err := pbt.Unmarshal([]byte("yesterday")); err == nil {
+		t.Error("Unmarshal(yesterday): want error")
+	}
+}
@@ -0,0 +1,169 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// ManyToManyField is field of many-to-many relation, it is implemented by *ManyToMany.
+type ManyToManyField interface {
+	IDs() []any
+	SetIDs(ids []any)
+	// Related returns empty model of related table.
+	Related() Model
+	Bind(join Join)
+}
+
+// Join saves ids of many-to-many field,
+// backends bind it to fields of models on load.
+type Join interface {
+	Save(ids []any) error
+	// Table returns table of related models.
+	Table() Table
+}
+
+// ManyToMany is field of many-to-many relation with models of type T stored as list of their ids.
+// Table of T is set by tag `relation:"table"`, by default it is name of T.
+// Field of loaded model is bound to table, Add, Remove, Set and Clear save model at once.
+type ManyToMany[T Model] struct {
+	ids  []any
+	join Join
+}
+
+// NewManyToMany returns unbound field with ids.
+func NewManyToMany[T Model](ids ...any) ManyToMany[T] {
+	return ManyToMany[T]{ids: ids}
+}
+
+func (many ManyToMany[T]) IDs() []any {
+	return append([]any{}, many.ids...)
+}
+
+func (many *ManyToMany[T]) SetIDs(ids []any) {
+	many.ids = append([]any{}, ids...)
+}
+
+func (many ManyToMany[T]) Related() Model {
+	var zero T
+	typ := reflect.TypeOf(zero)
+	if typ != nil && typ.Kind() == reflect.Pointer {
+		if model, ok := reflect.New(typ.Elem()).Interface().(Model); ok {
+			return model
+		}
+	}
+	return zero
+}
+
+func (many *ManyToMany[T]) Bind(join Join) {
+	many.join = join
+}
+
+// Add adds models to relation.
+func (many *ManyToMany[T]) Add(models ...T) error {
+	ids := many.IDs()
+	for _, model := range models {
+		if indexOfId(ids, model.Id()) < 0 {
+			ids = append(ids, model.Id())
+		}
+	}
+	return many.save(ids)
+}
+
+// Remove removes models from relation.
+func (many *ManyToMany[T]) Remove(models ...T) error {
+	ids := many.IDs()
+	for _, model := range models {
+		if i := indexOfId(ids, model.Id()); i >= 0 {
+			ids = append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return many.save(ids)
+}
+
+// Set replaces models of relation.
+func (many *ManyToMany[T]) Set(models ...T) error {
+	ids := []any{}
+	for _, model := range models {
+		if indexOfId(ids, model.Id()) < 0 {
+			ids = append(ids, model.Id())
+		}
+	}
+	return many.save(ids)
+}
+
+// Clear removes all models from relation.
+func (many *ManyToMany[T]) Clear() error {
+	return many.save([]any{})
+}
+
+// All returns related models, missing models are skipped.
+func (many *ManyToMany[T]) All() ([]T, error) {
+	if many.join == nil {
+		return nil, fmt.Errorf("many to many: field is not bound to table")
+	}
+	table := many.join.Table()
+	if table == nil {
+		return nil, fmt.Errorf("many to many: related table is not opened")
+	}
+	var models []Model
+	if getter, ok := table.(ManyGetter); ok {
+		var err error
+		if models, err = getter.GetManyContext(context.Background(), many.ids); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, id := range many.ids {
+			if model := table.Manager().Get(id); model != nil {
+				models = append(models, model)
+			}
+		}
+	}
+	return fromModels[T](models), nil
+}
+
+// save saves ids by join, ids are restored on error.
+func (many *ManyToMany[T]) save(ids []any) error {
+	if many.join == nil {
+		return fmt.Errorf("many to many: field is not bound to table, model is not saved")
+	}
+	previous := many.ids
+	many.ids = ids
+	if err := many.join.Save(ids); err != nil {
+		many.ids = previous
+		return err
+	}
+	return nil
+}
+
+func (many ManyToMany[T]) MarshalJSON() ([]byte, error) {
+	if many.ids == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(many.ids)
+}
+
+// UnmarshalJSON reads list of ids, whole numbers are read as uint.
+func (many *ManyToMany[T]) UnmarshalJSON(data []byte) error {
+	ids := []any{}
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return err
+	}
+	for i, id := range ids {
+		if number, ok := id.(float64); ok && number >= 0 && number == float64(uint(number)) {
+			ids[i] = uint(number)
+		}
+	}
+	many.ids = ids
+	return nil
+}
+
+func indexOfId(ids []any, id any) int {
+	for i, other := range ids {
+		if fmt.Sprint(other) == fmt.Sprint(id) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,87 @@
+package interfaces
+
+import (
+	"sort"
+)
+
+// Condition is Params or Q.
+type Condition interface {
+	q() Q
+}
+
+const (
+	QAnd = "&&"
+	QOr  = "||"
+)
+
+// Q is tree of filter conditions.
+// Conditions of Params are joined by And, Params and Children are joined by Op.
+type Q struct {
+	Op       string // QAnd or QOr, QAnd if empty
+	Not      bool
+	Params   Params
+	Children []Q
+}
+
+func (q Q) q() Q {
+	return q
+}
+
+func (params Params) q() Q {
+	return Q{Params: params}
+}
+
+// And returns Q true if all conditions are true.
+func And(conditions ...Condition) Q {
+	return group(QAnd, conditions)
+}
+
+// Or returns Q true if any condition is true.
+func Or(conditions ...Condition) Q {
+	return group(QOr, conditions)
+}
+
+// Not returns Q true if not all conditions are true.
+func Not(conditions ...Condition) Q {
+	q := And(conditions...)
+	q.Not = !q.Not
+	return q
+}
+
+func group(op string, conditions []Condition) Q {
+	q := Q{Op: op}
+	for _, condition := range conditions {
+		if condition == nil {
+			continue
+		}
+		q.Children = append(q.Children, condition.q())
+	}
+	return q
+}
+
+// IsOr reports if Params and Children of q are joined by Or.
+func (q Q) IsOr() bool {
+	return q.Op == QOr
+}
+
+// FilterQ returns Q of ManagerI.Filter arguments:
+// model is included if all conditions of include are true
+// and it is excluded if any condition of exclude is true.
+func FilterQ(include Params, exclude ...Params) Q {
+	q := And(include)
+	excluded := []Condition{}
+	for _, params := range exclude {
+		keys := make([]string, 0, len(params))
+		for key := range params {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			excluded = append(excluded, Params{key: params[key]})
+		}
+	}
+	if len(excluded) > 0 {
+		q.Children = append(q.Children, Not(Or(excluded...)))
+	}
+	return q
+}
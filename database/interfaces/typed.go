@@ -0,0 +1,170 @@
+package interfaces
+
+import (
+	"fmt"
+)
+
+// TypedTable is Table returning models of type T.
+type TypedTable[T Model] struct {
+	Table
+}
+
+// NewTypedTable wraps table, model of table must be of type T.
+func NewTypedTable[T Model](table Table) (*TypedTable[T], error) {
+	if table == nil {
+		return nil, fmt.Errorf("typed table: table is nil")
+	}
+	if _, ok := table.Model().(T); !ok {
+		var zero T
+		return nil, fmt.Errorf("typed table: model of `%v` is %T, not %T", table.Name(), table.Model(), zero)
+	}
+	return &TypedTable[T]{table}, nil
+}
+
+// OpenTable returns table of db for models of type T.
+func OpenTable[T Model](db DB, name string, model T) (*TypedTable[T], error) {
+	table, err := db.Table(name, model)
+	if err != nil {
+		return nil, err
+	}
+	return NewTypedTable[T](table)
+}
+
+// Untyped returns wrapped Table.
+func (table *TypedTable[T]) Untyped() Table {
+	return table.Table
+}
+
+func (table *TypedTable[T]) Get(id any) (T, error) {
+	model, err := table.Table.Get(id)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return cast[T](model)
+}
+
+func (table *TypedTable[T]) Save(model T) error {
+	return table.Table.Save(model)
+}
+
+func (table *TypedTable[T]) SaveMany(models []T) error {
+	return table.Table.SaveMany(toModels(models))
+}
+
+func (table *TypedTable[T]) Manager() *TypedManager[T] {
+	return NewTypedManager[T](table.Table.Manager())
+}
+
+// TypedManager is ManagerI returning models of type T.
+type TypedManager[T Model] struct {
+	ManagerI
+}
+
+func NewTypedManager[T Model](manager ManagerI) *TypedManager[T] {
+	return &TypedManager[T]{manager}
+}
+
+// Untyped returns wrapped ManagerI.
+func (manager *TypedManager[T]) Untyped() ManagerI {
+	return manager.ManagerI
+}
+
+func (manager *TypedManager[T]) Get(id any) T {
+	model, _ := cast[T](manager.ManagerI.Get(id))
+	return model
+}
+
+func (manager *TypedManager[T]) All() []T {
+	return fromModels[T](manager.ManagerI.All())
+}
+
+func (manager *TypedManager[T]) Filter(include Params, exclude ...Params) *TypedManager[T] {
+	return NewTypedManager[T](manager.ManagerI.Filter(include, exclude...))
+}
+
+func (manager *TypedManager[T]) Where(q Q) *TypedManager[T] {
+	return NewTypedManager[T](manager.ManagerI.Where(q))
+}
+
+func (manager *TypedManager[T]) First() T {
+	model, _ := cast[T](manager.ManagerI.First())
+	return model
+}
+
+func (manager *TypedManager[T]) Last() T {
+	model, _ := cast[T](manager.ManagerI.Last())
+	return model
+}
+
+func (manager *TypedManager[T]) OrderBy(fields ...string) *TypedManager[T] {
+	return NewTypedManager[T](manager.ManagerI.OrderBy(fields...))
+}
+
+func (manager *TypedManager[T]) Limit(n uint) *TypedManager[T] {
+	return NewTypedManager[T](manager.ManagerI.Limit(n))
+}
+
+func (manager *TypedManager[T]) Offset(n uint) *TypedManager[T] {
+	return NewTypedManager[T](manager.ManagerI.Offset(n))
+}
+
+func (manager *TypedManager[T]) With(relations ...string) *TypedManager[T] {
+	return NewTypedManager[T](manager.ManagerI.With(relations...))
+}
+
+func (manager *TypedManager[T]) WithDeleted() *TypedManager[T] {
+	return NewTypedManager[T](manager.ManagerI.WithDeleted())
+}
+
+func (manager *TypedManager[T]) OnlyDeleted() *TypedManager[T] {
+	return NewTypedManager[T](manager.ManagerI.OnlyDeleted())
+}
+
+// Iterate stops at model which is not of type T and returns error of it.
+func (manager *TypedManager[T]) Iterate(fn func(model T) (continue_ bool), conditions ...Condition) error {
+	var castErr error
+	err := manager.ManagerI.Iterate(func(model Model) bool {
+		typed, err := cast[T](model)
+		if err != nil {
+			castErr = err
+			return false
+		}
+		return fn(typed)
+	}, conditions...)
+	if castErr != nil {
+		return castErr
+	}
+	return err
+}
+
+// cast returns zero T without error for nil model.
+func cast[T Model](model Model) (T, error) {
+	var zero T
+	if model == nil {
+		return zero, nil
+	}
+	typed, ok := model.(T)
+	if !ok {
+		return zero, fmt.Errorf("typed table: model is %T, not %T", model, zero)
+	}
+	return typed, nil
+}
+
+func toModels[T Model](typed []T) []Model {
+	models := make([]Model, len(typed))
+	for i, model := range typed {
+		models[i] = model
+	}
+	return models
+}
+
+func fromModels[T Model](models []Model) []T {
+	typed := make([]T, 0, len(models))
+	for _, model := range models {
+		if model, ok := model.(T); ok {
+			typed = append(typed, model)
+		}
+	}
+	return typed
+}
@@ -0,0 +1,95 @@
+package interfaces_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/dbtest"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	"github.com/PoulIgorson/sub_engine_fiber/database/memory"
+)
+
+// typedNames returns names of items joined by ",".
+func typedNames(items []*dbtest.TestItem) string {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestTypedTable(t *testing.T) {
+	db := memory.New()
+	table, err := OpenTable(db, "test_item", &dbtest.TestItem{ID: uint(0)})
+	if err != nil {
+		t.Fatalf("OpenTable: %v", err)
+	}
+	items := []*dbtest.TestItem{}
+	for i, name := range []string{"a", "b", "c"} {
+		item := &dbtest.TestItem{ID: uint(0), Name: name, Year: 2000 + i}
+		items = append(items, item)
+	}
+	if err := table.SaveMany(items); err != nil {
+		t.Fatalf("SaveMany: %v", err)
+	}
+
+	item, err := table.Get(items[1].ID)
+	if err != nil || item.Name != "b" {
+		t.Fatalf("Get returns %v, %v, expected b", item, err)
+	}
+	manager := table.Manager()
+	if got := manager.Get(items[2].ID); got == nil || got.Name != "c" {
+		t.Errorf("Manager.Get returns %v, expected c", got)
+	}
+	if got := manager.Get(uint(100)); got != nil {
+		t.Errorf("Manager.Get of missing id returns %v", got)
+	}
+	if got := typedNames(manager.OrderBy("Name").All()); got != "a,b,c" {
+		t.Errorf("All returns %v, expected a,b,c", got)
+	}
+	if got := typedNames(manager.Filter(Params{"Year>": 2000}).OrderBy("-Name").All()); got != "c,b" {
+		t.Errorf("Filter returns %v, expected c,b", got)
+	}
+	if got := manager.OrderBy("Year").First(); got == nil || got.Name != "a" {
+		t.Errorf("First returns %v, expected a", got)
+	}
+	if got := manager.OrderBy("Year").Last(); got == nil || got.Name != "c" {
+		t.Errorf("Last returns %v, expected c", got)
+	}
+	iterated := []*dbtest.TestItem{}
+	err = manager.OrderBy("Name").Iterate(func(item *dbtest.TestItem) bool {
+		iterated = append(iterated, item)
+		return true
+	})
+	if err != nil || typedNames(iterated) != "a,b,c" {
+		t.Errorf("Iterate returns %v, %v, expected a,b,c", typedNames(iterated), err)
+	}
+}
+
+func TestTypedMismatch(t *testing.T) {
+	db := memory.New()
+	untyped, err := db.Table("test_item", &dbtest.TestItem{ID: uint(0)})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	if err := untyped.Save(&dbtest.TestItem{ID: uint(0), Name: "a"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := NewTypedTable[*dbtest.SoftItem](untyped); err == nil {
+		t.Errorf("NewTypedTable of model of other type returns no error")
+	}
+
+	// manager of other table returns models of other type
+	manager := NewTypedManager[*dbtest.SoftItem](untyped.Manager())
+	called := false
+	err = manager.Iterate(func(item *dbtest.SoftItem) bool {
+		called = true
+		return true
+	})
+	if err == nil || !strings.Contains(err.Error(), "*dbtest.TestItem") {
+		t.Errorf("Iterate of models of other type returns %v, expected error of type", err)
+	}
+	if called {
+		t.Errorf("Iterate calls fn with model of other type")
+	}
+}
@@ -0,0 +1,306 @@
+// Package memory implements db keeping models in memory, it is for tests and prototyping.
+package memory
+
+import (
+	"sync"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+var _ DB = &DataBase{}
+
+type tableMap struct {
+	sync.Map
+}
+
+func (m *tableMap) Load(name string) *MemTable {
+	v, ok := m.Map.Load(name)
+	if !ok {
+		return nil
+	}
+	return v.(*MemTable)
+}
+
+func (m *tableMap) LoadOK(name string) (*MemTable, bool) {
+	v, ok := m.Map.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return v.(*MemTable), true
+}
+
+func (m *tableMap) LoadOrStore(name string, table *MemTable) (*MemTable, bool) {
+	v, loaded := m.Map.LoadOrStore(name, table)
+	return v.(*MemTable), loaded
+}
+
+func (m *tableMap) Range(fn func(name string, table *MemTable) (continue_ bool)) {
+	m.Map.Range(func(nameI any, tableI any) bool {
+		return fn(nameI.(string), tableI.(*MemTable))
+	})
+}
+
+// store is committed records of table, json of models by their ids.
+type store struct {
+	mu      sync.RWMutex
+	records map[any]string
+	counter uint // last uint id
+}
+
+// data is stores of tables shared by db and its transactions.
+type data struct {
+	writer sync.Mutex // transactions are serial
+	mu     sync.Mutex
+	stores map[string]*store
+}
+
+func (data *data) store(name string) *store {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+	s, ok := data.stores[name]
+	if !ok {
+		s = &store{records: map[any]string{}}
+		data.stores[name] = s
+	}
+	return s
+}
+
+// changes is records written by transaction, nil record is deleted.
+type changes struct {
+	records  map[string]map[any]*string
+	counters map[string]uint
+	cleared  map[string]bool
+}
+
+// DataBase implements interface access to models in memory.
+// Writes are made in transactions, they are serial and isolated from reads until commit.
+type DataBase struct {
+	data      *data
+	tables    tableMap // map[string]Table
+	relations *base.Relations
+
+	// set for view returned by Tx
+	tx          *changes
+	parent      *DataBase
+	afterCommit *base.Deferred
+}
+
+// New returns empty DataBase.
+func New() *DataBase {
+	return &DataBase{
+		data:      &data{stores: map[string]*store{}},
+		relations: &base.Relations{},
+	}
+}
+
+// Tx runs fn in one transaction, tables of tx share it.
+// Changes are applied after fn without error, managers are updated after commit only.
+func (db *DataBase) Tx(fn func(tx DB) error) error {
+	if db.tx != nil {
+		return fn(db)
+	}
+	db.data.writer.Lock()
+	defer db.data.writer.Unlock()
+	txDB := &DataBase{
+		data:      db.data,
+		relations: db.relations,
+		tx: &changes{
+			records:  map[string]map[any]*string{},
+			counters: map[string]uint{},
+			cleared:  map[string]bool{},
+		},
+		parent:      db,
+		afterCommit: &base.Deferred{},
+	}
+	if err := fn(txDB); err != nil {
+		return err
+	}
+	txDB.commit()
+	txDB.afterCommit.Run()
+	return nil
+}
+
+// commit applies changes of transaction to stores.
+func (db *DataBase) commit() {
+	names := map[string]bool{}
+	for name := range db.tx.records {
+		names[name] = true
+	}
+	for name := range db.tx.cleared {
+		names[name] = true
+	}
+	for name := range names {
+		s := db.data.store(name)
+		s.mu.Lock()
+		if db.tx.cleared[name] {
+			s.records = map[any]string{}
+		}
+		for id, record := range db.tx.records[name] {
+			if record == nil {
+				delete(s.records, id)
+			} else {
+				s.records[id] = *record
+			}
+		}
+		if counter := db.tx.counters[name]; counter > s.counter {
+			s.counter = counter
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Close implements access to close DataBase, models are removed.
+func (db *DataBase) Close() error {
+	if db.tx != nil {
+		return NewErrorf("memory: transaction can not be closed")
+	}
+	db.data.writer.Lock()
+	defer db.data.writer.Unlock()
+	db.tables.Range(func(_ string, table *MemTable) (continue_ bool) {
+		table.Objects.Clear()
+		return true
+	})
+	db.tables = tableMap{}
+	db.data.mu.Lock()
+	db.data.stores = map[string]*store{}
+	db.data.mu.Unlock()
+	return nil
+}
+
+func (db *DataBase) TableFromCache(name string) Table {
+	table := db.tables.Load(name)
+	if table == nil && db.tx != nil {
+		if parent := db.parent.tables.Load(name); parent != nil {
+			txTable, _ := db.Table(name, parent.model)
+			return txTable
+		}
+	}
+	if table == nil {
+		return nil
+	}
+	return table
+}
+
+// Table returns table `name` of model, id of model must be uint or string.
+// Empty name is name of model.
+func (db *DataBase) Table(name string, model Model) (Table, error) {
+	if name == "" {
+		name = GetNameModel(model)
+	}
+	if table := db.tables.Load(name); table != nil {
+		return table, nil
+	}
+	idText := false
+	switch model.Id().(type) {
+	case uint:
+	case string:
+		idText = true
+	default:
+		// id of user is any, it is uint as in bbolt
+		if model.Id() != nil || name != "user" {
+			return nil, NewErrorf("memory: id must be uint or string")
+		}
+	}
+
+	if db.tx != nil {
+		parent, err := db.parent.Table(name, model)
+		if err != nil {
+			return nil, err
+		}
+		table := &MemTable{
+			db:     db,
+			name:   name,
+			model:  model,
+			idText: idText,
+			parent: parent.(*MemTable),
+		}
+		table.Objects = newTxManager(table)
+		db.tables.Store(name, table)
+		return table, nil
+	}
+
+	db.relations.Register(name, model)
+	table := &MemTable{
+		db:     db,
+		name:   name,
+		model:  model,
+		idText: idText,
+	}
+	manager := base.NewManager(table)
+	manager.UseCache = true
+	table.Objects = manager
+	table, _ = db.tables.LoadOrStore(name, table)
+	table.load()
+	return table, nil
+}
+
+// ExistsTable returns true if table is opened or has models.
+func (db *DataBase) ExistsTable(name string) bool {
+	if _, ok := db.tables.LoadOK(name); ok {
+		return true
+	}
+	db.data.mu.Lock()
+	defer db.data.mu.Unlock()
+	_, ok := db.data.stores[name]
+	return ok
+}
+
+// Snapshot is copy of models of all tables of db.
+type Snapshot struct {
+	stores map[string]snapshotStore
+}
+
+// snapshotStore is copy of records of store.
+type snapshotStore struct {
+	records map[any]string
+	counter uint
+}
+
+// Snapshot returns copy of committed models of db.
+func (db *DataBase) Snapshot() *Snapshot {
+	db.data.writer.Lock()
+	defer db.data.writer.Unlock()
+	snapshot := &Snapshot{stores: map[string]snapshotStore{}}
+	db.data.mu.Lock()
+	defer db.data.mu.Unlock()
+	for name, s := range db.data.stores {
+		s.mu.RLock()
+		records := make(map[any]string, len(s.records))
+		for id, record := range s.records {
+			records[id] = record
+		}
+		snapshot.stores[name] = snapshotStore{records: records, counter: s.counter}
+		s.mu.RUnlock()
+	}
+	return snapshot
+}
+
+// Reset restores models of snapshot, all models are removed without it.
+// Managers of opened tables are reloaded.
+func (db *DataBase) Reset(snapshot ...*Snapshot) error {
+	if db.tx != nil {
+		return NewErrorf("memory: transaction can not be reset")
+	}
+	db.data.writer.Lock()
+	defer db.data.writer.Unlock()
+	db.data.mu.Lock()
+	db.data.stores = map[string]*store{}
+	if len(snapshot) > 0 && snapshot[0] != nil {
+		for name, s := range snapshot[0].stores {
+			records := make(map[any]string, len(s.records))
+			for id, record := range s.records {
+				records[id] = record
+			}
+			db.data.stores[name] = &store{records: records, counter: s.counter}
+		}
+	}
+	db.data.mu.Unlock()
+	db.tables.Range(func(_ string, table *MemTable) (continue_ bool) {
+		table.load()
+		return true
+	})
+	return nil
+}
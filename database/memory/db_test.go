@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/dbtest"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+func TestConformance(t *testing.T) {
+	t.Run("UintIds", func(t *testing.T) {
+		dbtest.Run(t, dbtest.Config{Open: func(t *testing.T) DB {
+			return New()
+		}})
+	})
+	t.Run("StringIds", func(t *testing.T) {
+		dbtest.Run(t, dbtest.Config{StringIds: true, Open: func(t *testing.T) DB {
+			return New()
+		}})
+	})
+}
+
+// names returns names of items of table ordered by name.
+func names(table Table) string {
+	names := []string{}
+	for _, model := range table.Manager().OrderBy("Name").All() {
+		names = append(names, model.(*dbtest.TestItem).Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// openItems returns table `name` of dbtest.TestItem of db.
+func openItems(t *testing.T, db DB, name string) Table {
+	t.Helper()
+	table, err := db.Table(name, &dbtest.TestItem{ID: uint(0)})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	return table
+}
+
+func TestTableName(t *testing.T) {
+	db := New()
+	items, archive := openItems(t, db, ""), openItems(t, db, "archive")
+	if items.Name() != "test_item" || archive.Name() != "archive" {
+		t.Fatalf("tables are named %v and %v, expected test_item and archive", items.Name(), archive.Name())
+	}
+	if err := archive.Save(&dbtest.TestItem{ID: uint(0), Name: "a"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if items.Count() != 0 || archive.Count() != 1 {
+		t.Fatalf("tables of one model share models: %v and %v", items.Count(), archive.Count())
+	}
+	if db.TableFromCache("archive") != archive {
+		t.Fatalf("TableFromCache does not return table of name")
+	}
+}
+
+func TestSnapshotReset(t *testing.T) {
+	db := New()
+	table := openItems(t, db, "")
+	for _, name := range []string{"a", "b"} {
+		if err := table.Save(&dbtest.TestItem{ID: uint(0), Name: name}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	snapshot := db.Snapshot()
+
+	if err := table.Delete(uint(1)); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := table.Save(&dbtest.TestItem{ID: uint(0), Name: "c"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if got := names(table); got != "b,c" {
+		t.Fatalf("models before Reset are %v, expected b,c", got)
+	}
+
+	if err := db.Reset(snapshot); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if got := names(table); got != "a,b" {
+		t.Fatalf("models after Reset are %v, expected a,b", got)
+	}
+	// counter of ids is restored too
+	item := &dbtest.TestItem{ID: uint(0), Name: "d"}
+	if err := table.Save(item); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if item.ID != uint(3) {
+		t.Fatalf("id after Reset is %v, expected 3", item.ID)
+	}
+	// snapshot is not changed by writes after Reset
+	if err := db.Reset(snapshot); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if got := names(table); got != "a,b" {
+		t.Fatalf("models after second Reset are %v, expected a,b", got)
+	}
+
+	if err := db.Reset(); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if table.Count() != 0 || len(table.Manager().All()) != 0 {
+		t.Fatalf("Reset without snapshot keeps models")
+	}
+
+	err := db.Tx(func(tx DB) error {
+		return tx.(*DataBase).Reset(snapshot)
+	})
+	if err == nil {
+		t.Fatalf("Reset of transaction returns no error")
+	}
+}
+
+func TestConcurrentSnapshots(t *testing.T) {
+	db := New()
+	table := openItems(t, db, "")
+	snapshot := db.Snapshot()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				switch j % 4 {
+				case 0:
+					table.Save(&dbtest.TestItem{ID: uint(0), Name: fmt.Sprint(i, "-", j)})
+				case 1:
+					table.Manager().Filter(Params{"Year": 0}).All()
+				case 2:
+					db.Snapshot()
+				case 3:
+					if i == 0 {
+						db.Reset(snapshot)
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// stored models and models of manager are the same after concurrent writes
+	count := table.Count()
+	if all := table.Manager().All(); uint(len(all)) != count {
+		t.Fatalf("manager has %v models, table has %v", len(all), count)
+	}
+	ids := map[any]bool{}
+	for _, model := range table.Manager().All() {
+		if ids[model.Id()] {
+			t.Fatalf("id %v is assigned twice", model.Id())
+		}
+		ids[model.Id()] = true
+	}
+}
@@ -0,0 +1,440 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+var _ ContextTable = &MemTable{}
+
+// MemTable implements interface access to models of table in memory,
+// models are stored as json and manager keeps loaded models.
+type MemTable struct {
+	db     *DataBase
+	name   string
+	idText bool // id is string, else it is uint
+
+	model   Model
+	Objects ManagerI
+
+	// parent is table of parent db, set for table of transaction
+	parent *MemTable
+}
+
+func (table *MemTable) Manager() ManagerI {
+	return table.Objects
+}
+
+// cache returns manager of table outside of transaction,
+// it is updated after commit.
+func (table *MemTable) cache() ManagerI {
+	if table.parent != nil {
+		return table.parent.Objects
+	}
+	return table.Objects
+}
+
+func (table *MemTable) SetManager(newManager ManagerI) {
+	table.Objects = newManager
+}
+
+// DB returns pointer to DB.
+func (table *MemTable) DB() DB {
+	return table.db
+}
+
+// Name returns name of table.
+func (table *MemTable) Name() string {
+	return table.name
+}
+
+// Model returns model of table.
+func (table *MemTable) Model() Model {
+	return table.model
+}
+
+// checkId returns id of table of idI, ids read from json are float64.
+func (table *MemTable) checkId(idI any) (any, error) {
+	if table.idText {
+		id, ok := idI.(string)
+		if !ok {
+			return nil, NewErrorf("memory: id must be string")
+		}
+		return id, nil
+	}
+	switch id := idI.(type) {
+	case uint:
+		return id, nil
+	case float64:
+		return uint(id), nil
+	}
+	return nil, NewErrorf("memory: id must be uint")
+}
+
+// load fills manager of table with stored models.
+func (table *MemTable) load() {
+	table.Objects.Clear()
+	for id, record := range table.records() {
+		table.Objects.Store(id, table.model.Create(table.db, record))
+	}
+}
+
+// get returns json of model of id, changes of transaction are seen by it.
+func (table *MemTable) get(id any) (string, bool) {
+	if table.db.tx != nil {
+		if record, ok := table.db.tx.records[table.name][id]; ok {
+			if record == nil {
+				return "", false
+			}
+			return *record, true
+		}
+		if table.db.tx.cleared[table.name] {
+			return "", false
+		}
+	}
+	s := table.db.data.store(table.name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[id]
+	return record, ok
+}
+
+// records returns json of all models of table.
+func (table *MemTable) records() map[any]string {
+	records := map[any]string{}
+	if table.db.tx == nil || !table.db.tx.cleared[table.name] {
+		s := table.db.data.store(table.name)
+		s.mu.RLock()
+		for id, record := range s.records {
+			records[id] = record
+		}
+		s.mu.RUnlock()
+	}
+	if table.db.tx != nil {
+		for id, record := range table.db.tx.records[table.name] {
+			if record == nil {
+				delete(records, id)
+			} else {
+				records[id] = *record
+			}
+		}
+	}
+	return records
+}
+
+// put writes json of model of id to transaction, nil record deletes model.
+func (table *MemTable) put(id any, record *string) {
+	if table.db.tx.records[table.name] == nil {
+		table.db.tx.records[table.name] = map[any]*string{}
+	}
+	table.db.tx.records[table.name][id] = record
+}
+
+// nextId returns next uint id of table inside transaction.
+func (table *MemTable) nextId() uint {
+	counter := table.db.tx.counters[table.name]
+	s := table.db.data.store(table.name)
+	s.mu.RLock()
+	if s.counter > counter {
+		counter = s.counter
+	}
+	s.mu.RUnlock()
+	table.db.tx.counters[table.name] = counter + 1
+	return counter + 1
+}
+
+// Count returns count of models of table, soft deleted models too.
+func (table *MemTable) Count() uint {
+	return uint(len(table.records()))
+}
+
+func (table *MemTable) Get(idI any) (Model, error) {
+	return table.GetContext(context.Background(), idI)
+}
+
+func (table *MemTable) GetContext(ctx context.Context, idI any) (Model, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	id, err := table.checkId(idI)
+	if err != nil {
+		return nil, err
+	}
+	record, ok := table.get(id)
+	if !ok {
+		return nil, NewErrorf("memory: Table.Get: id `%v` is not exists", id)
+	}
+	return table.model.Create(table.db, record), nil
+}
+
+func (table *MemTable) Save(model Model) error {
+	return table.SaveContext(context.Background(), model)
+}
+
+// SaveContext writes model in one transaction. Model without id gets next id of table,
+// uint or random string, model of missing id is inserted with it.
+func (table *MemTable) SaveContext(ctx context.Context, model Model) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fieldId, err := Check(model, "ID")
+	if err != nil {
+		return NewErrorf("memory: " + err.Error())
+	}
+	if model.Id() == nil {
+		// model without id is new model
+		if table.idText {
+			fieldId.Set(reflect.ValueOf(""))
+		} else {
+			fieldId.Set(reflect.ValueOf(uint(0)))
+		}
+	}
+	id, err := table.checkId(model.Id())
+	if err != nil {
+		return err
+	}
+	version := base.ModelVersion(model)
+	if table.db.tx == nil {
+		err := table.db.Tx(func(tx DB) error {
+			return tx.TableFromCache(table.name).(*MemTable).SaveContext(ctx, model)
+		})
+		if err != nil {
+			// id assigned in rolled back transaction
+			fieldId.Set(reflect.ValueOf(id))
+			base.SetVersion(model, version)
+			return err
+		}
+		// fields are bound to table, not to finished transaction
+		base.BindManyRelations(table, model)
+		return nil
+	}
+	if err := base.BeforeSave(table, model); err != nil {
+		return err
+	}
+	if err := Validate(table.name, model); err != nil {
+		return err
+	}
+
+	old, exists := table.get(id)
+	if exists {
+		oldModel := table.model.Create(table.db, old)
+		if err := base.NextVersion(ctx, table.name, model, base.ModelVersion(oldModel), true); err != nil {
+			return err
+		}
+		base.Stamp(ctx, model, base.AutoTime(oldModel, AutoCreated), time.Now().UTC())
+	} else {
+		base.NextVersion(ctx, table.name, model, 0, false)
+		base.Stamp(ctx, model, time.Time{}, time.Now().UTC())
+	}
+	if err := table.checkUnique(id, model); err != nil {
+		return err
+	}
+	switch {
+	case id == uint(0):
+		id = table.nextId()
+	case id == "":
+		id = base.NewStringId()
+	case !table.idText && id.(uint) > table.db.tx.counters[table.name]:
+		table.db.tx.counters[table.name] = id.(uint)
+	}
+	fieldId.Set(reflect.ValueOf(id))
+
+	buf, err := json.Marshal(model)
+	if err != nil {
+		return NewErrorf("memory: Table.Save: %v", err)
+	}
+	record := string(buf)
+	table.put(id, &record)
+
+	base.BindManyRelations(table, model)
+	if err := base.AfterSave(table, model); err != nil {
+		return err
+	}
+	stored := table.model.Create(table.db, record)
+	table.db.afterCommit.Do(func() { table.cache().Store(id, stored) })
+	return nil
+}
+
+// checkUnique returns ErrUnique if values of unique set of model belong to other model.
+func (table *MemTable) checkUnique(id any, model Model) error {
+	sets := UniqueSets(table.model)
+	if len(sets) == 0 {
+		return nil
+	}
+	keys := make([]string, len(sets))
+	for i, set := range sets {
+		keys[i] = uniqueKey(model, set)
+	}
+	records := table.records()
+	ids := make([]any, 0, len(records))
+	for otherId := range records {
+		ids = append(ids, otherId)
+	}
+	sort.Slice(ids, func(i, j int) bool { return Compare(ids[i], ids[j]) == -1 })
+	for _, otherId := range ids {
+		if otherId == id {
+			continue
+		}
+		other := table.model.Create(table.db, records[otherId])
+		for i, set := range sets {
+			if keys[i] != "" && uniqueKey(other, set) == keys[i] {
+				return NewErrUnique(table.name, JSONNames(table.model, set))
+			}
+		}
+	}
+	return nil
+}
+
+// uniqueKey returns json of values of fields of set, empty string if some value is nil.
+func uniqueKey(model Model, set []string) string {
+	values := []any{}
+	for _, field := range set {
+		value, err := Check(model, field)
+		if err != nil || (value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface) && value.IsNil() {
+			return ""
+		}
+		values = append(values, value.Interface())
+	}
+	key, _ := json.Marshal(values)
+	return string(key)
+}
+
+func (table *MemTable) Delete(idI any) error {
+	return table.DeleteContext(context.Background(), idI)
+}
+
+// DeleteContext removes model of id, soft deleted models are marked by field `deleted_at`.
+// Missing id is skipped.
+func (table *MemTable) DeleteContext(ctx context.Context, idI any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	id, err := table.checkId(idI)
+	if err != nil {
+		return err
+	}
+	if SoftDeleteField(table.model) != "" {
+		return base.SoftDeleteContext(ctx, table, id)
+	}
+	return table.hardDelete(id)
+}
+
+// HardDelete removes model of id, soft deleted models too.
+func (table *MemTable) HardDelete(idI any) error {
+	id, err := table.checkId(idI)
+	if err != nil {
+		return err
+	}
+	return table.hardDelete(id)
+}
+
+// Restore clears field `deleted_at` of soft deleted model of id.
+func (table *MemTable) Restore(idI any) error {
+	return base.Restore(table, idI)
+}
+
+// hardDelete removes model of id with actions of relations in one transaction.
+func (table *MemTable) hardDelete(id any) error {
+	if table.db.tx == nil {
+		return table.db.Tx(func(tx DB) error {
+			return tx.TableFromCache(table.name).(*MemTable).hardDelete(id)
+		})
+	}
+	record, ok := table.get(id)
+	if !ok {
+		return nil
+	}
+	model := table.model.Create(table.db, record)
+	if err := base.BeforeDelete(table, model); err != nil {
+		return err
+	}
+	if err := table.db.relations.OnDelete(table.db, table.name, id); err != nil {
+		return err
+	}
+	table.put(id, nil)
+	if err := base.AfterDelete(table, model); err != nil {
+		return err
+	}
+	table.db.afterCommit.Do(func() { table.cache().ClearId(id) })
+	return nil
+}
+
+// SaveMany saves models in one transaction.
+func (table *MemTable) SaveMany(models []Model) error {
+	return base.SaveMany(table, models)
+}
+
+// DeleteMany deletes models of ids in one transaction.
+func (table *MemTable) DeleteMany(ids []any) error {
+	return base.DeleteMany(table, ids)
+}
+
+// DeleteAll removes all models of table, counter of ids is kept.
+func (table *MemTable) DeleteAll() error {
+	if table.db.tx == nil {
+		return table.db.Tx(func(tx DB) error {
+			return tx.TableFromCache(table.name).DeleteAll()
+		})
+	}
+	table.db.tx.cleared[table.name] = true
+	delete(table.db.tx.records, table.name)
+	table.db.afterCommit.Do(table.cache().Clear)
+	return nil
+}
+
+// newTxManager returns manager of table of transaction, its queries read
+// models of table with changes of transaction instead of cache.
+func newTxManager(table *MemTable) *base.Manager {
+	manager := base.NewManager(table)
+	manager.OnAll = func(ctx context.Context, manager ManagerI) ([]Model, error) {
+		baseManager := manager.(*base.Manager)
+		if manager.IsInstance() {
+			return baseManager.Cached(ctx)
+		}
+		query := baseManager.Query()
+		models, err := table.find(ctx, baseManager, query.Q())
+		return query.Page(models), err
+	}
+	manager.OnFilter = func(ctx context.Context, manager ManagerI, q Q) ([]Model, error) {
+		baseManager := manager.(*base.Manager)
+		models, err := table.find(ctx, baseManager, q)
+		return baseManager.Query().Page(models), err
+	}
+	manager.OnIterate = func(ctx context.Context, manager ManagerI, q Q, fn func(model Model) bool) error {
+		models, err := table.find(ctx, manager.(*base.Manager), q)
+		for _, model := range models {
+			if !fn(model) {
+				break
+			}
+		}
+		return err
+	}
+	return manager
+}
+
+// find returns models of table satisfying q ordered by query of manager,
+// changes of transaction are seen by it.
+func (table *MemTable) find(ctx context.Context, manager *base.Manager, q Q) ([]Model, error) {
+	models := []Model{}
+	for _, record := range table.records() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		model := table.model.Create(table.db, record)
+		manager.CheckPointers(model)
+		if manager.CheckQ(model, q) {
+			models = append(models, model)
+		}
+	}
+	manager.Query().Sort(models)
+	return models, nil
+}
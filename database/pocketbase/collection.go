@@ -1,16 +1,20 @@
 package pocketbase
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"reflect"
+	"time"
 
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
 	. "github.com/PoulIgorson/sub_engine_fiber/define"
 )
 
-var _ Table = &Collection{}
+var _ ContextTable = &Collection{}
 
 type Collection struct {
 	db   *DataBase
@@ -33,17 +37,22 @@ func (collection *Collection) Model() Model {
 }
 
 func (collection *Collection) Get(idI any) (Model, error) {
+	return collection.GetContext(context.Background(), idI)
+}
+
+func (collection *Collection) GetContext(ctx context.Context, idI any) (Model, error) {
 	id, ok := idI.(string)
 	if !ok {
 		return nil, NewErrorf("pb: id must be string")
 	}
-	records, err := collection.db.pb.Filter(collection.name, map[string]any{"id": id})
+	records, err := collection.db.pb.FilterContext(ctx, collection.name, map[string]any{"id": id})
 	if err != nil {
 		return nil, ToError(err)
 	}
 	if len(records) == 0 {
 		return nil, NewErrorf("pb: record not found")
 	}
+	MapAutoFields(collection.model, records[0].data)
 	dataByte, _ := json.Marshal(records[0].data)
 	model := collection.model.Create(collection.db, string(dataByte))
 	collection.Objects.Store(model.Id().(string), model)
@@ -51,12 +60,60 @@ func (collection *Collection) Get(idI any) (Model, error) {
 }
 
 func (collection *Collection) Save(model Model) error {
+	return collection.SaveContext(context.Background(), model)
+}
+
+func (collection *Collection) SaveContext(ctx context.Context, model Model) error {
+	token, err := collection.db.pb.getTokenContext(ctx)
+	if err != nil {
+		return NewErrorf("pb.Collection.Save.token: %v", err)
+	}
+	return collection.save(ctx, token, model)
+}
+
+// save writes model with hooks of model,
+// pocketbase has not transactions, changes of hooks are not rolled back.
+// Version of versioned model is checked by pocketbase by VersionRule of collection,
+// update of record of other version is not found and ErrConflict is returned.
+func (collection *Collection) save(ctx context.Context, token string, model Model) error {
+	if model.Id() == nil {
+		// model without id is new model
+		field_id, err := Check(model, "ID")
+		if err != nil {
+			return NewErrorf("pb: " + err.Error())
+		}
+		field_id.Set(reflect.ValueOf(""))
+	}
+	if err := base.BeforeSave(collection, model); err != nil {
+		return err
+	}
+	if err := Validate(collection.name, model); err != nil {
+		return err
+	}
+	version := base.ModelVersion(model)
+	exists := model.Id() != ""
+	if err := collection.nextVersion(ctx, model, exists); err != nil {
+		return err
+	}
+	// pocketbase sets auto fields itself, they are mapped on load
+	base.Stamp(ctx, model, time.Time{}, time.Now().UTC())
 	dataByte, _ := json.Marshal(model)
 	data := map[string]any{}
 	json.Unmarshal(dataByte, &data)
+	DropAutoFields(model, data)
 	form := NewForm(collection.db.pb, NewRecord(collection.name, collection.db.pb))
 	form.LoadData(data)
-	id, err := form.Submit()
+	id, err := form.submit(ctx, token)
+	if err != nil {
+		base.SetVersion(model, version)
+	}
+	respErr, _ := err.(ResponseError)
+	if len(respErr.NotUnique()) > 0 {
+		return NewErrUnique(collection.name, respErr.NotUnique())
+	}
+	if exists && respErr.Status == 404 && VersionField(model) != "" {
+		return NewErrConflict(collection.name, model.Id(), version)
+	}
 	if err != nil {
 		return ToError(err)
 	}
@@ -66,18 +123,144 @@ func (collection *Collection) Save(model Model) error {
 		return NewErrorf("pb: " + err.Error())
 	}
 	field_id.Set(reflect.ValueOf(id))
+	base.BindManyRelations(collection, model)
 	if collection.Objects.IsInstance() {
 		collection.Objects.Store(model.Id(), model)
 	}
-	return nil
+	return base.AfterSave(collection, model)
+}
+
+// nextVersion increments version of versioned model, pocketbase checks it by VersionRule.
+// Rules of collections do not apply to admins, so for admin stored version
+// is requested before update, concurrent update between requests is not detected.
+func (collection *Collection) nextVersion(ctx context.Context, model Model, exists bool) error {
+	if VersionField(model) == "" {
+		return nil
+	}
+	stored := base.ModelVersion(model)
+	if exists && collection.db.pb.IsAdmin() {
+		records, err := collection.db.pb.FilterContext(ctx, collection.name, map[string]any{"id": model.Id()})
+		if err != nil {
+			return ToError(err)
+		}
+		if len(records) == 0 {
+			return NewErrConflict(collection.name, model.Id(), stored)
+		}
+		MapAutoFields(collection.model, records[0].data)
+		dataByte, _ := json.Marshal(records[0].data)
+		stored = base.ModelVersion(collection.model.Create(collection.db, string(dataByte)))
+	}
+	if !exists {
+		stored = 0
+	}
+	return base.NextVersion(ctx, collection.name, model, stored, exists)
 }
 
 func (collection *Collection) Delete(idI any) error {
+	return collection.DeleteContext(context.Background(), idI)
+}
+
+// DeleteContext removes model of id, soft deleted models are marked by field `deleted_at`.
+func (collection *Collection) DeleteContext(ctx context.Context, idI any) error {
+	id, ok := idI.(string)
+	if !ok {
+		return NewErrorf("pb: id must be string")
+	}
+	if SoftDeleteField(collection.model) != "" {
+		return base.SoftDeleteContext(ctx, collection, id)
+	}
+	token, err := collection.db.pb.getTokenContext(ctx)
+	if err != nil {
+		return NewErrorf("pb.Collection.Delete.token: %v", err)
+	}
+	return collection.delete(ctx, token, id)
+}
+
+// HardDelete removes model of id, soft deleted models too.
+func (collection *Collection) HardDelete(idI any) error {
 	id, ok := idI.(string)
 	if !ok {
 		return NewErrorf("pb: id must be string")
 	}
-	return ToError(collection.db.pb.Delete(collection.name, id))
+	ctx := context.Background()
+	token, err := collection.db.pb.getTokenContext(ctx)
+	if err != nil {
+		return NewErrorf("pb.Collection.HardDelete.token: %v", err)
+	}
+	return collection.delete(ctx, token, id)
+}
+
+// Restore clears field `deleted_at` of soft deleted model of id.
+func (collection *Collection) Restore(idI any) error {
+	return base.Restore(collection, idI)
+}
+
+// delete removes record of id with hooks of model and actions of relations,
+// pocketbase has not transactions, their changes are not rolled back.
+func (collection *Collection) delete(ctx context.Context, token, id string) error {
+	var model Model
+	if base.HasDeleteHooks(collection.model) {
+		if model, _ = collection.GetContext(ctx, id); model != nil {
+			if err := base.BeforeDelete(collection, model); err != nil {
+				return err
+			}
+		}
+	}
+	if err := collection.db.relations.OnDelete(collection.db, collection.name, id); err != nil {
+		return err
+	}
+	if err := ToError(collection.db.pb.delete(ctx, token, collection.name, id)); err != nil {
+		return err
+	}
+	if model != nil {
+		return base.AfterDelete(collection, model)
+	}
+	return nil
+}
+
+// SaveMany saves models with one auth token,
+// pocketbase has not transactions, saved items are not rolled back.
+func (collection *Collection) SaveMany(models []Model) error {
+	ctx := context.Background()
+	token, err := collection.db.pb.getTokenContext(ctx)
+	if err != nil {
+		return NewErrorf("pb.Collection.SaveMany.token: %v", err)
+	}
+	errs := make([]error, len(models))
+	for i, model := range models {
+		errs[i] = collection.save(ctx, token, model)
+	}
+	if batch := NewErrBatch(errs); batch.Failed() > 0 {
+		return batch
+	}
+	return nil
+}
+
+// DeleteMany deletes models of ids with one auth token,
+// pocketbase has not transactions, deleted items are not restored.
+func (collection *Collection) DeleteMany(ids []any) error {
+	ctx := context.Background()
+	token, err := collection.db.pb.getTokenContext(ctx)
+	if err != nil {
+		return NewErrorf("pb.Collection.DeleteMany.token: %v", err)
+	}
+	errs := make([]error, len(ids))
+	for i, idI := range ids {
+		id, ok := idI.(string)
+		if !ok {
+			errs[i] = NewErrorf("pb: id must be string")
+			continue
+		}
+		if SoftDeleteField(collection.model) != "" {
+			errs[i] = base.SoftDeleteContext(ctx, collection, id)
+			continue
+		}
+		errs[i] = collection.delete(ctx, token, id)
+	}
+	if batch := NewErrBatch(errs); batch.Failed() > 0 {
+		return batch
+	}
+	return nil
 }
 
 // Pocketbase does not support DeleteAll
@@ -90,12 +273,13 @@ func (collection *Collection) DeleteAll() error {
 	return nil
 }
 
+// Count returns totalItems of list of one record, soft deleted records too.
 func (collection *Collection) Count() uint {
-	records, err := collection.db.pb.Filter(collection.name, map[string]any{})
+	count, err := collection.db.pb.CountContext(context.Background(), collection.name, "")
 	if err != nil {
-		return 0
+		log.Printf("pb.Collection.Count: %v\n", err)
 	}
-	return uint(len(records))
+	return count
 }
 
 func (collection *Collection) Manager() ManagerI {
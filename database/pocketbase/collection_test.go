@@ -0,0 +1,173 @@
+package pocketbase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+type versionCar struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version uint64 `json:"version"`
+}
+
+func (car versionCar) Id() any { return car.ID }
+func (versionCar) Create(db DB, data string) Model {
+	car := &versionCar{}
+	json.Unmarshal([]byte(data), car)
+	return car
+}
+func (car *versionCar) Save(table Table) error { return table.Save(car) }
+func (car *versionCar) Delete(db DB) error     { return nil }
+
+// versionServer returns server of record "abc" of version_car checking VersionRule:
+// record of other stored version is not found for update by user,
+// admins update it anyway.
+func versionServer(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	version := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/auth-with-password"):
+			fmt.Fprint(w, `{"token":"admin"}`)
+		case r.Method == "GET":
+			fmt.Fprintf(w, `{"page":1,"perPage":500,"totalPages":1,"totalItems":1,"items":[{"id":"abc","version":%v}]}`, version)
+		case r.Method == "POST":
+			version = 1
+			fmt.Fprint(w, `{"id":"abc"}`)
+		case r.Method == "PATCH":
+			next, _ := strconv.Atoi(r.FormValue("version"))
+			if r.Header.Get("Authorization") != "admin" && next != version+1 {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"code":404,"message":"The requested resource wasn't found.","data":{}}`)
+				return
+			}
+			version = next
+			fmt.Fprint(w, `{"id":"abc"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSaveVersioned(t *testing.T) {
+	for _, isAdmin := range []bool{false, true} {
+		identity := ""
+		if isAdmin {
+			identity = "admin"
+		}
+		table, err := Open(versionServer(t).URL, identity, "", isAdmin).Table("version_car", &versionCar{})
+		if err != nil {
+			t.Fatalf("Table: %v", err)
+		}
+		car := &versionCar{Name: "a"}
+		if err := table.Save(car); err != nil || car.Version != 1 {
+			t.Fatalf("admin %v: Save of new model returns %v, version %v, expected 1", isAdmin, err, car.Version)
+		}
+		stale := &versionCar{ID: car.ID, Name: "stale", Version: car.Version}
+		car.Name = "b"
+		if err := table.Save(car); err != nil || car.Version != 2 {
+			t.Fatalf("admin %v: Save returns %v, version %v, expected 2", isAdmin, err, car.Version)
+		}
+
+		err = table.Save(stale)
+		if _, ok := err.(ErrConflict); !ok {
+			t.Errorf("admin %v: Save of stale model returns %T: %v, expected ErrConflict", isAdmin, err, err)
+		}
+		if stale.Version != 1 {
+			t.Errorf("admin %v: failed save changes version to %v", isAdmin, stale.Version)
+		}
+	}
+}
+
+type softCar struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (car softCar) Id() any                     { return car.ID }
+func (softCar) Create(db DB, data string) Model { return &softCar{} }
+func (car *softCar) Save(table Table) error     { return table.Save(car) }
+func (car *softCar) Delete(db DB) error         { return nil }
+
+// recordsServer returns server answering lists of records by body,
+// uris of requests are collected in requests.
+func recordsServer(t *testing.T, body string) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, requests...)
+	}
+}
+
+func TestCount(t *testing.T) {
+	server, requests := recordsServer(t, `{"page":1,"perPage":1,"totalPages":1200,"totalItems":1200,"items":[{"id":"a"}]}`)
+	table, err := Open(server.URL, "", "", false).Table("soft_car", &softCar{})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	if count := table.Count(); count != 1200 {
+		t.Errorf("Count is %v, expected totalItems 1200", count)
+	}
+	if got := requests(); len(got) != 1 || !strings.Contains(got[0], "perPage=1&") {
+		t.Errorf("Count requests %v, expected one page of one record", got)
+	}
+}
+
+func TestDeleteContextSoftDeleted(t *testing.T) {
+	server, requests := recordsServer(t, `{"page":1,"perPage":500,"totalPages":1,"totalItems":1,"items":[{"id":"a","name":"a"}]}`)
+	table, err := Open(server.URL, "", "", false).Table("soft_car", &softCar{})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := table.(ContextTable).DeleteContext(ctx, "a"); err != context.Canceled {
+		t.Errorf("DeleteContext of soft deleted model with cancelled context returns %v, expected context.Canceled", err)
+	}
+	if got := requests(); len(got) != 0 {
+		t.Errorf("DeleteContext with cancelled context requests %v", got)
+	}
+}
+
+func TestUnsupportedLookups(t *testing.T) {
+	server, requests := recordsServer(t, `{"page":1,"perPage":500,"totalPages":1,"totalItems":0,"items":[]}`)
+	table, err := Open(server.URL, "", "", false).Table("soft_car", &softCar{})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	for _, params := range []Params{{"Name__contains": "a"}, {"Name__regex": "^a"}} {
+		_, err := table.Manager().(ContextManager).FilterContext(context.Background(), params)
+		if _, ok := err.(ErrLookup); !ok {
+			t.Errorf("Filter(%v) returns %T: %v, expected ErrLookup", params, err, err)
+		}
+	}
+	if got := requests(); len(got) != 0 {
+		t.Errorf("Filter of unsupported lookups requests %v", got)
+	}
+}
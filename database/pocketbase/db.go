@@ -1,7 +1,8 @@
 package pocketbase
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"strings"
 	"sync"
 
@@ -42,6 +43,7 @@ func (m *collectionMap) Range(fn func(name string, collection *Collection) (cont
 type DataBase struct {
 	pb          *PocketBase
 	collections collectionMap // map[string]Table
+	relations   *base.Relations
 }
 
 func Open(address, identity, password string, isAdmin bool, updateCollections ...bool) *DataBase {
@@ -50,7 +52,8 @@ func Open(address, identity, password string, isAdmin bool, updateCollections ..
 
 func OpenWith(pb *PocketBase) *DataBase {
 	db := &DataBase{
-		pb: pb,
+		pb:        pb,
+		relations: &base.Relations{},
 	}
 	return db
 }
@@ -62,20 +65,34 @@ func (db *DataBase) Close() error {
 	return nil
 }
 
+// Tx is not supported by remote pocketbase, fn is not called.
+func (db *DataBase) Tx(fn func(tx DB) error) error {
+	return NewErrNotSupported("pb: transactions")
+}
+
 func (db *DataBase) TableFromCache(name string) Table {
 	return db.collections.Load(name)
 }
 
 func (db *DataBase) CreateCollection(name string, model Model) error {
+	if !db.pb.updateCollections {
+		return nil
+	}
 	data, err := CreateDataCollection(name, model)
 	if err != nil {
 		return err
 	}
+	if err := ResolveRelations(data["schema"].([]map[string]any), db.collectionId); err != nil {
+		return err
+	}
+	if VersionField(model) != "" {
+		data["updateRule"] = VersionRule
+	}
 
 	if db.ExistsTable(name) {
-		log.Println("UpdateSchema is not available")
-		return nil
-		return ToError(db.pb.UpdateCollection(data))
+		// removed and retyped fields are applied by Migrate only
+		_, err := db.migrate(name, model, false, true)
+		return err
 	}
 
 	return ToError(db.pb.CreateCollection(data))
@@ -96,6 +113,7 @@ func (db *DataBase) Table(name string, model Model) (Table, error) {
 		return nil, NewErrorf("pb: id must be string")
 	}
 
+	db.relations.Register(name, model)
 	collection := &Collection{
 		db:    db,
 		name:  name,
@@ -104,15 +122,27 @@ func (db *DataBase) Table(name string, model Model) (Table, error) {
 	manager := base.NewManager(collection)
 	manager.OnAll = ManagerAll
 	manager.OnFilter = ManagerFilter
+	manager.OnIterate = ManagerIterate
+	manager.Preloads = true
 	collection.Objects = manager
 	db.collections.Store(name, collection)
 	return collection, nil
 }
 
+// ExistsTable reports if collection `name` exists, one record is requested.
 func (db *DataBase) ExistsTable(name string) bool {
-	_, err := db.pb.Filter(name, map[string]any{})
+	_, err := db.pb.CountContext(context.Background(), name, "")
 	if err != nil && strings.Contains(err.Error(), "refused") {
 		panic(err)
 	}
 	return err == nil
 }
+
+// collectionId returns id of collection `name`.
+func (db *DataBase) collectionId(name string) (string, error) {
+	collection, err := db.pb.GetCollection(name)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(collection["id"]), nil
+}
@@ -0,0 +1,23 @@
+package pocketbase
+
+import (
+	"os"
+	"testing"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/dbtest"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// TestConformance runs checks of dbtest on pocketbase of PB_TEST_URL,
+// collections are created by admin PB_TEST_IDENTITY of password PB_TEST_PASSWORD.
+func TestConformance(t *testing.T) {
+	address := os.Getenv("PB_TEST_URL")
+	if address == "" {
+		t.Skip("PB_TEST_URL of pocketbase server is not set")
+	}
+	db := Open(address, os.Getenv("PB_TEST_IDENTITY"), os.Getenv("PB_TEST_PASSWORD"), true, true)
+	// rules of collections do not apply to admins, versions are read before update
+	dbtest.Run(t, dbtest.Config{StringIds: true, RacyVersions: true, Open: func(t *testing.T) DB {
+		return db
+	}})
+}
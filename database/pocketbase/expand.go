@@ -0,0 +1,63 @@
+package pocketbase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+var _ Expander = &Collection{}
+
+// expandPage is count of models expanded by one request
+const expandPage = 50
+
+// Expand loads relations of models by expand of pocketbase, it is used for models
+// not loaded by list of manager: one request loads relations of expandPage models.
+func (collection *Collection) Expand(ctx context.Context, models []Model, relations []string) error {
+	paths, err := base.ExpandPaths(collection.db, collection.model, relations)
+	if err != nil {
+		return err
+	}
+	byId := map[string]Model{}
+	ids := []any{}
+	for _, model := range models {
+		id := fmt.Sprint(model.Id())
+		if _, ok := byId[id]; !ok {
+			ids = append(ids, id)
+		}
+		byId[id] = model
+	}
+
+	for start := 0; start < len(ids); start += expandPage {
+		end := start + expandPage
+		if end > len(ids) {
+			end = len(ids)
+		}
+		filter, err := PBFilter(collection.model, And(Params{"ID__in": ids[start:end]}))
+		if err != nil {
+			return err
+		}
+		records, err := collection.db.pb.ListContext(ctx, collection.name, ListOptions{
+			Filter: filter,
+			Expand: strings.Join(paths, ","),
+		})
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			model, ok := byId[fmt.Sprint(record.Get("id"))]
+			if !ok {
+				continue
+			}
+			expand, _ := record.Get("expand").(map[string]any)
+			if err := base.SetExpanded(collection.db, model, expand, relations); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,41 +1,110 @@
 package pocketbase
 
 import (
+	"context"
 	"encoding/json"
+	"strings"
 
 	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
 	. "github.com/PoulIgorson/sub_engine_fiber/define"
 )
 
-func ManagerFilter(manager ManagerI, include Params, _ ...Params) []Model {
+func ManagerFilter(ctx context.Context, manager ManagerI, q Q) ([]Model, error) {
+	filter, err := PBFilter(manager.Table().Model(), q)
+	if err != nil {
+		return nil, err
+	}
+	return list(ctx, manager, filter)
+}
+
+func ManagerAll(ctx context.Context, manager ManagerI) ([]Model, error) {
+	if manager.IsInstance() {
+		return manager.(*base.Manager).Cached(ctx)
+	}
+	// manager has no conditions but scope of soft deleted models
+	filter, err := PBFilter(manager.Table().Model(), manager.(*base.Manager).Query().Q())
+	if err != nil {
+		return nil, err
+	}
+	return list(ctx, manager, filter)
+}
+
+// list returns models of filter, ordered and paged by query of manager.
+// Paging is made by pocketbase when offset is multiple of limit,
+// relations of With are loaded by expand of the same request.
+func list(ctx context.Context, manager ManagerI, filter string) ([]Model, error) {
+	query := manager.(*base.Manager).Query()
+	opts := ListOptions{Filter: filter, Sort: PBSort(manager.Table().Model(), query.Order)}
+	if len(query.With) > 0 {
+		paths, err := base.ExpandPaths(manager.Table().DB(), manager.Table().Model(), query.With)
+		if err != nil {
+			return nil, err
+		}
+		opts.Expand = strings.Join(paths, ",")
+	}
+	paged := query.Limit > 0 && query.Limit <= 500 && query.Offset%query.Limit == 0
+	if paged {
+		opts.Page = query.Offset/query.Limit + 1
+		opts.PerPage = query.Limit
+	}
+	records, err := manager.Table().DB().(*DataBase).pb.ListContext(ctx, manager.Table().Name(), opts)
+	if err != nil {
+		return nil, err
+	}
 	objects := []Model{}
-	records, _ := manager.Table().DB().(*DataBase).pb.Filter(manager.Table().Name(), nameFieldsToJSONTags(manager.Table().Model(), include))
 	for _, record := range records {
 		model := recordToModel(record, manager.Table().DB(), manager.Table().Model())
+		if len(query.With) > 0 {
+			expand, _ := record.Get("expand").(map[string]any)
+			if err := base.SetExpanded(manager.Table().DB(), model, expand, query.With); err != nil {
+				return nil, err
+			}
+		}
 		objects = append(objects, model)
 	}
-	return objects
+	if !paged {
+		objects = query.Page(objects)
+	}
+	return objects, nil
 }
 
-func ManagerAll(manager ManagerI) []Model {
-	objects := []Model{}
-	if manager.IsInstance() {
-		oldAll := manager.(*base.Manager).OnAll
-		manager.(*base.Manager).OnAll = nil
-		objects = manager.All()
-		manager.(*base.Manager).OnAll = oldAll
-	} else {
-		records, _ := manager.Table().DB().(*DataBase).pb.Filter(manager.Table().Name(), map[string]any{})
+// iteratePage is size of page loaded by ManagerIterate
+const iteratePage = 200
+
+// ManagerIterate loads pages of records lazily, next page is requested
+// after fn takes all models of previous one.
+func ManagerIterate(ctx context.Context, manager ManagerI, q Q, fn func(model Model) bool) error {
+	filter, err := PBFilter(manager.Table().Model(), q)
+	if err != nil {
+		return err
+	}
+	query := manager.(*base.Manager).Query()
+	opts := ListOptions{
+		Filter:  filter,
+		Sort:    PBSort(manager.Table().Model(), query.Order),
+		PerPage: iteratePage,
+	}
+	pb := manager.Table().DB().(*DataBase).pb
+	for opts.Page = 1; ; opts.Page++ {
+		records, err := pb.ListContext(ctx, manager.Table().Name(), opts)
+		if err != nil {
+			return err
+		}
 		for _, record := range records {
-			model := recordToModel(record, manager.Table().DB(), manager.Table().Model())
-			objects = append(objects, model)
+			if !fn(recordToModel(record, manager.Table().DB(), manager.Table().Model())) {
+				return nil
+			}
+		}
+		if len(records) < iteratePage {
+			return nil
 		}
 	}
-	return objects
 }
 
 func recordToModel(record *Record, db DB, model Model) Model {
+	MapAutoFields(model, record.data)
 	dataByte, _ := json.Marshal(record.data)
 	return model.Create(db, string(dataByte))
 }
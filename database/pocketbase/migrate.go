@@ -0,0 +1,111 @@
+package pocketbase
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// Migrate brings schema of existing collection `name` to schema of model
+// and appends applied migration to log of migrations.
+// With dryRun planned changes are printed only.
+func (db *DataBase) Migrate(name string, model Model, dryRun bool) (Migration, error) {
+	return db.migrate(name, model, dryRun, false)
+}
+
+// migrate applies migration of collection `name` to schema of model,
+// additive migration keeps removed and retyped fields and indexes of collection.
+// Collections are not changed by pb opened without updateCollections, ErrNotSupported is returned.
+func (db *DataBase) migrate(name string, model Model, dryRun, additive bool) (Migration, error) {
+	if !db.pb.updateCollections && !dryRun {
+		return Migration{}, NewErrNotSupported("pb: migrations without updateCollections")
+	}
+	collection, err := db.pb.GetCollection(name)
+	if err != nil {
+		return Migration{}, ToError(err)
+	}
+	current := []map[string]any{}
+	if fields, ok := collection["schema"].([]any); ok {
+		for _, field := range fields {
+			if field, ok := field.(map[string]any); ok {
+				current = append(current, field)
+			}
+		}
+	}
+	indexes := []string{}
+	if values, ok := collection["indexes"].([]any); ok {
+		for _, index := range values {
+			indexes = append(indexes, fmt.Sprint(index))
+		}
+	}
+
+	migration, err := PlanMigration(name, model, current, indexes)
+	if err != nil {
+		return migration, err
+	}
+	if additive {
+		var skipped []SchemaChange
+		migration, skipped = migration.Additive(current, indexes)
+		for _, change := range skipped {
+			log.Printf("pb.migrate: %v: `%v` is skipped, apply it by Migrate\n", name, change)
+		}
+	}
+	if err := ResolveRelations(migration.Schema, db.collectionId); err != nil {
+		return migration, err
+	}
+	if migration.Version, err = db.migrationVersion(name); err != nil {
+		return migration, err
+	}
+	if dryRun {
+		log.Println(migration)
+		return migration, nil
+	}
+	if len(migration.Changes) == 0 {
+		return migration, nil
+	}
+
+	data := map[string]any{
+		"name":    name,
+		"schema":  migration.Schema,
+		"indexes": migration.Indexes,
+	}
+	if VersionField(model) != "" {
+		data["updateRule"] = VersionRule
+	}
+	err = db.pb.UpdateCollection(data)
+	if err != nil {
+		return migration, ToError(err)
+	}
+	if !db.ExistsTable(MigrationsCollection) {
+		if err := db.pb.CreateCollection(CreateDataMigrations()); err != nil {
+			return migration, ToError(err)
+		}
+	}
+	form := NewForm(db.pb, NewRecord(MigrationsCollection, db.pb))
+	form.LoadData(migration.Record())
+	if _, err := form.Submit(); err != nil {
+		return migration, ToError(err)
+	}
+	return migration, nil
+}
+
+// migrationVersion returns version of next migration of collection `name`,
+// migrations are counted by pb without loading them.
+func (db *DataBase) migrationVersion(name string) (uint, error) {
+	if !db.ExistsTable(MigrationsCollection) {
+		return 1, nil
+	}
+	filter, err := PBFilter(nil, And(Params{"collection": name}))
+	if err != nil {
+		return 0, err
+	}
+	count, err := db.pb.CountContext(context.Background(), MigrationsCollection, filter)
+	if err != nil {
+		return 0, ToError(err)
+	}
+	return count + 1, nil
+}
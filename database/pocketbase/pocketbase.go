@@ -6,15 +6,19 @@ package pocketbase
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
-	"strings"
+	"sort"
 
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
 	. "github.com/PoulIgorson/sub_engine_fiber/define"
 )
 
@@ -117,12 +121,21 @@ func (form *Form) AddFiles(field string, path ...string) {
 
 // Form.Submit записывает изменения в pb
 func (form *Form) Submit() (string, error) {
-	token, err := form.app.getToken()
+	return form.SubmitContext(context.Background())
+}
+
+// Form.SubmitContext записывает изменения в pb, запрос отменяется вместе с ctx
+func (form *Form) SubmitContext(ctx context.Context) (string, error) {
+	token, err := form.app.getTokenContext(ctx)
 	if err != nil {
 		log.Printf("pocketbase.Submit.token.error: %v\n", err)
 		return "", fmt.Errorf("pb.Form.Submit.token: %v", err.Error())
 	}
+	return form.submit(ctx, token)
+}
 
+// Form.submit записывает изменения в pb с полученным токеном `token`
+func (form *Form) submit(ctx context.Context, token string) (string, error) {
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 
@@ -152,9 +165,9 @@ func (form *Form) Submit() (string, error) {
 	var req *http.Request
 	curl := fmt.Sprintf("%v/api/collections/%v/records", form.app.address, form.record.collectionNameOrId)
 	if id, ok := form.data["id"]; !ok || id == "" || id == nil {
-		req, _ = http.NewRequest("POST", curl, bytes.NewReader(body.Bytes()))
+		req, _ = http.NewRequestWithContext(ctx, "POST", curl, bytes.NewReader(body.Bytes()))
 	} else {
-		req, _ = http.NewRequest("PATCH", curl+"/"+fmt.Sprint(form.data["id"]), bytes.NewReader(body.Bytes()))
+		req, _ = http.NewRequestWithContext(ctx, "PATCH", curl+"/"+fmt.Sprint(form.data["id"]), bytes.NewReader(body.Bytes()))
 	}
 	req.Header.Set("Content-Type", "multipart/form-data; boundary="+writer.Boundary())
 	req.Header.Set("Authorization", token)
@@ -163,23 +176,52 @@ func (form *Form) Submit() (string, error) {
 	response, err := client.Do(req)
 	if err != nil {
 		log.Println("pocketbase.Submit.getResponse.error:", err)
-		log.Println("pocketbase.Submit.getResponse.body:", response.Body)
 		return "", err
 	}
+	defer response.Body.Close()
 	responseBody, _ := io.ReadAll(response.Body)
 	var resp struct {
 		Id string `json:"id"`
 	}
 	json.Unmarshal(responseBody, &resp)
 	if response.StatusCode != 200 && response.StatusCode != 204 {
-		log.Println("pocketbase.Submit.status-resp:", response.StatusCode, resp)
-		return "", fmt.Errorf("%v, %v", response.StatusCode, resp)
+		respErr := ResponseError{Status: response.StatusCode}
+		json.Unmarshal(responseBody, &respErr)
+		log.Println("pocketbase.Submit.status-resp:", response.StatusCode, respErr)
+		return "", respErr
 	}
 	return resp.Id, nil
 }
 
+// ResponseError ошибка ответа pb, Data содержит ошибки полей записи
+type ResponseError struct {
+	Status  int                       `json:"code"`
+	Message string                    `json:"message"`
+	Data    map[string]map[string]any `json:"data"`
+}
+
+func (err ResponseError) Error() string {
+	return fmt.Sprintf("%v, %v %v", err.Status, err.Message, err.Data)
+}
+
+// ResponseError.NotUnique возвращает поля с ошибкой уникальности
+func (err ResponseError) NotUnique() []string {
+	fields := []string{}
+	for field, fieldErr := range err.Data {
+		if fieldErr["code"] == "validation_not_unique" {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
 // PocketBase.getToken возвращает токен для работы с защищенным api
 func (pb *PocketBase) getToken() (string, error) {
+	return pb.getTokenContext(context.Background())
+}
+
+func (pb *PocketBase) getTokenContext(ctx context.Context) (string, error) {
 	if pb.identity == "" {
 		return "", nil
 	}
@@ -194,8 +236,8 @@ func (pb *PocketBase) getToken() (string, error) {
 	if pb.isAdmin {
 		collection = "admins"
 	}
-	status, resp, err := GetJSONResponse(
-		"POST", fmt.Sprintf("%v/api/%v/auth-with-password", pb.address, collection),
+	status, resp, err := GetJSONResponseContext(
+		ctx, "POST", fmt.Sprintf("%v/api/%v/auth-with-password", pb.address, collection),
 		Headers(headers), Data(data),
 	)
 	if err != nil {
@@ -207,9 +249,81 @@ func (pb *PocketBase) getToken() (string, error) {
 	return resp.(map[string]any)["token"].(string), nil
 }
 
+// ListOptions параметры запроса списка записей
+type ListOptions struct {
+	Filter  string // фильтр pb, пустой - все записи
+	Sort    string // поля через запятую, `-` перед полем - по убыванию
+	Page    uint   // номер страницы, 0 - все страницы
+	PerPage uint   // размер страницы, 0 - 500
+	Expand  string // поля relation через запятую, загружаются в `expand` записи
+}
+
 // PocketBase.Filter возвращает список записей из pb удовлетворяющим фильтру `data`
 func (pb *PocketBase) Filter(collectionNameOrId string, data map[string]any, page ...uint) ([]*Record, error) {
-	token, err := pb.getToken()
+	return pb.FilterContext(context.Background(), collectionNameOrId, data, page...)
+}
+
+// PocketBase.FilterContext как Filter, запросы отменяются вместе с ctx
+func (pb *PocketBase) FilterContext(ctx context.Context, collectionNameOrId string, data map[string]any, page ...uint) ([]*Record, error) {
+	start := uint(1)
+	if len(page) > 0 {
+		start = page[0]
+	}
+	filter, err := PBFilter(nil, And(Params(data)))
+	if err != nil {
+		return nil, fmt.Errorf("pb.Filter: %v", err)
+	}
+	return pb.list(ctx, collectionNameOrId, ListOptions{Filter: filter}, start, true)
+}
+
+// PocketBase.ListContext возвращает записи удовлетворяющие фильтру,
+// в порядке и на странице из `opts`
+func (pb *PocketBase) ListContext(ctx context.Context, collectionNameOrId string, opts ListOptions) ([]*Record, error) {
+	if opts.Page == 0 {
+		return pb.list(ctx, collectionNameOrId, opts, 1, true)
+	}
+	return pb.list(ctx, collectionNameOrId, opts, opts.Page, false)
+}
+
+// PocketBase.list возвращает записи начиная со страницы `page`,
+// при `all` загружает все следующие страницы
+func (pb *PocketBase) list(ctx context.Context, collectionNameOrId string, opts ListOptions, page uint, all bool) ([]*Record, error) {
+	records := []*Record{}
+	for {
+		resp, err := pb.listPage(ctx, collectionNameOrId, opts, page)
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			return records, nil
+		}
+		for _, item := range resp["items"].([]any) {
+			records = append(records, &Record{collectionNameOrId, pb, item.(map[string]any)})
+		}
+		if !all || int(resp["page"].(float64)) >= int(resp["totalPages"].(float64)) {
+			return records, nil
+		}
+		page = uint(int(resp["page"].(float64))) + 1
+	}
+}
+
+// PocketBase.CountContext возвращает количество записей удовлетворяющих фильтру `filter`,
+// загружается одна запись
+func (pb *PocketBase) CountContext(ctx context.Context, collectionNameOrId, filter string) (uint, error) {
+	resp, err := pb.listPage(ctx, collectionNameOrId, ListOptions{Filter: filter, PerPage: 1}, 1)
+	if err != nil || resp == nil {
+		return 0, err
+	}
+	total, ok := resp["totalItems"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("pb.Count: response has no totalItems")
+	}
+	return uint(total), nil
+}
+
+// PocketBase.listPage возвращает ответ pb со страницей `page` записей, nil для статуса 204
+func (pb *PocketBase) listPage(ctx context.Context, collectionNameOrId string, opts ListOptions, page uint) (map[string]any, error) {
+	token, err := pb.getTokenContext(ctx)
 	if err != nil {
 		log.Println("pocketbase.Filter.token.error:", err)
 		return nil, fmt.Errorf("pb.Filter.token: %v", err.Error())
@@ -219,26 +333,20 @@ func (pb *PocketBase) Filter(collectionNameOrId string, data map[string]any, pag
 		"Accept-Encoding": "identity",
 		"Authorization":   token,
 	}
-	filter := ""
-	for key, value := range data {
-		if strings.IndexByte("=<>~", key[len(key)-1]) == -1 {
-			key += "="
-		}
-		if len(filter) != 0 {
-			filter += "&&"
-		}
-		if str, ok := value.(string); ok {
-			value = `"` + str + `"`
-		}
-		filter += key + fmt.Sprint(value)
+	perPage := opts.PerPage
+	if perPage == 0 {
+		perPage = 500
 	}
-	filter = strings.ReplaceAll(filter, "&", "%26")
-	curl := fmt.Sprintf(`%v/api/collections/%v/records?perPage=500&filter=%v`, pb.address, collectionNameOrId, filter)
-	if len(page) > 0 {
-		curl += "&page=" + fmt.Sprint(page[0])
+
+	curl := fmt.Sprintf(`%v/api/collections/%v/records?perPage=%v&page=%v&filter=%v`, pb.address, collectionNameOrId, perPage, page, url.QueryEscape(opts.Filter))
+	if opts.Sort != "" {
+		curl += "&sort=" + url.QueryEscape(opts.Sort)
+	}
+	if opts.Expand != "" {
+		curl += "&expand=" + url.QueryEscape(opts.Expand)
 	}
-	status, respI, err := GetJSONResponse(
-		"GET", curl,
+	status, respI, err := GetJSONResponseContext(
+		ctx, "GET", curl,
 		Headers(headers), nil,
 	)
 	if err != nil {
@@ -246,45 +354,49 @@ func (pb *PocketBase) Filter(collectionNameOrId string, data map[string]any, pag
 		return nil, err
 	}
 	if status == 204 {
-		return []*Record{}, nil
+		return nil, nil
 	}
 	if status != 200 {
 		log.Println("pocketbase.Filter.getResponse:", status, respI)
 		return nil, fmt.Errorf("%v, %v", status, respI)
 	}
-
-	resp := respI.(map[string]any)
-	records := []*Record{}
-	for _, item := range resp["items"].([]any) {
-		records = append(records, &Record{collectionNameOrId, pb, item.(map[string]any)})
-	}
-	if int(resp["page"].(float64)) < int(resp["totalPages"].(float64)) {
-		nextRecords, _ := pb.Filter(collectionNameOrId, data, uint(int(resp["page"].(float64))+1))
-		records = append(records, nextRecords...)
-	}
-	return records, nil
+	return respI.(map[string]any), nil
 }
 
 func (pb *PocketBase) Delete(collectionNameOrId, id string) error {
-	token, err := pb.getToken()
+	return pb.DeleteContext(context.Background(), collectionNameOrId, id)
+}
+
+// PocketBase.DeleteContext удаляет запись `id`, запрос отменяется вместе с ctx
+func (pb *PocketBase) DeleteContext(ctx context.Context, collectionNameOrId, id string) error {
+	token, err := pb.getTokenContext(ctx)
 	if err != nil {
 		log.Println("pocketbase.Filter.token.error:", err)
 		return fmt.Errorf("pb.Delete.token: %v", err.Error())
 	}
+	return pb.delete(ctx, token, collectionNameOrId, id)
+}
+
+// PocketBase.delete удаляет запись `id` с полученным токеном `token`
+func (pb *PocketBase) delete(ctx context.Context, token, collectionNameOrId, id string) error {
 	headers := Headers{
 		"Content-Type":    "application/x-www-form-urlencoded",
 		"Accept-Encoding": "identity",
 		"Authorization":   token,
 	}
 	curl := fmt.Sprintf(`%v/api/collections/%v/records/%v`, pb.address, collectionNameOrId, id)
-	status, body, err := GetResponse(
-		"DELETE", curl,
+	status, body, err := GetResponseContext(
+		ctx, "DELETE", curl,
 		headers, nil,
 	)
 	if err != nil {
 		log.Println("pocketbase.Filter.getResponse.error:", err)
 		return err
 	}
+	if status == 404 {
+		// запись уже удалена
+		return nil
+	}
 	if status != 200 && status != 204 {
 		log.Println("pocketbase.Filter.getResponse:", status, string(body))
 		return fmt.Errorf("%v, %v", status, string(body))
@@ -357,7 +469,34 @@ func (pb *PocketBase) UpdateCollection(data map[string]any) error {
 	if !pb.updateCollections {
 		return nil
 	}
-	log.Println("UpdateSchema is not avaiable")
-	return nil
 	return pb.doCollection("PATCH", fmt.Sprintf(`%v/api/collections/%v`, pb.address, data["name"]), data)
 }
+
+// GetCollection возвращает данные коллекции `collectionNameOrId`, в том числе schema и indexes
+func (pb *PocketBase) GetCollection(collectionNameOrId string) (map[string]any, error) {
+	token, err := pb.getToken()
+	if err != nil {
+		log.Println("pocketbase.GetCollection.token:", err)
+		return nil, fmt.Errorf("pocketbase.GetCollection.token: %v", err)
+	}
+	headers := Headers{
+		"Accept-Encoding": "identity",
+		"Authorization":   token,
+	}
+
+	status, body, err := GetResponse(
+		"GET", fmt.Sprintf(`%v/api/collections/%v`, pb.address, collectionNameOrId),
+		headers, nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("getResponse.error: %v", err)
+	}
+	if status != 200 {
+		return nil, fmt.Errorf("getResponse: %v, %v", status, string(body))
+	}
+	data := map[string]any{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("pocketbase.GetCollection.unmarshal: %v", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,180 @@
+package pocketbaselocal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/resolvers"
+	"github.com/pocketbase/pocketbase/tools/search"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// ManagerAggregate aggregates records by sql query of dao.
+func ManagerAggregate(ctx context.Context, manager ManagerI, groupBy []string, aggregations []Aggregation) ([]Group, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	model := manager.Table().Model()
+	dao := manager.Table().DB().(*DataBase).Dao()
+	collection, err := dao.FindCollectionByNameOrId(manager.Table().Name())
+	if err != nil {
+		return nil, err
+	}
+
+	query := dao.RecordQuery(collection).WithContext(ctx)
+	resolver := resolvers.NewRecordFieldResolver(dao, collection, nil, true)
+
+	filter, err := PBFilter(model, manager.(*base.Manager).Query().Q())
+	if err != nil {
+		return nil, err
+	}
+	if filter != "" {
+		expr, err := search.FilterData(filter).BuildExpr(resolver)
+		if err != nil {
+			return nil, err
+		}
+		query.AndWhere(expr)
+	}
+
+	columns := []string{}
+	groupColumns := []string{}
+	for i, field := range groupBy {
+		column, err := resolveColumn(resolver, model, field)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, fmt.Sprintf("%v AS [[g%v]]", column, i))
+		groupColumns = append(groupColumns, column)
+	}
+	for i, aggregation := range aggregations {
+		column := "*"
+		if aggregation.Field != "" {
+			if column, err = resolveColumn(resolver, model, aggregation.Field); err != nil {
+				return nil, err
+			}
+		}
+		switch aggregation.Func {
+		case AggSum:
+			column = "TOTAL(" + column + ")"
+		case AggAvg:
+			column = "AVG(" + column + ")"
+		case AggCount:
+			if aggregation.Field != "" {
+				column = "NULLIF(" + column + ", '')"
+			}
+			column = "COUNT(" + column + ")"
+		default:
+			column = strings.ToUpper(aggregation.Func) + "(NULLIF(" + column + ", ''))"
+		}
+		columns = append(columns, fmt.Sprintf("%v AS [[a%v]]", column, i))
+	}
+	if err := resolver.UpdateQuery(query); err != nil {
+		return nil, err
+	}
+	query.Select(columns...)
+	if len(groupColumns) > 0 {
+		query.GroupBy(groupColumns...)
+	}
+
+	rows := []dbx.NullStringMap{}
+	if err := query.All(&rows); err != nil {
+		return nil, err
+	}
+
+	groups := make([]Group, 0, len(rows))
+	for _, row := range rows {
+		group := Group{Values: Params{}, Result: Params{}}
+		for i, field := range groupBy {
+			group.Values[field] = fromSQL(model, field, row[fmt.Sprintf("g%v", i)])
+		}
+		for i, aggregation := range aggregations {
+			value := row[fmt.Sprintf("a%v", i)]
+			switch aggregation.Func {
+			case AggSum, AggAvg:
+				if !value.Valid {
+					group.Result[aggregation.Key()] = nil
+					continue
+				}
+				number, err := strconv.ParseFloat(value.String, 64)
+				if err != nil {
+					return nil, NewErrorf("aggregation `%v`: %v", aggregation.Key(), err)
+				}
+				group.Result[aggregation.Key()] = number
+			case AggCount:
+				count, _ := strconv.ParseUint(value.String, 10, 64)
+				group.Result[aggregation.Key()] = uint(count)
+			default:
+				group.Result[aggregation.Key()] = fromSQL(model, aggregation.Field, value)
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// resolveColumn returns sql column of field of model.
+func resolveColumn(resolver *resolvers.RecordFieldResolver, model Model, field string) (string, error) {
+	name, _, _ := strings.Cut(GetTagField(model, field, "json"), ",")
+	if name == "" || name == "-" {
+		name = field
+	}
+	result, err := resolver.Resolve(name)
+	if err != nil {
+		return "", err
+	}
+	if result.MultiMatchSubQuery != nil || len(result.Params) > 0 {
+		return "", NewErrNotSupported(fmt.Sprintf("aggregation of field `%v`", field))
+	}
+	return result.Identifier, nil
+}
+
+// fromSQL converts value of sql to type of field of model.
+func fromSQL(model Model, field string, value sql.NullString) any {
+	if !value.Valid {
+		return nil
+	}
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil {
+		return value.String
+	}
+	structField, ok := vModel.Type().FieldByName(field)
+	if !ok {
+		return value.String
+	}
+	fieldT := structField.Type
+
+	switch fieldT.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		number, err := strconv.ParseFloat(value.String, 64)
+		if err != nil {
+			return value.String
+		}
+		return reflect.ValueOf(number).Convert(fieldT).Interface()
+	case reflect.Bool:
+		return reflect.ValueOf(value.String == "1" || value.String == "true").Convert(fieldT).Interface()
+	case reflect.String:
+		return reflect.ValueOf(value.String).Convert(fieldT).Interface()
+	}
+	if reflect.TypeOf(time.Time{}).ConvertibleTo(fieldT) {
+		datetime, err := types.ParseDateTime(value.String)
+		if err != nil {
+			return value.String
+		}
+		return reflect.ValueOf(datetime.Time()).Convert(fieldT).Interface()
+	}
+	return value.String
+}
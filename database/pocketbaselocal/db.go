@@ -1,14 +1,12 @@
 package pocketbaselocal
 
 import (
-	"encoding/json"
 	"log"
 	"os"
 	"sync"
 
 	pocketbase "github.com/pocketbase/pocketbase"
-	"github.com/pocketbase/pocketbase/models"
-	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/daos"
 
 	"github.com/PoulIgorson/sub_engine_fiber/database/base"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
@@ -47,10 +45,48 @@ func (m *collectionMap) Range(fn func(name string, collection *Collection) (cont
 type DataBase struct {
 	app         *pocketbase.PocketBase
 	collections collectionMap // map[string]Table
+	relations   *base.Relations
+
+	// set for view returned by Tx
+	dao         *daos.Dao
+	parent      *DataBase
+	afterCommit *base.Deferred
+}
+
+// Dao returns dao of transaction or dao of app.
+func (db *DataBase) Dao() *daos.Dao {
+	if db.dao != nil {
+		return db.dao
+	}
+	return db.app.Dao()
+}
+
+// Tx runs fn in dao transaction, tables of tx share it.
+// Managers of tables are updated after commit only.
+func (db *DataBase) Tx(fn func(tx DB) error) error {
+	if db.dao != nil {
+		return fn(db)
+	}
+	txDB := &DataBase{
+		app:         db.app,
+		relations:   db.relations,
+		parent:      db,
+		afterCommit: &base.Deferred{},
+	}
+	err := db.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		txDB.dao = txDao
+		return fn(txDB)
+	})
+	txDB.dao = nil
+	if err != nil {
+		return err
+	}
+	txDB.afterCommit.Run()
+	return nil
 }
 
 func New(appp ...*pocketbase.PocketBase) *DataBase {
-	db := &DataBase{}
+	db := &DataBase{relations: &base.Relations{}}
 	if len(appp) > 0 && appp[0] != nil {
 		db.app = appp[0]
 		return db
@@ -78,6 +114,9 @@ func New(appp ...*pocketbase.PocketBase) *DataBase {
 }
 
 func (db *DataBase) Close() error {
+	if db.parent != nil {
+		return NewErrorf("pocketbaselocal: transaction can not be closed")
+	}
 	db.collections.Range(func(_ string, collection *Collection) (continue_ bool) {
 		collection.Objects.Clear()
 		return true
@@ -86,42 +125,24 @@ func (db *DataBase) Close() error {
 	return db.app.DB().Close()
 }
 
+// UpdateCollection creates collection of model or migrates existing collection to schema of model,
+// removed and retyped fields are applied by Migrate only.
 func (db *DataBase) UpdateCollection(model Model) error {
-	log.Println("UpdateSchema is not available")
-	return nil
 	name := GetNameModel(model)
-	data, err := CreateDataCollection(name, model)
-	if err != nil {
+	if db.ExistsTable(name) {
+		_, err := db.migrate(name, model, false, true)
 		return err
 	}
 
-	typ := data["type"].(string)
-	dataB, err := json.Marshal(data["schema"])
+	data, err := CreateDataCollection(name, model)
 	if err != nil {
-		return NewErrorf("pocketbaselocal.updateCollection.marshalData: %v", err)
+		return err
 	}
-	schema := &schema.Schema{}
-	err = schema.UnmarshalJSON(dataB)
+	collection, err := newCollection(db.Dao(), data)
 	if err != nil {
-		return NewErrorf("pocketbaselocal.updateCollection.unmarshalJSON: %v", err)
-	}
-
-	var collection *models.Collection
-
-	if collectionPB, err := db.app.Dao().FindCollectionByNameOrId(name); err == nil {
-		collection = collectionPB
-		collection.MarkAsNotNew()
-		log.Println("UpdateSchema is not available")
-		return nil
-	} else {
-		collection = &models.Collection{
-			Name: name,
-			Type: typ,
-		}
-		collection.MarkAsNew()
+		return err
 	}
-	collection.Schema = *schema
-	return db.app.Dao().SaveCollection(collection)
+	return db.Dao().SaveCollection(collection)
 }
 
 func (db *DataBase) Table(_ string, model Model) (Table, error) {
@@ -134,28 +155,69 @@ func (db *DataBase) Table(_ string, model Model) (Table, error) {
 		return table, nil
 	}
 
-	/*if err := db.UpdateCollection(model); err != nil {
+	if db.parent != nil {
+		return db.txTable(name, model)
+	}
+
+	if err := db.UpdateCollection(model); err != nil {
 		return nil, NewErrorf("pocketbaselocal: %v", err)
-	}*/
+	}
+	db.relations.Register(name, model)
 
 	collection := &Collection{
 		db:    db,
 		name:  name,
 		model: model,
 	}
-	manager := base.NewManager(collection)
-	manager.OnAll = ManagerAll
-	manager.OnFilter = ManagerFilter
-	collection.Objects = manager
+	collection.Objects = newManager(collection)
+	db.collections.Store(name, collection)
+	return collection, nil
+}
+
+// txTable returns collection bound to transaction of db, its manager reads
+// the transaction and manager of collection of parent db is updated after commit.
+// Collection of model missing in parent is created or migrated by dao of transaction.
+func (db *DataBase) txTable(name string, model Model) (Table, error) {
+	parent := db.parent.collections.Load(name)
+	if parent == nil {
+		if err := db.UpdateCollection(model); err != nil {
+			return nil, NewErrorf("pocketbaselocal: %v", err)
+		}
+		db.relations.Register(name, model)
+		parent = &Collection{
+			db:    db.parent,
+			name:  name,
+			model: model,
+		}
+		parent.Objects = newManager(parent)
+		db.afterCommit.Do(func() { db.parent.collections.Store(name, parent) })
+	}
+	collection := &Collection{
+		db:     db,
+		name:   name,
+		model:  model,
+		parent: parent,
+	}
+	collection.Objects = newManager(collection)
 	db.collections.Store(name, collection)
 	return collection, nil
 }
 
 func (db *DataBase) ExistsTable(name string) bool {
-	_, err := db.app.Dao().FindCollectionByNameOrId(name)
+	_, err := db.Dao().FindCollectionByNameOrId(name)
 	return err == nil
 }
 
 func (db *DataBase) TableFromCache(name string) Table {
-	return db.collections.Load(name)
+	collection := db.collections.Load(name)
+	if collection == nil && db.parent != nil {
+		if parent := db.parent.collections.Load(name); parent != nil {
+			table, _ := db.Table(name, parent.model)
+			return table
+		}
+	}
+	if collection == nil {
+		return nil
+	}
+	return collection
 }
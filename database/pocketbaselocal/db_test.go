@@ -0,0 +1,280 @@
+package pocketbaselocal
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/migrate"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/dbtest"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// dataDir is dir of data dirs of apps of tests. Pocketbase removes files
+// of deleted records in background, so dirs are removed after all tests.
+var dataDir string
+
+func TestMain(m *testing.M) {
+	var err error
+	if dataDir, err = os.MkdirTemp("", "pocketbaselocal"); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dataDir)
+	os.Exit(code)
+}
+
+// openDB returns db of migrated pocketbase app in temporary dir of t.
+// Pocketbase decodes schema of collections by json aliases recursing under encoding/json/v2,
+// so tests are skipped on toolchains enabling it, run them with GOEXPERIMENT=nojsonv2.
+func openDB(t *testing.T) *DataBase {
+	t.Helper()
+	if jsonV2 {
+		t.Skip("pocketbase overflows stack under encoding/json/v2, run tests with GOEXPERIMENT=nojsonv2")
+	}
+	dir, err := os.MkdirTemp(dataDir, "")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	app := pocketbase.NewWithConfig(pocketbase.Config{DefaultDataDir: dir})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	runner, err := migrate.NewRunner(app.DB(), migrations.AppMigrations)
+	if err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	t.Cleanup(func() { app.ResetBootstrapState() })
+	return New(app)
+}
+
+func TestConformance(t *testing.T) {
+	dbtest.Run(t, dbtest.Config{StringIds: true, Open: func(t *testing.T) DB {
+		return openDB(t)
+	}})
+}
+
+type MigrateCar struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Year  uint   `json:"year"`
+	Color string `json:"color"`
+}
+
+func (car MigrateCar) Id() any                     { return car.ID }
+func (MigrateCar) Create(db DB, data string) Model { return &MigrateCar{} }
+func (car *MigrateCar) Save(table Table) error     { return table.Save(car) }
+func (car *MigrateCar) Delete(db DB) error         { return nil }
+
+// MigrateCarV2 is MigrateCar with added kind, retyped year and removed color.
+type MigrateCarV2 struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Year string `json:"year"`
+	Kind string `json:"kind"`
+}
+
+func (car MigrateCarV2) Id() any                     { return car.ID }
+func (MigrateCarV2) Create(db DB, data string) Model { return &MigrateCarV2{} }
+func (car *MigrateCarV2) Save(table Table) error     { return table.Save(car) }
+func (car *MigrateCarV2) Delete(db DB) error         { return nil }
+
+func TestAdditiveMigration(t *testing.T) {
+	db := openDB(t)
+	if _, err := db.Table("", &MigrateCar{}); err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	collection, _ := db.Dao().FindCollectionByNameOrId("migrate_car")
+	collection.Indexes = append(collection.Indexes, "CREATE INDEX `idx_year` ON `migrate_car` (`year`)")
+	if err := db.Dao().SaveCollection(collection); err != nil {
+		t.Fatalf("SaveCollection: %v", err)
+	}
+
+	if _, err := db.migrate("migrate_car", &MigrateCarV2{}, false, true); err != nil {
+		t.Fatalf("additive migrate: %v", err)
+	}
+	collection, _ = db.Dao().FindCollectionByNameOrId("migrate_car")
+	if collection.Schema.GetFieldByName("kind") == nil {
+		t.Errorf("additive migrate: kind is not added")
+	}
+	if field := collection.Schema.GetFieldByName("color"); field == nil {
+		t.Errorf("additive migrate: removed color is not kept")
+	}
+	if field := collection.Schema.GetFieldByName("year"); field == nil || field.Type != "number" {
+		t.Errorf("additive migrate: retyped year is not kept, got %v", field)
+	}
+	if len(collection.Indexes) != 1 {
+		t.Errorf("additive migrate: indexes = %v, want index of admin kept", collection.Indexes)
+	}
+
+	if _, err := db.Migrate("migrate_car", &MigrateCarV2{}, false); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	collection, _ = db.Dao().FindCollectionByNameOrId("migrate_car")
+	if collection.Schema.GetFieldByName("color") != nil {
+		t.Errorf("Migrate: color is not removed")
+	}
+	if field := collection.Schema.GetFieldByName("year"); field == nil || field.Type != "text" {
+		t.Errorf("Migrate: year is not retyped, got %v", field)
+	}
+	if len(collection.Indexes) != 1 {
+		t.Errorf("Migrate: indexes = %v, want index of admin kept", collection.Indexes)
+	}
+}
+
+type WithOwner struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (owner WithOwner) Id() any { return owner.ID }
+func (WithOwner) Create(db DB, data string) Model {
+	owner := &WithOwner{}
+	json.Unmarshal([]byte(data), owner)
+	return owner
+}
+func (owner *WithOwner) Save(table Table) error { return table.Save(owner) }
+func (owner *WithOwner) Delete(db DB) error     { return nil }
+
+type WithCar struct {
+	ID      string     `json:"id"`
+	Name    string     `json:"name"`
+	OwnerID string     `json:"owner_id" relation:"with_owner"`
+	Owner   *WithOwner `json:"-"`
+}
+
+func (car WithCar) Id() any { return car.ID }
+func (WithCar) Create(db DB, data string) Model {
+	car := &WithCar{}
+	json.Unmarshal([]byte(data), car)
+	return car
+}
+func (car *WithCar) Save(table Table) error { return table.Save(car) }
+func (car *WithCar) Delete(db DB) error     { return nil }
+
+func TestWith(t *testing.T) {
+	db := openDB(t)
+	owners, err := db.Table("", &WithOwner{})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	cars, err := db.Table("", &WithCar{})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	owner := &WithOwner{Name: "a"}
+	if err := owners.Save(owner); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	for _, car := range []*WithCar{{Name: "x", OwnerID: owner.ID}, {Name: "y"}} {
+		if err := cars.Save(car); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	byName := func(models []Model) map[string]*WithCar {
+		result := map[string]*WithCar{}
+		for _, model := range models {
+			result[model.(*WithCar).Name] = model.(*WithCar)
+		}
+		return result
+	}
+	// relations are expanded by list of All and Filter
+	for _, models := range [][]Model{
+		cars.Manager().With("Owner").All(),
+		cars.Manager().With("Owner").Filter(Params{"Name__in": []string{"x", "y"}}).All(),
+	} {
+		got := byName(models)
+		if got["x"] == nil || got["x"].Owner == nil || got["x"].Owner.Name != "a" {
+			t.Errorf("owner of x is not loaded: %v", got["x"])
+		}
+		if got["y"] == nil || got["y"].Owner != nil {
+			t.Errorf("y without owner has owner: %v", got["y"])
+		}
+	}
+	// relations are resolved on load without With too
+	if got := byName(cars.Manager().All()); got["x"] == nil || got["x"].Owner == nil || got["x"].Owner.Name != "a" {
+		t.Errorf("owner of x is not resolved without With: %v", got["x"])
+	}
+
+	// loaded models are expanded without reload
+	models := cars.Manager().Filter(Params{"Name": "x"}).All()
+	if len(models) != 1 {
+		t.Fatalf("Filter returns %v models, expected 1", len(models))
+	}
+	car := models[0].(*WithCar)
+	car.Name = "changed"
+	if err := cars.(*Collection).Expand(context.Background(), models, []string{"Owner"}); err != nil {
+		t.Fatalf("Expand: %v", err)
+	}
+	if car.Owner == nil || car.Owner.Name != "a" || car.Name != "changed" {
+		t.Errorf("Expand returns %+v, expected owner a and unsaved name", car)
+	}
+}
+
+type TxCar struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (car TxCar) Id() any { return car.ID }
+func (TxCar) Create(db DB, data string) Model {
+	car := &TxCar{}
+	json.Unmarshal([]byte(data), car)
+	return car
+}
+func (car *TxCar) Save(table Table) error { return table.Save(car) }
+func (car *TxCar) Delete(db DB) error     { return nil }
+
+func TestTxTable(t *testing.T) {
+	db := openDB(t)
+	// collection is created by dao of transaction, dao of app waits for it
+	done := make(chan error, 1)
+	go func() {
+		done <- db.Tx(func(tx DB) error {
+			table, err := tx.Table("", &TxCar{})
+			if err != nil {
+				return err
+			}
+			return table.Save(&TxCar{Name: "a"})
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Tx: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Table inside Tx is deadlocked")
+	}
+	table := db.TableFromCache("tx_car")
+	if table == nil {
+		t.Fatalf("collection opened inside Tx is not opened after commit")
+	}
+	if count := table.Count(); count != 1 {
+		t.Errorf("Count is %v, expected 1", count)
+	}
+}
+
+func TestUnsupportedLookups(t *testing.T) {
+	db := openDB(t)
+	owners, err := db.Table("", &WithOwner{})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	for _, params := range []Params{{"Name__contains": "a"}, {"Name__regex": "^a"}} {
+		_, err := owners.Manager().(ContextManager).FilterContext(context.Background(), params)
+		if _, ok := err.(ErrLookup); !ok {
+			t.Errorf("Filter(%v) returns %T: %v, expected ErrLookup", params, err, err)
+		}
+	}
+}
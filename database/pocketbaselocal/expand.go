@@ -0,0 +1,67 @@
+package pocketbaselocal
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase/models"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+var _ Expander = &Collection{}
+
+// Expand loads relations of models by ExpandRecords of dao in one query for each relation,
+// records are made of models, so models are not queried again.
+func (collection *Collection) Expand(ctx context.Context, objects []Model, relations []string) error {
+	if err := ctx.Err(); err != nil {
+		return NewErrorf("pocketbaselocal.collection.expand: %v", err)
+	}
+	pbCollection, err := collection.db.Dao().FindCollectionByNameOrId(collection.name)
+	if err != nil {
+		return NewErrorf("pocketbaselocal.collection.expand.findCollection: %v", err)
+	}
+	records := make([]*models.Record, len(objects))
+	for i, model := range objects {
+		dataB, err := json.Marshal(model)
+		if err != nil {
+			return NewErrorf("pocketbaselocal.collection.expand: %v", err)
+		}
+		data := map[string]any{}
+		if err := json.Unmarshal(dataB, &data); err != nil {
+			return NewErrorf("pocketbaselocal.collection.expand: %v", err)
+		}
+		records[i] = models.NewRecord(pbCollection)
+		records[i].Load(data)
+	}
+	return expandRecords(collection.db, collection.model, records, objects, relations)
+}
+
+// expandRecords loads relations of records by ExpandRecords of dao
+// and sets them to models, records[i] is record of objects[i].
+func expandRecords(db *DataBase, model Model, records []*models.Record, objects []Model, relations []string) error {
+	paths, err := base.ExpandPaths(db, model, relations)
+	if err != nil {
+		return err
+	}
+	for path, err := range db.Dao().ExpandRecords(records, paths, nil) {
+		return NewErrorf("pocketbaselocal.expand: `%v`: %v", path, err)
+	}
+	for i, record := range records {
+		dataB, err := json.Marshal(record)
+		if err != nil {
+			return NewErrorf("pocketbaselocal.expand: %v", err)
+		}
+		data := map[string]any{}
+		if err := json.Unmarshal(dataB, &data); err != nil {
+			return NewErrorf("pocketbaselocal.expand: %v", err)
+		}
+		expand, _ := data["expand"].(map[string]any)
+		if err := base.SetExpanded(db, objects[i], expand, relations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
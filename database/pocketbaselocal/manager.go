@@ -1,70 +1,159 @@
 package pocketbaselocal
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
-	"fmt"
-	"log"
-	"strings"
 
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
 	"github.com/pocketbase/pocketbase/models"
 
 	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
 	. "github.com/PoulIgorson/sub_engine_fiber/define"
 )
 
-func ManagerFilter(manager ManagerI, include Params, _ ...Params) []Model {
-	objects := []Model{}
-	filter := ""
-	for key, value := range nameFieldsToJSONTags(manager.Table().Model(), include) {
-		if strings.IndexByte("=<>~", key[len(key)-1]) == -1 {
-			key += "="
-		}
-		if len(filter) != 0 {
-			filter += "&&"
-		}
-		if str, ok := value.(string); ok {
-			value = `"` + str + `"`
-		}
-		filter += key + fmt.Sprint(value)
+// newManager returns manager of collection, its queries find records of collection.
+func newManager(collection *Collection) *base.Manager {
+	manager := base.NewManager(collection)
+	manager.OnAll = ManagerAll
+	manager.OnFilter = ManagerFilter
+	manager.OnAggregate = ManagerAggregate
+	manager.OnIterate = ManagerIterate
+	manager.Preloads = true
+	return manager
+}
+
+func ManagerFilter(ctx context.Context, manager ManagerI, q Q) ([]Model, error) {
+	filter, err := PBFilter(manager.Table().Model(), q)
+	if err != nil {
+		return nil, err
+	}
+	if filter == "" {
+		filter = `id!=""`
 	}
 
-	records, err := manager.Table().DB().(*DataBase).app.Dao().FindRecordsByFilter(manager.Table().Name(), filter, "-created", 0, 0)
+	objects, err := list(ctx, manager, filter)
 	if err != nil {
-		log.Printf("pocketbaselocal.managerFilter: %v\n", err)
-		return nil
+		return nil, NewErrorf("pocketbaselocal.managerFilter: %v", err)
 	}
+	return objects, nil
+}
 
+func ManagerAll(ctx context.Context, manager ManagerI) ([]Model, error) {
+	if manager.IsInstance() {
+		return manager.(*base.Manager).Cached(ctx)
+	}
+	// manager has no conditions but scope of soft deleted models
+	filter, err := PBFilter(manager.Table().Model(), manager.(*base.Manager).Query().Q())
+	if err != nil {
+		return nil, err
+	}
+	if filter == "" {
+		filter = `id!=""`
+	}
+	objects, err := list(ctx, manager, filter)
+	if err != nil {
+		return nil, NewErrorf("pocketbaselocal.managerAll: %v", err)
+	}
+	return objects, nil
+}
+
+// list returns models of filter, ordered and paged by query of manager,
+// relations of With are expanded on the found records.
+func list(ctx context.Context, manager ManagerI, filter string) ([]Model, error) {
+	query := manager.(*base.Manager).Query()
+	sort := PBSort(manager.Table().Model(), query.Order)
+	if sort == "" {
+		sort = "-created"
+	}
+	records, err := findRecordsByFilter(ctx, manager.Table().DB().(*DataBase).Dao(), manager.Table().Name(), filter, sort, int(query.Limit), int(query.Offset))
+	if err != nil {
+		return nil, err
+	}
+	objects := []Model{}
 	for _, record := range records {
 		model := recordToModel(record, manager.Table().DB(), manager.Table().Model())
 		objects = append(objects, model)
 	}
-	return objects
+	if len(query.With) > 0 {
+		db := manager.Table().DB().(*DataBase)
+		if err := expandRecords(db, manager.Table().Model(), records, objects, query.With); err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
 }
 
-func ManagerAll(manager ManagerI) []Model {
-	objects := []Model{}
-	if manager.IsInstance() {
-		oldAll := manager.(*base.Manager).OnAll
-		manager.(*base.Manager).OnAll = nil
-		objects = manager.All()
-		manager.(*base.Manager).OnAll = oldAll
-	} else {
-		records, err := manager.Table().DB().(*DataBase).app.Dao().FindRecordsByFilter(manager.Table().Name(), `id!=""`, "-created", 0, 0)
+// iteratePage is count of records loaded by ManagerIterate in one query
+const iteratePage = 200
+
+// ManagerIterate loads records by pages lazily, next page is queried
+// after fn takes all models of previous one.
+func ManagerIterate(ctx context.Context, manager ManagerI, q Q, fn func(model Model) bool) error {
+	filter, err := PBFilter(manager.Table().Model(), q)
+	if err != nil {
+		return err
+	}
+	if filter == "" {
+		filter = `id!=""`
+	}
+	sort := PBSort(manager.Table().Model(), manager.(*base.Manager).Query().Order)
+	if sort == "" {
+		sort = "-created"
+	}
+	for offset := 0; ; offset += iteratePage {
+		records, err := findRecordsByFilter(ctx, manager.Table().DB().(*DataBase).Dao(), manager.Table().Name(), filter, sort, iteratePage, offset)
 		if err != nil {
-			log.Printf("pocketbaselocal.managerAll: %v\n", err)
-			return nil
+			return err
 		}
 		for _, record := range records {
-			model := recordToModel(record, manager.Table().DB(), manager.Table().Model())
-			objects = append(objects, model)
+			if !fn(recordToModel(record, manager.Table().DB(), manager.Table().Model())) {
+				return nil
+			}
+		}
+		if len(records) < iteratePage {
+			return nil
+		}
+	}
+}
+
+// findRecordsByFilter is dao.FindRecordsByFilter executed with ctx.
+func findRecordsByFilter(ctx context.Context, dao *daos.Dao, collectionNameOrId, filter, sort string, limit, offset int) ([]*models.Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return contextDao(ctx, dao).FindRecordsByFilter(collectionNameOrId, filter, sort, limit, offset)
+}
+
+// contextDao returns dao without hooks whose queries run with ctx,
+// dao of transaction keeps its transaction.
+func contextDao(ctx context.Context, dao *daos.Dao) *daos.Dao {
+	switch db := dao.DB().(type) {
+	case *dbx.DB:
+		return daos.New(db.WithContext(ctx))
+	case *dbx.Tx:
+		builder, ok := db.Builder.(interface {
+			DB() *dbx.DB
+			Executor() dbx.Executor
+		})
+		if !ok {
+			return dao
+		}
+		if tx, ok := builder.Executor().(*sql.Tx); ok {
+			return daos.New(builder.DB().WithContext(ctx).Wrap(tx))
 		}
 	}
-	return objects
+	return dao
 }
 
 func recordToModel(record *models.Record, db DB, model Model) Model {
-	dataByte, _ := json.Marshal(record.PublicExport())
+	data := record.PublicExport()
+	MapAutoFields(model, data)
+	dataByte, _ := json.Marshal(data)
 	return model.Create(db, string(dataByte))
 }
 
@@ -0,0 +1,139 @@
+package pocketbaselocal
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// Migrate brings schema of existing collection `name` to schema of model
+// and appends applied migration to log of migrations in one transaction.
+// With dryRun planned changes are printed only.
+func (db *DataBase) Migrate(name string, model Model, dryRun bool) (Migration, error) {
+	return db.migrate(name, model, dryRun, false)
+}
+
+// migrate applies migration of collection `name` to schema of model,
+// additive migration keeps removed and retyped fields and indexes of collection.
+func (db *DataBase) migrate(name string, model Model, dryRun, additive bool) (Migration, error) {
+	collection, err := db.Dao().FindCollectionByNameOrId(name)
+	if err != nil {
+		return Migration{}, NewErrorf("pocketbaselocal.migrate: %v", err)
+	}
+	current := []map[string]any{}
+	dataB, err := json.Marshal(collection.Schema)
+	if err != nil {
+		return Migration{}, NewErrorf("pocketbaselocal.migrate.marshalSchema: %v", err)
+	}
+	if err := json.Unmarshal(dataB, &current); err != nil {
+		return Migration{}, NewErrorf("pocketbaselocal.migrate.unmarshalSchema: %v", err)
+	}
+
+	migration, err := PlanMigration(name, model, current, collection.Indexes)
+	if err != nil {
+		return migration, err
+	}
+	if additive {
+		var skipped []SchemaChange
+		migration, skipped = migration.Additive(current, collection.Indexes)
+		for _, change := range skipped {
+			log.Printf("pocketbaselocal.migrate: %v: `%v` is skipped, apply it by Migrate\n", name, change)
+		}
+	}
+	migration.Version = 1
+	if total, err := db.migrations(db.Dao(), name); err == nil {
+		migration.Version += total
+	}
+	if dryRun {
+		log.Println(migration)
+		return migration, nil
+	}
+	if len(migration.Changes) == 0 {
+		return migration, nil
+	}
+
+	newSchema, err := toSchema(db.Dao(), migration.Schema)
+	if err != nil {
+		return migration, err
+	}
+	collection.Schema = newSchema
+	collection.Indexes = types.JsonArray[string](migration.Indexes)
+	err = db.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		if err := txDao.SaveCollection(collection); err != nil {
+			return err
+		}
+		logCollection, err := txDao.FindCollectionByNameOrId(MigrationsCollection)
+		if err != nil {
+			if logCollection, err = newCollection(txDao, CreateDataMigrations()); err != nil {
+				return err
+			}
+			if err := txDao.SaveCollection(logCollection); err != nil {
+				return err
+			}
+		}
+		record := models.NewRecord(logCollection)
+		record.Load(migration.Record())
+		return txDao.SaveRecord(record)
+	})
+	if err != nil {
+		return migration, NewErrorf("pocketbaselocal.migrate: %v", err)
+	}
+	return migration, nil
+}
+
+// migrations returns count of applied migrations of collection `name`.
+func (db *DataBase) migrations(dao *daos.Dao, name string) (uint, error) {
+	records, err := dao.FindRecordsByExpr(MigrationsCollection, dbx.HashExp{"collection": name})
+	if err != nil {
+		return 0, err
+	}
+	return uint(len(records)), nil
+}
+
+// newCollection returns new collection of data of CreateDataCollection.
+func newCollection(dao *daos.Dao, data map[string]any) (*models.Collection, error) {
+	newSchema, err := toSchema(dao, data["schema"].([]map[string]any))
+	if err != nil {
+		return nil, err
+	}
+	collection := &models.Collection{
+		Name:    data["name"].(string),
+		Type:    data["type"].(string),
+		Schema:  newSchema,
+		Indexes: types.JsonArray[string](data["indexes"].([]string)),
+	}
+	collection.MarkAsNew()
+	return collection, nil
+}
+
+// toSchema returns schema of fields, relations to collections are resolved by dao.
+func toSchema(dao *daos.Dao, fields []map[string]any) (schema.Schema, error) {
+	newSchema := schema.Schema{}
+	err := ResolveRelations(fields, func(name string) (string, error) {
+		collection, err := dao.FindCollectionByNameOrId(name)
+		if err != nil {
+			return "", err
+		}
+		return collection.Id, nil
+	})
+	if err != nil {
+		return newSchema, err
+	}
+	dataB, err := json.Marshal(fields)
+	if err != nil {
+		return newSchema, NewErrorf("pocketbaselocal.schema.marshal: %v", err)
+	}
+	if err := newSchema.UnmarshalJSON(dataB); err != nil {
+		return newSchema, NewErrorf("pocketbaselocal.schema.unmarshalJSON: %v", err)
+	}
+	return newSchema, nil
+}
@@ -0,0 +1,6 @@
+//go:build !goexperiment.jsonv2
+
+package pocketbaselocal
+
+// jsonV2 is true if encoding/json is implemented by encoding/json/v2.
+const jsonV2 = false
@@ -1,19 +1,25 @@
 package pocketbaselocal
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"reflect"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/daos"
 	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
 
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
 	. "github.com/PoulIgorson/sub_engine_fiber/define"
 )
 
-var _ Table = &Collection{}
+var _ ContextTable = &Collection{}
 
 type Collection struct {
 	db   *DataBase
@@ -21,6 +27,18 @@ type Collection struct {
 
 	model   Model
 	Objects ManagerI
+
+	// parent is collection of parent db, set for collection of transaction
+	parent *Collection
+}
+
+// cache returns manager of collection outside of transaction,
+// it is updated after commit.
+func (collection *Collection) cache() ManagerI {
+	if collection.parent != nil {
+		return collection.parent.Objects
+	}
+	return collection.Objects
 }
 
 func (collection Collection) DB() DB {
@@ -36,43 +54,99 @@ func (collection Collection) Model() Model {
 }
 
 func (collection *Collection) Get(idI any) (Model, error) {
+	return collection.GetContext(context.Background(), idI)
+}
+
+func (collection *Collection) GetContext(ctx context.Context, idI any) (Model, error) {
 	id, ok := idI.(string)
 	if !ok {
 		return nil, NewErrorf("pb: id must be string")
 	}
-	record, err := collection.db.app.Dao().FindRecordById(collection.name, id)
+	record, err := collection.db.Dao().FindRecordById(collection.name, id, withContext(ctx))
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if err != nil {
 		return nil, NewErrorf("pocketbaselocal.table.get: model not found")
 	}
-	dataByte, _ := json.Marshal(record.PublicExport())
+	data := record.PublicExport()
+	MapAutoFields(collection.model, data)
+	dataByte, _ := json.Marshal(data)
 	model := collection.model.Create(collection.db, string(dataByte))
-	collection.Objects.Store(model.Id().(string), model)
+	collection.db.afterCommit.Do(func() { collection.cache().Store(model.Id().(string), model) })
 	return model, nil
 }
 
 func (collection *Collection) Save(model Model) error {
+	return collection.SaveContext(context.Background(), model)
+}
+
+func (collection *Collection) SaveContext(ctx context.Context, model Model) error {
 	if model.Id() == nil {
-		return NewErrorf("pocketbaselocal.collection.save: id experted string, got nil")
+		// model without id is new model
+		fieldId, err := Check(model, "ID")
+		if err != nil {
+			return NewErrorf("pocketbaselocal.getFieldID: %v", err)
+		}
+		fieldId.Set(reflect.ValueOf(""))
 	}
 	if _, ok := model.Id().(string); !ok {
 		return NewErrorf("pocketbaselocal.collection.save: id experted string, got %v", reflect.TypeOf(model.Id()))
 	}
-	dataByte, _ := json.Marshal(model)
-	data := map[string]any{}
-	json.Unmarshal(dataByte, &data)
-
-	record, err := collection.db.app.Dao().FindRecordById(collection.name, model.Id().(string))
+	version := base.ModelVersion(model)
+	if collection.db.dao == nil && (base.HasSaveHooks(model) || VersionField(model) != "") {
+		// hooks and check of version run in transaction of write
+		id := model.Id()
+		err := collection.db.Tx(func(tx DB) error {
+			return tx.TableFromCache(collection.name).(*Collection).SaveContext(ctx, model)
+		})
+		if err != nil {
+			if fieldId, errC := Check(model, "ID"); errC == nil {
+				fieldId.Set(reflect.ValueOf(id))
+			}
+			base.SetVersion(model, version)
+			return err
+		}
+		// fields are bound to table, not to finished transaction
+		base.BindManyRelations(collection, model)
+		return nil
+	}
+	if err := base.BeforeSave(collection, model); err != nil {
+		return err
+	}
+	if err := Validate(collection.name, model); err != nil {
+		return err
+	}
+	record, err := collection.db.Dao().FindRecordById(collection.name, model.Id().(string), withContext(ctx))
+	if err := ctx.Err(); err != nil {
+		return NewErrorf("pocketbaselocal.collection.save: %v", err)
+	}
 	if err != nil {
 		name := GetNameModel(model)
-		collectionPB, err := collection.db.app.Dao().FindCollectionByNameOrId(name)
+		collectionPB, err := collection.db.Dao().FindCollectionByNameOrId(name)
 		if err != nil {
 			return NewErrorf("pocketbaselocal.collection.save.findCollection: %v", err)
 		}
 		record = models.NewRecord(collectionPB)
 	}
+	if VersionField(model) != "" {
+		if err := base.NextVersion(ctx, collection.name, model, uint64(record.GetInt(Version)), !record.IsNew()); err != nil {
+			return err
+		}
+	}
+
+	dataByte, _ := json.Marshal(model)
+	data := map[string]any{}
+	json.Unmarshal(dataByte, &data)
 	delete(data, "id")
+	DropAutoFields(model, data)
 
 	for field, value := range data {
+		if value == nil {
+			// null of interface field, empty relation
+			record.Set(field, nil)
+			continue
+		}
 		typ := GetType(reflect.ValueOf(value))
 		if typ == "" || typ == "json" {
 			valueB, _ := json.Marshal(value)
@@ -81,7 +155,23 @@ func (collection *Collection) Save(model Model) error {
 		record.Set(field, value)
 	}
 
-	if err := collection.db.app.Dao().SaveRecord(record); err != nil {
+	if base.IsRestored(ctx) && record.IsNew() {
+		// pocketbase keeps system times of new record which are set
+		if created := base.AutoTime(model, AutoCreated); !created.IsZero() {
+			record.Created, _ = types.ParseDateTime(created)
+		}
+		if updated := base.AutoTime(model, AutoUpdated); !updated.IsZero() {
+			record.Updated, _ = types.ParseDateTime(updated)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return NewErrorf("pocketbaselocal.collection.save: %v", err)
+	}
+	if err := collection.db.Dao().SaveRecord(record); err != nil {
+		base.SetVersion(model, version)
+		if fields := UniqueColumns(err); len(fields) > 0 {
+			return NewErrUnique(collection.name, fields)
+		}
 		return NewErrorf("pocketbaselocal.collection.save.saveRecord: %v", err)
 	}
 
@@ -90,10 +180,17 @@ func (collection *Collection) Save(model Model) error {
 		return NewErrorf("pocketbaselocal.getFieldID: %v", err)
 	}
 	fieldId.Set(reflect.ValueOf(record.Id))
-	return nil
+	base.Stamp(ctx, model, record.Created.Time(), record.Updated.Time())
+	base.BindManyRelations(collection, model)
+	return base.AfterSave(collection, model)
 }
 
 func (collection *Collection) Delete(idI any) error {
+	return collection.DeleteContext(context.Background(), idI)
+}
+
+// DeleteContext removes model of id, soft deleted models are marked by field `deleted_at`.
+func (collection *Collection) DeleteContext(ctx context.Context, idI any) error {
 	id, ok := idI.(string)
 	if !ok {
 		return NewErrorf("pb: id must be string")
@@ -101,22 +198,89 @@ func (collection *Collection) Delete(idI any) error {
 	if id == "" {
 		return nil
 	}
-	record, err := collection.db.app.Dao().FindRecordById(collection.name, id)
+	if SoftDeleteField(collection.model) != "" {
+		return base.SoftDeleteContext(ctx, collection, id)
+	}
+	return collection.hardDelete(ctx, id)
+}
+
+// HardDelete removes model of id, soft deleted models too.
+func (collection *Collection) HardDelete(idI any) error {
+	id, ok := idI.(string)
+	if !ok {
+		return NewErrorf("pb: id must be string")
+	}
+	if id == "" {
+		return nil
+	}
+	return collection.hardDelete(context.Background(), id)
+}
+
+// Restore clears field `deleted_at` of soft deleted model of id.
+func (collection *Collection) Restore(idI any) error {
+	return base.Restore(collection, idI)
+}
+
+// hardDelete removes record of id with actions of relations.
+func (collection *Collection) hardDelete(ctx context.Context, id string) error {
+	references := collection.db.relations.To(collection.name)
+	hooked := base.HasDeleteHooks(collection.model)
+	if collection.db.dao == nil && (len(references) > 0 || hooked) {
+		return collection.db.Tx(func(tx DB) error {
+			return tx.TableFromCache(collection.name).(*Collection).hardDelete(ctx, id)
+		})
+	}
+	record, err := collection.db.Dao().FindRecordById(collection.name, id, withContext(ctx))
+	if err := ctx.Err(); err != nil {
+		return NewErrorf("pocketbaselocal.table.delete: %v", err)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// missing record is not deleted, hooks are not called
+		return nil
+	}
 	if err != nil {
 		return NewErrorf("pocketbaselocal.table.delete.findRecord: %v", err)
 	}
+	var model Model
+	if hooked {
+		model = recordToModel(record, collection.db, collection.model)
+		if err := base.BeforeDelete(collection, model); err != nil {
+			return err
+		}
+	}
+	if len(references) > 0 {
+		if err := collection.db.relations.OnDelete(collection.db, collection.name, id); err != nil {
+			return err
+		}
+	}
 
-	if err := collection.db.app.Dao().DeleteRecord(record); err != nil {
+	if err := collection.db.Dao().DeleteRecord(record); err != nil {
 		return NewErrorf("pocketbaselocal.table.delete.deleteRecord: %v", err)
 	}
+	if model != nil {
+		if err := base.AfterDelete(collection, model); err != nil {
+			return err
+		}
+	}
+	collection.db.afterCommit.Do(func() { collection.cache().ClearId(id) })
 	return nil
 }
 
+// SaveMany saves models in one transaction.
+func (collection *Collection) SaveMany(models []Model) error {
+	return base.SaveMany(collection, models)
+}
+
+// DeleteMany deletes models of ids in one transaction.
+func (collection *Collection) DeleteMany(ids []any) error {
+	return base.DeleteMany(collection, ids)
+}
+
 // Pocketbase does not support DeleteAll
 // All models will be deletting of one
 func (collection *Collection) DeleteAll() error {
-	err := collection.db.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
-		records, err := collection.db.app.Dao().FindRecordsByFilter(collection.name, `id!=""`, "-created", 0, 0)
+	err := collection.db.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		records, err := txDao.FindRecordsByFilter(collection.name, `id!=""`, "-created", 0, 0)
 		if err != nil {
 			return err
 		}
@@ -128,11 +292,18 @@ func (collection *Collection) DeleteAll() error {
 	if err != nil {
 		return NewErrorf("pocketbaselocal.table.deleteAll: %v", err)
 	}
+	collection.db.afterCommit.Do(collection.cache().Clear)
 	return nil
 }
 
+// Count returns count of records of collection, soft deleted records too.
 func (collection Collection) Count() uint {
-	return 0
+	var count uint
+	err := collection.db.Dao().DB().Select("COUNT(*)").From(collection.name).Row(&count)
+	if err != nil {
+		return 0
+	}
+	return count
 }
 
 func (collection Collection) Manager() ManagerI {
@@ -142,3 +313,11 @@ func (collection Collection) Manager() ManagerI {
 func (collection *Collection) SetManager(newManager ManagerI) {
 	collection.Objects = newManager
 }
+
+// withContext binds query of dao.FindRecordById to ctx.
+func withContext(ctx context.Context) func(q *dbx.SelectQuery) error {
+	return func(q *dbx.SelectQuery) error {
+		q.WithContext(ctx)
+		return nil
+	}
+}
@@ -0,0 +1,328 @@
+// Package sqlite implements access to embedded sqlite db,
+// every table is a sql table with columns of fields of its model.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	sqlite "modernc.org/sqlite"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+var _ DB = &DataBase{}
+
+var (
+	regexpOnce sync.Once
+	regexpErr  error
+)
+
+// registerRegexp registers function of operator REGEXP, sqlite has operator without it.
+// Driver of sqlite has no functions of one connection, function is added to
+// all connections opened after first Open. Function of other package of the same name is kept.
+func registerRegexp() error {
+	regexpOnce.Do(func() {
+		regexpErr = sqlite.RegisterDeterministicScalarFunction("regexp", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			if args[0] == nil || args[1] == nil {
+				return nil, nil
+			}
+			re, err := regexp.Compile(fmt.Sprint(args[0]))
+			if err != nil {
+				return nil, err
+			}
+			return re.MatchString(fmt.Sprint(args[1])), nil
+		})
+		if regexpErr != nil && strings.Contains(regexpErr.Error(), "already registered") {
+			regexpErr = nil
+		}
+	})
+	return regexpErr
+}
+
+type tableMap struct {
+	sync.Map
+}
+
+func (m *tableMap) Load(name string) *SQLTable {
+	v, ok := m.Map.Load(name)
+	if !ok {
+		return nil
+	}
+	return v.(*SQLTable)
+}
+
+func (m *tableMap) LoadOK(name string) (*SQLTable, bool) {
+	v, ok := m.Map.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return v.(*SQLTable), true
+}
+
+func (m *tableMap) Range(fn func(name string, table *SQLTable) (continue_ bool)) {
+	m.Map.Range(func(nameI any, tableI any) bool {
+		return fn(nameI.(string), tableI.(*SQLTable))
+	})
+}
+
+// querier is sql.DB or sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// DataBase implements interface access to sqlite db.
+type DataBase struct {
+	sqlDB     *sql.DB
+	tables    tableMap // map[string]Table
+	relations *base.Relations
+
+	// set for view returned by Tx
+	tx          *sql.Tx
+	parent      *DataBase
+	afterCommit *base.Deferred
+}
+
+// Open returns DataBase of file of path, file is created if it does not exist.
+// Transactions take lock of write at once, other writers wait for it.
+func Open(path string) (*DataBase, error) {
+	if strings.Contains(path, "?") {
+		return nil, NewErrorf("sqlite: path must not contain parameters")
+	}
+	if err := registerRegexp(); err != nil {
+		return nil, NewErrorf("sqlite: %v", err)
+	}
+	dsn := "file:" + path + "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, NewErrorf("sqlite: %v", err)
+	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, NewErrorf("sqlite: %v", err)
+	}
+	return &DataBase{sqlDB: sqlDB, relations: &base.Relations{}}, nil
+}
+
+// SQLDB returns sql.DB of db.
+func (db *DataBase) SQLDB() *sql.DB {
+	return db.sqlDB
+}
+
+// conn returns transaction of db or sql.DB.
+func (db *DataBase) conn() querier {
+	if db.tx != nil {
+		return db.tx
+	}
+	return db.sqlDB
+}
+
+// Tx runs fn in one sql transaction, tables of tx share it.
+// Managers of tables are updated after commit only, panic of fn rolls back transaction.
+func (db *DataBase) Tx(fn func(tx DB) error) error {
+	if db.tx != nil {
+		return fn(db)
+	}
+	tx, err := db.sqlDB.Begin()
+	if err != nil {
+		return NewErrorf("sqlite: %v", err)
+	}
+	txDB := &DataBase{
+		sqlDB:       db.sqlDB,
+		relations:   db.relations,
+		tx:          tx,
+		parent:      db,
+		afterCommit: &base.Deferred{},
+	}
+	defer func() {
+		// panic of fn rolls back transaction, it is not left open on connection
+		if r := recover(); r != nil {
+			tx.Rollback()
+			txDB.tx = nil
+			panic(r)
+		}
+	}()
+	if err := fn(txDB); err != nil {
+		tx.Rollback()
+		txDB.tx = nil
+		return err
+	}
+	err = tx.Commit()
+	txDB.tx = nil
+	if err != nil {
+		return NewErrorf("sqlite: %v", err)
+	}
+	txDB.afterCommit.Run()
+	return nil
+}
+
+// Close implements access to close DataBase.
+func (db *DataBase) Close() error {
+	if db.tx != nil {
+		return NewErrorf("sqlite: transaction can not be closed")
+	}
+	db.tables.Range(func(_ string, table *SQLTable) (continue_ bool) {
+		table.Objects.Clear()
+		return true
+	})
+	db.tables = tableMap{}
+	if err := db.sqlDB.Close(); err != nil {
+		return NewErrorf("sqlite: %v", err)
+	}
+	return nil
+}
+
+func (db *DataBase) TableFromCache(name string) Table {
+	table := db.tables.Load(name)
+	if table == nil && db.tx != nil {
+		if parent := db.parent.tables.Load(name); parent != nil {
+			txTable, _ := db.Table(name, parent.model)
+			return txTable
+		}
+	}
+	if table == nil {
+		return nil
+	}
+	return table
+}
+
+// Table returns table of model, sql table is created with columns of fields of model
+// and missing columns are added to existing one. Id of model must be uint or string.
+// name is not required
+func (db *DataBase) Table(_ string, model Model) (Table, error) {
+	name := GetNameModel(model)
+	if table := db.tables.Load(name); table != nil {
+		return table, nil
+	}
+	if db.tx != nil {
+		return db.txTable(name, model)
+	}
+
+	schema, err := newSchema(name, model)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.createTable(schema); err != nil {
+		return nil, NewErrorf("sqlite: %v", err)
+	}
+	db.relations.Register(name, model)
+
+	table := &SQLTable{
+		db:     db,
+		name:   name,
+		model:  model,
+		schema: schema,
+	}
+	table.Objects = newManager(table)
+	db.tables.Store(name, table)
+	return table, nil
+}
+
+// txTable returns table bound to transaction of db, its manager reads
+// the transaction and manager of table of parent db is updated after commit.
+// Sql table of model missing in parent is created in transaction.
+func (db *DataBase) txTable(name string, model Model) (Table, error) {
+	parent := db.parent.tables.Load(name)
+	if parent == nil {
+		schema, err := newSchema(name, model)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.createTable(schema); err != nil {
+			return nil, NewErrorf("sqlite: %v", err)
+		}
+		db.relations.Register(name, model)
+		parent = &SQLTable{
+			db:     db.parent,
+			name:   name,
+			model:  model,
+			schema: schema,
+		}
+		parent.Objects = newManager(parent)
+		db.afterCommit.Do(func() { db.parent.tables.Store(name, parent) })
+	}
+	table := &SQLTable{
+		db:     db,
+		name:   name,
+		model:  model,
+		schema: parent.schema,
+		parent: parent,
+	}
+	table.Objects = newManager(table)
+	db.tables.Store(name, table)
+	return table, nil
+}
+
+// createTable creates sql table and indexes of schema, missing columns are added.
+func (db *DataBase) createTable(schema *schema) error {
+	if _, err := db.conn().ExecContext(context.Background(), schema.createSQL()); err != nil {
+		return err
+	}
+	existing, err := db.columns(schema.name)
+	if err != nil {
+		return err
+	}
+	for _, column := range schema.columns {
+		if existing[column.Name] {
+			continue
+		}
+		if _, err := db.conn().ExecContext(context.Background(), fmt.Sprintf("ALTER TABLE %v ADD COLUMN %v", quote(schema.name), column.definition())); err != nil {
+			return err
+		}
+	}
+	for _, index := range schema.indexesSQL() {
+		if _, err := db.conn().ExecContext(context.Background(), index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columns returns names of columns of sql table.
+func (db *DataBase) columns(name string) (map[string]bool, error) {
+	rows, err := db.conn().QueryContext(context.Background(), "SELECT name FROM pragma_table_info(?)", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := map[string]bool{}
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns[column] = true
+	}
+	return columns, rows.Err()
+}
+
+// model returns model of opened table `name`.
+func (db *DataBase) model(name string) Model {
+	if table := db.tables.Load(name); table != nil {
+		return table.model
+	}
+	if db.parent != nil {
+		return db.parent.model(name)
+	}
+	return nil
+}
+
+// ExistsTable returns true if sql table exists.
+func (db *DataBase) ExistsTable(name string) bool {
+	if _, ok := db.tables.LoadOK(name); ok {
+		return true
+	}
+	var count int
+	err := db.conn().QueryRowContext(context.Background(), "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count)
+	return err == nil && count > 0
+}
@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/dbtest"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// openDB returns db in temporary dir of t, it is closed by cleanup of t.
+func openDB(t *testing.T) *DataBase {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	t.Run("UintIds", func(t *testing.T) {
+		dbtest.Run(t, dbtest.Config{Open: func(t *testing.T) DB {
+			return openDB(t)
+		}})
+	})
+	t.Run("StringIds", func(t *testing.T) {
+		dbtest.Run(t, dbtest.Config{StringIds: true, Open: func(t *testing.T) DB {
+			return openDB(t)
+		}})
+	})
+}
+
+func TestTxPanic(t *testing.T) {
+	db := openDB(t)
+	table, err := db.Table("test_item", &dbtest.TestItem{ID: uint(0)})
+	if err != nil {
+		t.Fatalf("Table: %v", err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != "fail" {
+				t.Fatalf("Tx recovers %v, expected panic of fn", r)
+			}
+		}()
+		db.Tx(func(tx DB) error {
+			tx.TableFromCache("test_item").Save(&dbtest.TestItem{ID: uint(0), Name: "a"})
+			panic("fail")
+		})
+	}()
+
+	// transaction is rolled back, it does not lock db
+	done := make(chan error, 1)
+	go func() { done <- table.Save(&dbtest.TestItem{ID: uint(0), Name: "b"}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Save after panic: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Save after panic waits for transaction of fn")
+	}
+	names := []string{}
+	for _, model := range table.Manager().All() {
+		names = append(names, model.(*dbtest.TestItem).Name)
+	}
+	if len(names) != 1 || names[0] != "b" {
+		t.Errorf("models after panic are %v, expected b", names)
+	}
+}
@@ -0,0 +1,287 @@
+package sqlite
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// sqlFalse is condition matching no rows
+const sqlFalse = "0"
+
+// zeroTime is zero time in timeLayout
+const zeroTime = "0001-01-01 00:00:00.000000000Z"
+
+// filter builds parameterized sql conditions of Q,
+// conditions of unknown fields are skipped as by base.Manager.
+type filter struct {
+	db    *DataBase
+	args  []any
+	alias int // count of aliases of tables of subqueries
+}
+
+// where returns sql condition of q on columns of table `t0` with its arguments,
+// empty condition matches all rows.
+func where(table *SQLTable, q Q) (string, []any, error) {
+	f := &filter{db: table.db}
+	condition, err := f.q(table.schema, "t0", q)
+	if err != nil {
+		return "", nil, err
+	}
+	return condition, f.args, nil
+}
+
+func (f *filter) q(schema *schema, alias string, q Q) (string, error) {
+	terms := []string{}
+	if len(q.Params) > 0 {
+		params := []string{}
+		for _, key := range SortedKeys(q.Params) {
+			field, op := SplitKey(key)
+			term, err := f.term(schema, alias, field, op, q.Params[key])
+			if err != nil {
+				return "", NewErrLookup(key, err.Error())
+			}
+			params = append(params, term)
+		}
+		terms = append(terms, join(params, false))
+	}
+	for _, child := range q.Children {
+		term, err := f.q(schema, alias, child)
+		if err != nil {
+			return "", err
+		}
+		terms = append(terms, term)
+	}
+	condition := join(terms, q.IsOr())
+	if q.Not {
+		return not(condition), nil
+	}
+	return condition, nil
+}
+
+// join joins terms by Or or by And, empty term is true.
+func join(terms []string, or bool) string {
+	if or {
+		if len(terms) == 0 {
+			return sqlFalse
+		}
+		for _, term := range terms {
+			if term == "" {
+				return ""
+			}
+		}
+		if len(terms) == 1 {
+			return terms[0]
+		}
+		return "(" + strings.Join(terms, " OR ") + ")"
+	}
+	nonEmpty := []string{}
+	for _, term := range terms {
+		if term != "" {
+			nonEmpty = append(nonEmpty, term)
+		}
+	}
+	if len(nonEmpty) <= 1 {
+		return strings.Join(nonEmpty, "")
+	}
+	return "(" + strings.Join(nonEmpty, " AND ") + ")"
+}
+
+// not negates condition, unknown result of NULL is false before negation.
+func not(condition string) string {
+	if condition == "" {
+		return sqlFalse
+	}
+	return "NOT IFNULL(" + condition + ", 0)"
+}
+
+// term returns condition of field, names of fields of related models
+// are joined by "__", `Owner__Name` is name of owner.
+func (f *filter) term(schema *schema, alias, field, op string, value any) (string, error) {
+	if name, rest, ok := strings.Cut(field, "__"); ok {
+		return f.related(schema, alias, name, rest, op, value)
+	}
+	column, ok := schema.column(field)
+	if !ok {
+		return "", nil
+	}
+	ref := alias + "." + quote(column.Name)
+
+	switch op {
+	case "__in":
+		if !isList(value) {
+			return "", fmt.Errorf("value of type %T is not list", value)
+		}
+		items := reflect.ValueOf(value)
+		if column.List {
+			terms := []string{}
+			for i := 0; i < items.Len(); i++ {
+				term, err := f.term(schema, alias, field, "__contains", items.Index(i).Interface())
+				if err != nil {
+					return "", err
+				}
+				terms = append(terms, term)
+			}
+			return join(terms, true), nil
+		}
+		if items.Len() == 0 {
+			return sqlFalse, nil
+		}
+		marks := make([]string, items.Len())
+		for i := range marks {
+			marks[i] = f.arg(column, items.Index(i).Interface())
+		}
+		return ref + " IN (" + strings.Join(marks, ", ") + ")", nil
+	case "__contains":
+		if column.List {
+			return "EXISTS (SELECT 1 FROM json_each(" + ref + ") WHERE json_each.value = " + f.arg(column, value) + ")", nil
+		}
+		return "instr(" + ref + ", " + f.text(value) + ") > 0", nil
+	case "__icontains", "~":
+		if column.List {
+			return "EXISTS (SELECT 1 FROM json_each(" + ref + ") WHERE lower(json_each.value) = lower(" + f.text(value) + "))", nil
+		}
+		return "instr(lower(" + ref + "), lower(" + f.text(value) + ")) > 0", nil
+	case "!~":
+		condition, err := f.term(schema, alias, field, "~", value)
+		return not(condition), err
+	case "__startswith":
+		return "substr(" + ref + ", 1, length(" + f.text(value) + ")) = " + f.text(value), nil
+	case "__isnull":
+		null, ok := value.(bool)
+		if !ok {
+			return "", fmt.Errorf("value of type %T is not bool", value)
+		}
+		condition := isNull(column, ref)
+		if !null {
+			return not(condition), nil
+		}
+		return condition, nil
+	case "__between":
+		bounds := reflect.ValueOf(value)
+		if !isList(value) || bounds.Len() != 2 {
+			return "", fmt.Errorf("value of type %T is not pair of bounds", value)
+		}
+		lower := f.arg(column, bounds.Index(0).Interface())
+		upper := f.arg(column, bounds.Index(1).Interface())
+		return ref + " BETWEEN " + lower + " AND " + upper, nil
+	case "__regex":
+		return ref + " REGEXP " + f.text(value), nil
+	}
+
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Pointer && reflect.ValueOf(value).IsNil()) {
+		switch op {
+		case "=":
+			return isNull(column, ref), nil
+		case "!=":
+			return not(isNull(column, ref)), nil
+		}
+		return "", fmt.Errorf("operator `%v` can not compare null", op)
+	}
+	return ref + " " + op + " " + f.arg(column, value), nil
+}
+
+// related returns condition of field `rest` of related models of relation or many-to-many field `name`,
+// unknown name is skipped.
+func (f *filter) related(schema *schema, alias, name, rest, op string, value any) (string, error) {
+	modelT := reflect.TypeOf(schema.model).Elem()
+	table, related, column, many := "", Model(nil), "", false
+	for _, relation := range ModelManyRelations(schema.model) {
+		if relation.Field == name {
+			table, column, many = relation.Table, relation.Name, true
+			field, _ := modelT.FieldByName(relation.Field)
+			related = reflect.New(field.Type).Interface().(ManyToManyField).Related()
+		}
+	}
+	for _, relation := range ModelRelations(schema.model) {
+		if relation.Pointer == name || relation.Field == name {
+			table, column = relation.Table, relation.Name
+			if relation.Pointer != "" {
+				field, _ := modelT.FieldByName(relation.Pointer)
+				related, _ = reflect.New(field.Type.Elem()).Interface().(Model)
+			}
+		}
+	}
+	if table == "" {
+		return "", nil
+	}
+	if opened := f.db.model(table); opened != nil {
+		related = opened
+	}
+	if related == nil {
+		return "", fmt.Errorf("model of table `%v` is unknown", table)
+	}
+	relatedSchema, err := newSchema(table, related)
+	if err != nil {
+		return "", err
+	}
+
+	f.alias++
+	relatedAlias := fmt.Sprintf("t%v", f.alias)
+	condition, err := f.term(relatedSchema, relatedAlias, rest, op, value)
+	if err != nil {
+		return "", err
+	}
+	subquery := "SELECT " + relatedAlias + ".id FROM " + quote(table) + " AS " + relatedAlias
+	if condition != "" {
+		subquery += " WHERE " + condition
+	}
+	ref := alias + "." + quote(column)
+	if many {
+		return "EXISTS (SELECT 1 FROM json_each(" + ref + ") WHERE json_each.value IN (" + subquery + "))", nil
+	}
+	return ref + " IN (" + subquery + ")", nil
+}
+
+// isNull returns condition of empty value of column as base.Manager: nil, empty string,
+// empty list or zero time. Numbers and bools are never null.
+func isNull(column column, ref string) string {
+	switch column.Type {
+	case typeNumber, typeBool:
+		return sqlFalse
+	case typeDate:
+		return "(" + ref + " IS NULL OR " + ref + " = '" + zeroTime + "')"
+	case typeJSON:
+		return "(" + ref + " IS NULL OR " + ref + " IN ('null', '[]', '{}', '\"\"'))"
+	}
+	return "(" + ref + " IS NULL OR " + ref + " = '')"
+}
+
+// arg adds argument compared with column and returns its mark.
+func (f *filter) arg(column column, value any) string {
+	f.args = append(f.args, sqlArg(column, value))
+	return "?"
+}
+
+// text adds argument as string and returns its mark.
+func (f *filter) text(value any) string {
+	f.args = append(f.args, fmt.Sprint(sqlArg(column{Type: typeText}, value)))
+	return "?"
+}
+
+func isList(value any) bool {
+	kind := reflect.ValueOf(value).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+// order returns sql order of fields of model, unknown fields are skipped.
+// Rows of equal values are ordered by id.
+func order(schema *schema, fields []string) string {
+	terms := []string{}
+	for _, field := range fields {
+		direction := "ASC"
+		if strings.HasPrefix(field, "-") {
+			direction, field = "DESC", field[1:]
+		}
+		column, ok := schema.column(field)
+		if !ok {
+			continue
+		}
+		terms = append(terms, "t0."+quote(column.Name)+" "+direction)
+	}
+	return strings.Join(append(terms, "t0.id ASC"), ", ")
+}
@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+type filterOwner struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func (owner filterOwner) Id() any                   { return owner.ID }
+func (filterOwner) Create(db DB, data string) Model { return &filterOwner{} }
+func (owner *filterOwner) Save(table Table) error   { return table.Save(owner) }
+func (owner *filterOwner) Delete(db DB) error       { return nil }
+
+type filterCar struct {
+	ID        uint         `json:"id"`
+	Name      string       `json:"name"`
+	Year      uint         `json:"year"`
+	Tags      []string     `json:"tags"`
+	Ref       any          `json:"ref"`
+	OwnerID   uint         `json:"owner_id" relation:"filter_owner"`
+	Owner     *filterOwner `json:"-"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+func (car filterCar) Id() any                     { return car.ID }
+func (filterCar) Create(db DB, data string) Model { return &filterCar{} }
+func (car *filterCar) Save(table Table) error     { return table.Save(car) }
+func (car *filterCar) Delete(db DB) error         { return nil }
+
+func TestWhere(t *testing.T) {
+	schema, err := newSchema("filter_car", &filterCar{})
+	if err != nil {
+		t.Fatalf("newSchema: %v", err)
+	}
+	table := &SQLTable{db: &DataBase{}, name: "filter_car", schema: schema}
+	tests := []struct {
+		name string
+		q    Q
+		want string
+		args []any
+	}{
+		{"Empty", And(), ``, nil},
+		{"Params", And(Params{"Name": "a", "Year>": 2000}), `(t0."name" = ? AND t0."year" > ?)`, []any{"a", int64(2000)}},
+		{"Or", Or(Params{"Name": "a"}, Params{"Name": "b"}), `(t0."name" = ? OR t0."name" = ?)`, []any{"a", "b"}},
+		{"EmptyOr", Or(), sqlFalse, nil},
+		{"Not", Not(Params{"Name": "a"}), `NOT IFNULL(t0."name" = ?, 0)`, []any{"a"}},
+		{"Unknown", And(Params{"Missing": 1}), ``, nil},
+		{"In", And(Params{"Year__in": []uint{2000, 2001}}), `t0."year" IN (?, ?)`, []any{int64(2000), int64(2001)}},
+		{"InEmpty", And(Params{"Year__in": []uint{}}), sqlFalse, nil},
+		{"Contains", And(Params{"Name__contains": "a"}), `instr(t0."name", ?) > 0`, []any{"a"}},
+		{"ContainsList", And(Params{"Tags__contains": "a"}), `EXISTS (SELECT 1 FROM json_each(t0."tags") WHERE json_each.value = ?)`, []any{"a"}},
+		{"IContains", And(Params{"Name__icontains": "A"}), `instr(lower(t0."name"), lower(?)) > 0`, []any{"A"}},
+		{"StartsWith", And(Params{"Name__startswith": "ab"}), `substr(t0."name", 1, length(?)) = ?`, []any{"ab", "ab"}},
+		{"Regex", And(Params{"Name__regex": "^a"}), `t0."name" REGEXP ?`, []any{"^a"}},
+		{"Between", And(Params{"Year__between": []uint{2000, 2010}}), `t0."year" BETWEEN ? AND ?`, []any{int64(2000), int64(2010)}},
+		{"IsNull", And(Params{"CreatedAt__isnull": true}), `(t0."created_at" IS NULL OR t0."created_at" = '` + zeroTime + `')`, nil},
+		{"Nil", And(Params{"Name": nil}), `(t0."name" IS NULL OR t0."name" = '')`, nil},
+		{"Date", And(Params{"CreatedAt>": time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)}), `t0."created_at" > ?`, []any{"2000-01-02 03:04:05.000000000Z"}},
+		{"JSON", And(Params{"Ref": "abc"}), `t0."ref" = ?`, []any{`"abc"`}},
+		{"Related", And(Params{"Owner__Name": "a"}), `t0."owner_id" IN (SELECT t1.id FROM "filter_owner" AS t1 WHERE t1."name" = ?)`, []any{"a"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, args, err := where(table, test.q)
+			if err != nil {
+				t.Fatalf("where: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("where = %v, want %v", got, test.want)
+			}
+			if len(args) != 0 || len(test.args) != 0 {
+				if !reflect.DeepEqual(args, test.args) {
+					t.Errorf("args = %#v, want %#v", args, test.args)
+				}
+			}
+		})
+	}
+}
+
+func TestWhereErrors(t *testing.T) {
+	schema, err := newSchema("filter_car", &filterCar{})
+	if err != nil {
+		t.Fatalf("newSchema: %v", err)
+	}
+	table := &SQLTable{db: &DataBase{}, name: "filter_car", schema: schema}
+	for _, q := range []Q{
+		And(Params{"Year__in": 2000}),
+		And(Params{"Name__isnull": "yes"}),
+		And(Params{"Year__between": []uint{2000}}),
+		And(Params{"Name>": nil}),
+	} {
+		if _, _, err := where(table, q); err == nil {
+			t.Errorf("where(%v) returns no error", q)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+)
+
+// newManager returns manager of table, its queries are sql queries.
+func newManager(table *SQLTable) *base.Manager {
+	manager := base.NewManager(table)
+	manager.OnAll = ManagerAll
+	manager.OnFilter = ManagerFilter
+	manager.OnCount = ManagerCount
+	manager.OnIterate = ManagerIterate
+	return manager
+}
+
+// ManagerAll selects models of manager ordered and paged by its query,
+// instance managers returned by Filter return their stored models.
+func ManagerAll(ctx context.Context, manager ManagerI) ([]Model, error) {
+	baseManager := manager.(*base.Manager)
+	if manager.IsInstance() {
+		return baseManager.Cached(ctx)
+	}
+	// manager has no conditions but scope of soft deleted models
+	query := baseManager.Query()
+	objects, err := list(ctx, manager, query.Q(), query.Limit, query.Offset)
+	if err != nil {
+		return nil, NewErrorf("sqlite.managerAll: %v", err)
+	}
+	return objects, nil
+}
+
+// ManagerFilter selects models of q ordered and paged by query of manager.
+func ManagerFilter(ctx context.Context, manager ManagerI, q Q) ([]Model, error) {
+	query := manager.(*base.Manager).Query()
+	objects, err := list(ctx, manager, q, query.Limit, query.Offset)
+	if err != nil {
+		return nil, NewErrorf("sqlite.managerFilter: %v", err)
+	}
+	return objects, nil
+}
+
+// ManagerCount counts rows of manager by sql, instance managers count their stored models.
+func ManagerCount(ctx context.Context, manager ManagerI) (uint, error) {
+	baseManager := manager.(*base.Manager)
+	if manager.IsInstance() {
+		objects, err := baseManager.Cached(ctx)
+		return uint(len(objects)), err
+	}
+	table := manager.Table().(*SQLTable)
+	query := baseManager.Query()
+	condition, args, err := where(table, query.Q())
+	if err != nil {
+		return 0, err
+	}
+	selectSQL := "SELECT t0.id FROM " + quote(table.name) + " AS t0"
+	if condition != "" {
+		selectSQL += " WHERE " + condition
+	}
+	selectSQL, args = page(selectSQL, args, query.Limit, query.Offset)
+	var count uint
+	if err := table.db.conn().QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+selectSQL+")", args...).Scan(&count); err != nil {
+		return 0, NewErrorf("sqlite.managerCount: %v", err)
+	}
+	return count, nil
+}
+
+// list returns models of q ordered by query of manager.
+func list(ctx context.Context, manager ManagerI, q Q, limit, offset uint) ([]Model, error) {
+	table := manager.Table().(*SQLTable)
+	condition, args, err := where(table, q)
+	if err != nil {
+		return nil, err
+	}
+	selectSQL := table.selectSQL(condition) + " ORDER BY " + order(table.schema, manager.(*base.Manager).Query().Order)
+	selectSQL, args = page(selectSQL, args, limit, offset)
+	rows, err := table.db.conn().QueryContext(ctx, selectSQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	return table.scan(rows)
+}
+
+// page adds limit and offset to query, zero limit is no limit.
+func page(query string, args []any, limit, offset uint) (string, []any) {
+	if limit == 0 && offset == 0 {
+		return query, args
+	}
+	n := int64(-1)
+	if limit > 0 {
+		n = int64(limit)
+	}
+	return query + " LIMIT ? OFFSET ?", append(args, n, int64(offset))
+}
+
+// iteratePage is count of rows loaded by ManagerIterate in one query
+const iteratePage = 200
+
+// ManagerIterate loads rows by pages lazily, next page is queried
+// after fn takes all models of previous one.
+func ManagerIterate(ctx context.Context, manager ManagerI, q Q, fn func(model Model) bool) error {
+	for offset := uint(0); ; offset += iteratePage {
+		models, err := list(ctx, manager, q, iteratePage, offset)
+		if err != nil {
+			return err
+		}
+		for _, model := range models {
+			if !fn(model) {
+				return nil
+			}
+		}
+		if len(models) < iteratePage {
+			return nil
+		}
+	}
+}
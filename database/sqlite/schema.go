@@ -0,0 +1,338 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+// Types of columns
+const (
+	typeNumber = "number"
+	typeBool   = "bool"
+	typeText   = "text"
+	typeDate   = "date" // text in timeLayout, zero time is stored too
+	typeJSON   = "json"
+)
+
+// timeLayout keeps order of dates of equal width.
+const timeLayout = "2006-01-02 15:04:05.000000000Z"
+
+// column is column of field of model named by json tag of field.
+type column struct {
+	Name  string
+	Field string
+	Index []int
+	Type  string
+	List  bool // json array, many-to-many fields and slices
+}
+
+// definition returns sql definition of column.
+func (column column) definition() string {
+	typ := "TEXT"
+	switch column.Type {
+	case typeNumber:
+		typ = "NUMERIC"
+	case typeBool:
+		typ = "BOOLEAN"
+	}
+	return quote(column.Name) + " " + typ
+}
+
+// schema is sql table of model.
+type schema struct {
+	name    string
+	model   Model
+	idText  bool // id is string, else it is uint
+	columns []column
+	indexes [][]string // names of columns of indexes
+	unique  [][]string // names of columns of unique indexes
+}
+
+// newSchema returns schema of table of model like CreateDataCollection,
+// fields without json tag are skipped, fields of embedded structs are columns too.
+func newSchema(name string, model Model) (*schema, error) {
+	if model == nil {
+		return nil, NewErrorf("sqlite: model is nil")
+	}
+	vModel, err := GoToStruct(reflect.ValueOf(model))
+	if err != nil || reflect.ValueOf(model).Kind() != reflect.Pointer {
+		return nil, NewErrorf("sqlite: model must be a pointer to a struct")
+	}
+	schema := &schema{name: name, model: model}
+	switch model.Id().(type) {
+	case uint:
+	case string:
+		schema.idText = true
+	default:
+		// id of user is any, it is uint as in bbolt
+		if model.Id() != nil || name != "user" {
+			return nil, NewErrorf("sqlite: id must be uint or string")
+		}
+	}
+	schema.addColumns(vModel.Type(), nil)
+	for _, set := range UniqueSets(model) {
+		schema.unique = append(schema.unique, JSONNames(model, set))
+	}
+	for _, column := range schema.columns {
+		if GetTagField(model, column.Field, "index") == "true" {
+			schema.indexes = append(schema.indexes, []string{column.Name})
+		}
+	}
+	return schema, nil
+}
+
+func (schema *schema) addColumns(typ reflect.Type, index []int) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+		tag := field.Tag.Get("json")
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			schema.addColumns(field.Type, fieldIndex)
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if !field.IsExported() || name == "" || name == "-" || field.Name == "ID" {
+			continue
+		}
+		column := column{Name: name, Field: field.Name, Index: fieldIndex}
+		column.Type, column.List = columnType(field)
+		schema.columns = append(schema.columns, column)
+	}
+}
+
+// columnType returns type of column of field.
+func columnType(field reflect.StructField) (string, bool) {
+	if IsManyToMany(field) {
+		return typeJSON, true
+	}
+	typ := field.Type
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(time.Time{}) || typ == reflect.TypeOf(PBTime{}) {
+		return typeDate, false
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return typeBool, false
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return typeNumber, false
+	case reflect.String:
+		return typeText, false
+	case reflect.Slice, reflect.Array:
+		return typeJSON, true
+	}
+	return typeJSON, false
+}
+
+// column returns column of field or json name of model, ok is false for unknown name.
+func (schema *schema) column(name string) (column, bool) {
+	for _, column := range schema.columns {
+		if column.Field == name {
+			return column, true
+		}
+	}
+	for _, column := range schema.columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	if name == "ID" || name == "Id" || name == "id" {
+		typ := typeNumber
+		if schema.idText {
+			typ = typeText
+		}
+		return column{Name: "id", Field: "ID", Type: typ}, true
+	}
+	return column{}, false
+}
+
+func (schema *schema) createSQL() string {
+	id := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if schema.idText {
+		id = "TEXT PRIMARY KEY NOT NULL"
+	}
+	definitions := []string{"id " + id}
+	for _, column := range schema.columns {
+		definitions = append(definitions, column.definition())
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (%v)", quote(schema.name), strings.Join(definitions, ", "))
+}
+
+func (schema *schema) indexesSQL() []string {
+	indexes := []string{}
+	for _, columns := range schema.indexes {
+		indexes = append(indexes, fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS %v ON %v (%v)",
+			quote("idx_"+schema.name+"_"+strings.Join(columns, "_")), quote(schema.name), quoteAll(columns),
+		))
+	}
+	for _, columns := range schema.unique {
+		indexes = append(indexes, fmt.Sprintf(
+			"CREATE UNIQUE INDEX IF NOT EXISTS %v ON %v (%v)",
+			quote("idx_unique_"+schema.name+"_"+strings.Join(columns, "_")), quote(schema.name), quoteAll(columns),
+		))
+	}
+	return indexes
+}
+
+// names returns quoted names of columns of schema, id is first.
+func (schema *schema) names() []string {
+	names := []string{"id"}
+	for _, column := range schema.columns {
+		names = append(names, quote(column.Name))
+	}
+	return names
+}
+
+// values returns values of columns of model.
+func (schema *schema) values(model Model) ([]any, error) {
+	modelV := reflect.ValueOf(model).Elem()
+	values := make([]any, len(schema.columns))
+	for i, column := range schema.columns {
+		value, err := toSQL(column, modelV.FieldByIndex(column.Index))
+		if err != nil {
+			return nil, fmt.Errorf("field `%v`: %v", column.Field, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// toSQL returns value of field stored in column.
+func toSQL(column column, field reflect.Value) (any, error) {
+	if column.Type == typeJSON {
+		value := field.Interface()
+		if field.CanAddr() {
+			value = field.Addr().Interface()
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if string(data) == "null" {
+			return nil, nil
+		}
+		return string(data), nil
+	}
+	for field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return nil, nil
+		}
+		field = field.Elem()
+	}
+	switch column.Type {
+	case typeDate:
+		return field.Convert(reflect.TypeOf(time.Time{})).Interface().(time.Time).UTC().Format(timeLayout), nil
+	case typeBool:
+		return field.Bool(), nil
+	case typeText:
+		return field.String(), nil
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(field.Uint()), nil
+	}
+	return field.Float(), nil
+}
+
+// fromSQL returns json value of column of value read from db.
+func fromSQL(column column, value any) any {
+	if value == nil {
+		return nil
+	}
+	if data, ok := value.([]byte); ok {
+		value = string(data)
+	}
+	switch column.Type {
+	case typeJSON:
+		if data, ok := value.(string); ok {
+			return json.RawMessage(data)
+		}
+	case typeBool:
+		switch v := value.(type) {
+		case int64:
+			return v != 0
+		case float64:
+			return v != 0
+		}
+	case typeDate:
+		if data, ok := value.(string); ok {
+			if t, err := time.Parse(timeLayout, data); err == nil {
+				return t.Format(time.RFC3339Nano)
+			}
+		}
+	}
+	return value
+}
+
+// sqlArg returns argument of sql compared with column.
+func sqlArg(column column, value any) any {
+	if model, ok := value.(Model); ok && reflect.ValueOf(model).Kind() == reflect.Pointer && !reflect.ValueOf(model).IsNil() {
+		value = model.Id()
+	}
+	if column.Type == typeJSON && !column.List && value != nil {
+		// scalar of json column, as any id of relation, is stored as json
+		if data, err := json.Marshal(value); err == nil {
+			return string(data)
+		}
+	}
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(timeLayout)
+	case PBTime:
+		return time.Time(v).UTC().Format(timeLayout)
+	case string:
+		if column.Type == typeDate {
+			for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00"} {
+				if t, err := time.Parse(layout, v); err == nil {
+					return t.UTC().Format(timeLayout)
+				}
+			}
+		}
+		return v
+	}
+	valueV := reflect.ValueOf(value)
+	switch valueV.Kind() {
+	case reflect.Pointer:
+		if valueV.IsNil() {
+			return nil
+		}
+		return sqlArg(column, valueV.Elem().Interface())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(valueV.Uint())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return valueV.Int()
+	case reflect.String:
+		return valueV.String()
+	case reflect.Bool:
+		return valueV.Bool()
+	case reflect.Float32, reflect.Float64:
+		return valueV.Float()
+	}
+	return value
+}
+
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteAll(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quote(name)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	if got := quote(`a"b`); got != `"a""b"` {
+		t.Errorf(`quote(a"b) = %v, want "a""b"`, got)
+	}
+	if got := quoteAll([]string{"id", `x"`}); got != `"id", "x"""` {
+		t.Errorf(`quoteAll = %v, want "id", "x"""`, got)
+	}
+}
+
+func TestSQLArg(t *testing.T) {
+	jsonColumn := column{Name: "ref", Type: typeJSON}
+	listColumn := column{Name: "tags", Type: typeJSON, List: true}
+	tests := []struct {
+		name   string
+		column column
+		value  any
+		want   any
+	}{
+		{"JSONString", jsonColumn, "abc", `"abc"`},
+		{"JSONQuote", jsonColumn, `a"b`, `"a\"b"`},
+		{"JSONNumber", jsonColumn, uint(1), `1`},
+		{"JSONNil", jsonColumn, nil, nil},
+		{"ListItem", listColumn, "a", "a"},
+		{"Model", column{Name: "owner_id", Type: typeNumber}, &filterOwner{ID: 3}, int64(3)},
+		{"Uint", column{Name: "year", Type: typeNumber}, uint(2000), int64(2000)},
+		{"Date", column{Name: "created_at", Type: typeDate}, "2000-01-02T03:04:05+01:00", "2000-01-02 02:04:05.000000000Z"},
+		{"Text", column{Name: "name", Type: typeText}, "2000-01-02T03:04:05Z", "2000-01-02T03:04:05Z"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := sqlArg(test.column, test.value); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("sqlArg = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/PoulIgorson/sub_engine_fiber/database/base"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/errors"
+	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
+	. "github.com/PoulIgorson/sub_engine_fiber/define"
+)
+
+var _ ContextTable = &SQLTable{}
+var _ ManyGetter = &SQLTable{}
+
+// SQLTable implements interface access to sql table of model.
+type SQLTable struct {
+	db     *DataBase
+	name   string
+	schema *schema
+
+	model   Model
+	Objects ManagerI
+
+	// parent is table of parent db, set for table of transaction
+	parent *SQLTable
+}
+
+func (table *SQLTable) Manager() ManagerI {
+	return table.Objects
+}
+
+// cache returns manager of table outside of transaction,
+// it is updated after commit.
+func (table *SQLTable) cache() ManagerI {
+	if table.parent != nil {
+		return table.parent.Objects
+	}
+	return table.Objects
+}
+
+func (table *SQLTable) SetManager(newManager ManagerI) {
+	table.Objects = newManager
+}
+
+// DB returns pointer to DB.
+func (table *SQLTable) DB() DB {
+	return table.db
+}
+
+// Name returns name of table.
+func (table *SQLTable) Name() string {
+	return table.name
+}
+
+// Model returns model of table.
+func (table *SQLTable) Model() Model {
+	return table.model
+}
+
+// checkId returns id of table of idI, ids read from json are float64.
+func (table *SQLTable) checkId(idI any) (any, error) {
+	if table.schema.idText {
+		id, ok := idI.(string)
+		if !ok {
+			return nil, NewErrorf("sqlite: id must be string")
+		}
+		return id, nil
+	}
+	switch id := idI.(type) {
+	case uint:
+		return id, nil
+	case float64:
+		return uint(id), nil
+	}
+	return nil, NewErrorf("sqlite: id must be uint")
+}
+
+// Count returns count of rows of table, soft deleted rows too.
+func (table *SQLTable) Count() uint {
+	var count uint
+	err := table.db.conn().QueryRowContext(context.Background(), "SELECT COUNT(*) FROM "+quote(table.name)).Scan(&count)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+// scan returns models of rows selected by columns of schema.
+func (table *SQLTable) scan(rows *sql.Rows) ([]Model, error) {
+	defer rows.Close()
+	models := []Model{}
+	values := make([]any, len(table.schema.columns)+1)
+	pointers := make([]any, len(values))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		data := map[string]any{"id": values[0]}
+		for i, column := range table.schema.columns {
+			if value := fromSQL(column, values[i+1]); value != nil {
+				data[column.Name] = value
+			}
+		}
+		dataByte, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, table.model.Create(table.db, string(dataByte)))
+	}
+	return models, rows.Err()
+}
+
+// selectSQL returns query of models of rows of table `t0`.
+func (table *SQLTable) selectSQL(condition string) string {
+	names := []string{}
+	for _, name := range table.schema.names() {
+		names = append(names, "t0."+name)
+	}
+	query := "SELECT " + strings.Join(names, ", ") + " FROM " + quote(table.name) + " AS t0"
+	if condition != "" {
+		query += " WHERE " + condition
+	}
+	return query
+}
+
+// get returns model of id or nil if it is missing.
+func (table *SQLTable) get(ctx context.Context, id any) (Model, error) {
+	rows, err := table.db.conn().QueryContext(ctx, table.selectSQL("t0.id = ?"), sqlArg(column{}, id))
+	if err != nil {
+		return nil, err
+	}
+	models, err := table.scan(rows)
+	if err != nil || len(models) == 0 {
+		return nil, err
+	}
+	return models[0], nil
+}
+
+func (table *SQLTable) Get(idI any) (Model, error) {
+	return table.GetContext(context.Background(), idI)
+}
+
+func (table *SQLTable) GetContext(ctx context.Context, idI any) (Model, error) {
+	id, err := table.checkId(idI)
+	if err != nil {
+		return nil, err
+	}
+	model, err := table.get(ctx, id)
+	if err != nil {
+		return nil, NewErrorf("sqlite: Table.Get: %v", err)
+	}
+	if model == nil {
+		return nil, NewErrorf("sqlite: Table.Get: id `%v` is not exists", id)
+	}
+	return model, nil
+}
+
+// GetManyContext returns models of ids read by one query, missing ids are skipped.
+func (table *SQLTable) GetManyContext(ctx context.Context, ids []any) ([]Model, error) {
+	if len(ids) == 0 {
+		return []Model{}, nil
+	}
+	args := make([]any, len(ids))
+	for i, idI := range ids {
+		id, err := table.checkId(idI)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = sqlArg(column{}, id)
+	}
+	rows, err := table.db.conn().QueryContext(ctx, table.selectSQL("t0.id IN ("+marks(len(ids))+")"), args...)
+	if err != nil {
+		return nil, NewErrorf("sqlite: Table.GetMany: %v", err)
+	}
+	models, err := table.scan(rows)
+	if err != nil {
+		return nil, NewErrorf("sqlite: Table.GetMany: %v", err)
+	}
+	return models, nil
+}
+
+func (table *SQLTable) Save(model Model) error {
+	return table.SaveContext(context.Background(), model)
+}
+
+// SaveContext writes model in one transaction. Model without id gets next id of table,
+// uint or random string, model of missing id is inserted with it.
+func (table *SQLTable) SaveContext(ctx context.Context, model Model) error {
+	fieldId, err := Check(model, "ID")
+	if err != nil {
+		return NewErrorf("sqlite: " + err.Error())
+	}
+	if model.Id() == nil {
+		// model without id is new model
+		if table.schema.idText {
+			fieldId.Set(reflect.ValueOf(""))
+		} else {
+			fieldId.Set(reflect.ValueOf(uint(0)))
+		}
+	}
+	id, err := table.checkId(model.Id())
+	if err != nil {
+		return err
+	}
+	version := base.ModelVersion(model)
+	if table.db.tx == nil {
+		// read of stored model and write are atomic
+		err := table.db.Tx(func(tx DB) error {
+			return tx.TableFromCache(table.name).(*SQLTable).SaveContext(ctx, model)
+		})
+		if err != nil {
+			// id assigned in rolled back transaction
+			fieldId.Set(reflect.ValueOf(id))
+			base.SetVersion(model, version)
+			return err
+		}
+		// fields are bound to table, not to finished transaction
+		base.BindManyRelations(table, model)
+		return nil
+	}
+	if err := base.BeforeSave(table, model); err != nil {
+		return err
+	}
+	if err := Validate(table.name, model); err != nil {
+		return err
+	}
+
+	var old Model
+	if id != uint(0) && id != "" {
+		if old, err = table.get(ctx, id); err != nil {
+			return NewErrorf("sqlite: Table.Save: %v", err)
+		}
+	}
+	if old == nil {
+		base.NextVersion(ctx, table.name, model, 0, false)
+		base.Stamp(ctx, model, time.Time{}, time.Now().UTC())
+	} else {
+		if err := base.NextVersion(ctx, table.name, model, base.ModelVersion(old), true); err != nil {
+			return err
+		}
+		base.Stamp(ctx, model, base.AutoTime(old, AutoCreated), time.Now().UTC())
+	}
+	if id == "" {
+		id = base.NewStringId()
+	}
+
+	values, err := table.schema.values(model)
+	if err != nil {
+		return NewErrorf("sqlite: Table.Save: %v", err)
+	}
+	names := table.schema.names()
+	var result sql.Result
+	switch {
+	case old != nil && len(names) == 1:
+		// table of id only, nothing to update
+	case old != nil:
+		sets := make([]string, len(names)-1)
+		for i, name := range names[1:] {
+			sets[i] = name + " = ?"
+		}
+		query := "UPDATE " + quote(table.name) + " SET " + strings.Join(sets, ", ") + " WHERE id = ?"
+		result, err = table.db.conn().ExecContext(ctx, query, append(values, sqlArg(column{}, id))...)
+	case id == uint(0):
+		query := "INSERT INTO " + quote(table.name) + " (" + strings.Join(names[1:], ", ") + ") VALUES (" + marks(len(values)) + ")"
+		if len(values) == 0 {
+			query = "INSERT INTO " + quote(table.name) + " DEFAULT VALUES"
+		}
+		result, err = table.db.conn().ExecContext(ctx, query, values...)
+	default:
+		query := "INSERT INTO " + quote(table.name) + " (" + strings.Join(names, ", ") + ") VALUES (" + marks(len(values)+1) + ")"
+		result, err = table.db.conn().ExecContext(ctx, query, append([]any{sqlArg(column{}, id)}, values...)...)
+	}
+	if err != nil {
+		if fields := UniqueColumns(err); len(fields) > 0 {
+			return NewErrUnique(table.name, fields)
+		}
+		return NewErrorf("sqlite: Table.Save: %v", err)
+	}
+	if id == uint(0) {
+		lastId, err := result.LastInsertId()
+		if err != nil {
+			return NewErrorf("sqlite: Table.Save: %v", err)
+		}
+		id = uint(lastId)
+	}
+	fieldId.Set(reflect.ValueOf(id))
+
+	base.BindManyRelations(table, model)
+	return base.AfterSave(table, model)
+}
+
+// marks returns n marks of arguments.
+func marks(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func (table *SQLTable) Delete(idI any) error {
+	return table.DeleteContext(context.Background(), idI)
+}
+
+// DeleteContext removes model of id, soft deleted models are marked by field `deleted_at`.
+// Missing id is skipped.
+func (table *SQLTable) DeleteContext(ctx context.Context, idI any) error {
+	id, err := table.checkId(idI)
+	if err != nil {
+		return err
+	}
+	if SoftDeleteField(table.model) != "" {
+		return base.SoftDeleteContext(ctx, table, id)
+	}
+	return table.hardDelete(ctx, id)
+}
+
+// HardDelete removes model of id, soft deleted models too.
+func (table *SQLTable) HardDelete(idI any) error {
+	id, err := table.checkId(idI)
+	if err != nil {
+		return err
+	}
+	return table.hardDelete(context.Background(), id)
+}
+
+// Restore clears field `deleted_at` of soft deleted model of id.
+func (table *SQLTable) Restore(idI any) error {
+	return base.Restore(table, idI)
+}
+
+// hardDelete removes row of id with actions of relations.
+func (table *SQLTable) hardDelete(ctx context.Context, id any) error {
+	references := table.db.relations.To(table.name)
+	hooked := base.HasDeleteHooks(table.model)
+	if table.db.tx == nil && (len(references) > 0 || hooked) {
+		return table.db.Tx(func(tx DB) error {
+			return tx.TableFromCache(table.name).(*SQLTable).hardDelete(ctx, id)
+		})
+	}
+	var model Model
+	if hooked {
+		// missing row is not deleted, hooks are not called
+		if model, _ = table.get(ctx, id); model != nil {
+			if err := base.BeforeDelete(table, model); err != nil {
+				return err
+			}
+		}
+	}
+	if len(references) > 0 {
+		if err := table.db.relations.OnDelete(table.db, table.name, id); err != nil {
+			return err
+		}
+	}
+	if _, err := table.db.conn().ExecContext(ctx, "DELETE FROM "+quote(table.name)+" WHERE id = ?", sqlArg(column{}, id)); err != nil {
+		return NewErrorf("sqlite: Table.Delete: %v", err)
+	}
+	if model != nil {
+		if err := base.AfterDelete(table, model); err != nil {
+			return err
+		}
+	}
+	table.db.afterCommit.Do(func() { table.cache().ClearId(id) })
+	return nil
+}
+
+// SaveMany saves models in one transaction.
+func (table *SQLTable) SaveMany(models []Model) error {
+	return base.SaveMany(table, models)
+}
+
+// DeleteMany deletes models of ids in one transaction.
+func (table *SQLTable) DeleteMany(ids []any) error {
+	return base.DeleteMany(table, ids)
+}
+
+// DeleteAll removes all rows of table.
+func (table *SQLTable) DeleteAll() error {
+	if _, err := table.db.conn().ExecContext(context.Background(), "DELETE FROM "+quote(table.name)); err != nil {
+		return NewErrorf("sqlite: Table.DeleteAll: %v", err)
+	}
+	table.db.afterCommit.Do(table.cache().Clear)
+	return nil
+}
@@ -3,6 +3,7 @@ package define
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/binary"
@@ -385,12 +386,17 @@ type Headers map[string]string
 type Data map[string]any
 
 func GetResponse(method, curl string, headers Headers, data Data) (int, []byte, error) {
+	return GetResponseContext(context.Background(), method, curl, headers, data)
+}
+
+// GetResponseContext is GetResponse bound to ctx: the request is aborted when ctx is done.
+func GetResponseContext(ctx context.Context, method, curl string, headers Headers, data Data) (int, []byte, error) {
 	dataByte, err := json.Marshal(data)
 	if err != nil {
 		return 500, nil, fmt.Errorf("GetResponse: marshal data: %v", err)
 	}
 
-	req, err := http.NewRequest(method, curl, strings.NewReader(string(dataByte)))
+	req, err := http.NewRequestWithContext(ctx, method, curl, strings.NewReader(string(dataByte)))
 	if err != nil {
 		return 500, nil, fmt.Errorf("GetResponse: create request: %v", err)
 	}
@@ -406,12 +412,18 @@ func GetResponse(method, curl string, headers Headers, data Data) (int, []byte,
 		return 500, nil, fmt.Errorf("GetResponse: get response: %v", err)
 	}
 
+	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
 	return resp.StatusCode, body, nil
 }
 
 func GetJSONResponse(method, curl string, headers Headers, data Data) (int, any, error) {
-	status, body, err := GetResponse(method, curl, headers, data)
+	return GetJSONResponseContext(context.Background(), method, curl, headers, data)
+}
+
+// GetJSONResponseContext is GetJSONResponse bound to ctx.
+func GetJSONResponseContext(ctx context.Context, method, curl string, headers Headers, data Data) (int, any, error) {
+	status, body, err := GetResponseContext(ctx, method, curl, headers, data)
 	if err != nil {
 		return status, body, err
 	}
@@ -492,6 +504,19 @@ func Compare(a, b any) int {
 			return 0
 		}
 		return 1
+	case reflect.Struct:
+		timeT := reflect.TypeOf(time.Time{})
+		if !v.Type().ConvertibleTo(timeT) || !u.Type().ConvertibleTo(timeT) {
+			break
+		}
+		a := v.Convert(timeT).Interface().(time.Time)
+		b := u.Convert(timeT).Interface().(time.Time)
+		if a.Before(b) {
+			return -1
+		} else if a.Equal(b) {
+			return 0
+		}
+		return 1
 	}
 	return -2
 }
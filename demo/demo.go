@@ -6,6 +6,7 @@ import (
 	"time"
 
 	db "github.com/PoulIgorson/sub_engine_fiber/database"
+	"github.com/PoulIgorson/sub_engine_fiber/database/aggregate"
 	. "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
 )
 
@@ -64,14 +65,10 @@ func createModels(db_ DB, count int) {
 	}
 }
 
-func showCars(cars []Model) {
+func showCars(cars []*Car) {
 	fmt.Printf(" %15v | %10v | %10v | %10v | %10v\n", "ID", "modelCar", "color", "city", "year")
 	fmt.Printf("---------------- | ---------- | ---------- | ---------- | ----------\n")
-	for _, carM := range cars {
-		car, ok := carM.(*Car)
-		if !ok {
-			continue
-		}
+	for _, car := range cars {
 		fmt.Printf(" %15v | %10v | %10v | %10v | %10v\n", car.ID, car.ModelCar, car.Color, car.City, car.Year)
 	}
 }
@@ -87,7 +84,7 @@ func main() {
 	defer db_.Close()
 
 	fmt.Println("getting table")
-	table, err := db_.Table("car", &Car{})
+	table, err := OpenTable(db_, "car", &Car{})
 	if err != nil {
 		panic("DB.Table: " + err.Error())
 	}
@@ -98,6 +95,15 @@ func main() {
 	fmt.Println("all models")
 	showCars(table.Manager().All())
 
+	fmt.Println("cars by city")
+	groups, err := table.Manager().GroupBy("City").Aggregate(aggregate.Count(), aggregate.Avg("Year"))
+	if err != nil {
+		panic("Manager.GroupBy: " + err.Error())
+	}
+	for _, group := range groups {
+		fmt.Printf(" %10v | %3v | %v\n", group.Values["City"], group.Result["count"], group.Result["avg_Year"])
+	}
+
 	fmt.Println("creating model")
 	car := &Car{
 		ModelCar: "BMW",
@@ -107,7 +113,7 @@ func main() {
 		InSale:   PBTime(time.Now()),
 	}
 	fmt.Println("saving model")
-	if err := car.Save(table); err != nil {
+	if err := car.Save(table.Untyped()); err != nil {
 		panic("Car.Save: " + err.Error())
 	}
 
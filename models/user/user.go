@@ -46,7 +46,7 @@ func GetRole(name string, access ...uint) *Role {
 // User presents model of bucket.
 type User struct {
 	ID       any    `json:"id"`
-	Login    string `json:"login"`
+	Login    string `json:"login" index:"true" unique:"true" validate:"required,min=4,max=64"`
 	Password string `json:"password"`
 	Role     *Role  `json:"role"`
 
@@ -99,15 +99,18 @@ func CheckUser(db_ db.DB, userStr string) bool {
 	if user.ID == "" {
 		return false
 	}
-	userBct, _ := db_.Table("user", &User{})
-	ruserM := userBct.Manager().Get(user.ID)
-	if ruserM == nil {
+	userBct, err := db.OpenTable(db_, "user", &User{})
+	if err != nil {
 		return false
 	}
-	if ruserM.(*User).Login != user.Login {
+	ruser := userBct.Manager().Get(user.ID)
+	if ruser == nil {
 		return false
 	}
-	if ruserM.(*User).Password != user.Password {
+	if ruser.Login != user.Login {
+		return false
+	}
+	if ruser.Password != user.Password {
 		return false
 	}
 	return true
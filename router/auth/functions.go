@@ -7,6 +7,8 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 
+	dbdefine "github.com/PoulIgorson/sub_engine_fiber/database/define"
+	dberrors "github.com/PoulIgorson/sub_engine_fiber/database/errors"
 	db "github.com/PoulIgorson/sub_engine_fiber/database/interfaces"
 	. "github.com/PoulIgorson/sub_engine_fiber/define"
 	user "github.com/PoulIgorson/sub_engine_fiber/models/user"
@@ -41,13 +43,15 @@ func LoginPage(db_ db.DB, urls ...interface{}) fiber.Handler {
 			var data map[string]string
 			json.Unmarshal(c.Request().Body(), &data)
 
-			users, _ := db_.Table("user", &user.User{})
-			cuserModel := users.Manager().Filter(db.Params{"Login": data["login"]}).First()
-			if cuserModel == nil {
+			users, err := db.OpenTable(db_, "user", &user.User{})
+			if err != nil {
+				return c.JSON(fiber.Map{"Status": "500", "Error": err.Error()})
+			}
+			cuser := users.Manager().Filter(db.Params{"Login": data["login"]}).First()
+			if cuser == nil {
 				return c.JSON(fiber.Map{"Status": "400", "login": "Логин не существует"})
 			}
 
-			cuser := cuserModel.(*user.User)
 			if Hash([]byte(data["password"])) != cuser.Password {
 				return c.JSON(fiber.Map{"Status": "400", "password": "Неверный пароль"})
 			}
@@ -107,10 +111,6 @@ func APIRegistration(db_ db.DB, urls ...interface{}) fiber.Handler {
 		users, _ := db_.Table("user", &user.User{})
 
 		errors := map[string]string{}
-		if len(data["login"]) < 4 {
-			errors["login"] = "Слишком короткий логин"
-		}
-
 		if len(data["password1"]) < 8 {
 			errors["password1"] = "Слишком короткий пароль"
 		}
@@ -119,22 +119,10 @@ func APIRegistration(db_ db.DB, urls ...interface{}) fiber.Handler {
 			errors["password2"] = "Слишком короткий пароль"
 		}
 
-		if users.Manager().Filter(db.Params{"Login": data["login"]}).Count() > 0 {
-			errors["login"] = "Логин существует"
-		}
-
 		if data["password1"] != data["password2"] {
 			errors["password2"] = "Пароли не совпадают"
 		}
 
-		if len(errors) > 0 {
-			errorsMap := fiber.Map{"Status": "400"}
-			for field, err := range errors {
-				errorsMap[field] = err
-			}
-			return c.JSON(errorsMap)
-		}
-
 		copyData := CopyMapAny(data)
 		for _, k := range []string{"login", "password1", "password2", "role"} {
 			delete(copyData, k)
@@ -147,7 +135,30 @@ func APIRegistration(db_ db.DB, urls ...interface{}) fiber.Handler {
 			Role:        user.GetRole("", ParseUint(data["role"])),
 			ExtraFields: copyData,
 		}
+		// login is checked by tag validate of user.User together with other fields
+		if err := dbdefine.Validate("user", &cuser); err != nil {
+			errV, ok := err.(dberrors.ValidationError)
+			if !ok {
+				return c.JSON(fiber.Map{"Status": "500", "Error": err.Error()})
+			}
+			validationErrors(errV, errors)
+		}
+
+		if len(errors) > 0 {
+			return c.JSON(formErrors(errors))
+		}
+
 		if err := cuser.Save(users); err != nil {
+			if errU, ok := err.(dberrors.ErrUnique); ok {
+				for _, field := range errU.Fields {
+					errors[field] = uniqueMessage(field)
+				}
+				return c.JSON(formErrors(errors))
+			}
+			if errV, ok := err.(dberrors.ValidationError); ok {
+				validationErrors(errV, errors)
+				return c.JSON(formErrors(errors))
+			}
 			resp := fiber.Map{"Status": "500", "Error": err.Error()}
 			return c.JSON(resp)
 		}
@@ -155,6 +166,63 @@ func APIRegistration(db_ db.DB, urls ...interface{}) fiber.Handler {
 	}
 }
 
+// validationMessages are messages of broken rules of tags validate of user.User
+// by json names of fields.
+var validationMessages = map[string]map[string]string{
+	"login": {
+		"required": "Введите логин",
+		"min":      "Слишком короткий логин",
+		"max":      "Слишком длинный логин",
+	},
+}
+
+// ruleMessages are messages of broken rules of fields without own messages.
+var ruleMessages = map[string]string{
+	"required": "Заполните поле",
+	"min":      "Слишком короткое значение",
+	"max":      "Слишком длинное значение",
+	"email":    "Неверный email",
+}
+
+// uniqueMessages are messages of taken values of unique fields by json names of fields.
+var uniqueMessages = map[string]string{
+	"login": "Логин существует",
+}
+
+// validationErrors writes messages of fields of errV to errors,
+// fields with errors of form keep them.
+func validationErrors(errV dberrors.ValidationError, errors map[string]string) {
+	for field, msg := range errV.Fields {
+		if _, ok := errors[field]; ok {
+			continue
+		}
+		rule := errV.Rules[field]
+		if message, ok := validationMessages[field][rule]; ok {
+			msg = message
+		} else if message, ok := ruleMessages[rule]; ok {
+			msg = message
+		}
+		errors[field] = msg
+	}
+}
+
+// uniqueMessage returns message of taken value of unique field.
+func uniqueMessage(field string) string {
+	if message, ok := uniqueMessages[field]; ok {
+		return message
+	}
+	return "Значение занято"
+}
+
+// formErrors returns response with errors of fields of form.
+func formErrors(errors map[string]string) fiber.Map {
+	errorsMap := fiber.Map{"Status": "400"}
+	for field, err := range errors {
+		errorsMap[field] = err
+	}
+	return errorsMap
+}
+
 func APINewPassword(db_ db.DB, urls ...interface{}) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var data map[string]string
@@ -173,14 +241,16 @@ func APINewPassword(db_ db.DB, urls ...interface{}) fiber.Handler {
 			return c.JSON(fiber.Map{"Status": "500", "password1": "Пароли не совпадают"})
 		}
 
-		users, _ := db_.Table("user", &user.User{})
-		cuserModel := users.Manager().Filter(db.Params{"Login": data["login"]}).First()
-		if cuserModel == nil {
+		users, err := db.OpenTable(db_, "user", &user.User{})
+		if err != nil {
+			return c.JSON(fiber.Map{"Status": "500", "Error": err.Error()})
+		}
+		cuser := users.Manager().Filter(db.Params{"Login": data["login"]}).First()
+		if cuser == nil {
 			return c.JSON(fiber.Map{"Status": "500", "Error": "Логин не существует"})
 		}
-		cuser := cuserModel.(*user.User)
 		cuser.Password = Hash([]byte(password1))
-		cuser.Save(users)
+		cuser.Save(users.Untyped())
 		types.NotifyInfo("Пароль успешно изменен", cuser.ID)
 		return c.JSON(fiber.Map{"Status": "200"})
 	}